@@ -1,11 +1,18 @@
 package api
 
 import (
+	"backend/pkg/auth"
+	"backend/pkg/config"
 	"backend/pkg/handler"
+	"backend/pkg/mail"
+	"backend/pkg/model"
+	"backend/pkg/ranking"
 	"backend/pkg/repository"
 	"backend/pkg/ws"
+	"backend/util"
 	"database/sql"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 
@@ -14,7 +21,7 @@ import (
 )
 
 // API layer, handlers, and routing
-func Router(mux *mux.Router, db *sql.DB) {
+func Router(mux *mux.Router, db *sql.DB, cfg *config.Config) {
 	// User registration requires input in the form like RegistrationData struct at /pkg/model/stucts.go
 	userRepository := repository.NewUserRepository(db)
 	postRepository := repository.NewPostRepository(db)
@@ -26,104 +33,233 @@ func Router(mux *mux.Router, db *sql.DB) {
 	eventRepository := repository.NewEventRepository(db)
 	sessionRepository := repository.NewSessionRepository(db)
 	friendsRepository := repository.NewFriendsRepository(db)
-	voteRepository := repository.NewVoteRepository(db)
+	reactionRepository := repository.NewReactionRepository(db)
+	userTokenRepository := repository.NewUserTokenRepository(db)
+	twoFactorRepository := repository.NewTwoFactorRepository(db)
+	passkeyRepository := repository.NewPasskeyRepository(db)
+	loginThrottleRepository := repository.NewLoginThrottleRepository(db)
+	identityRepository := repository.NewIdentityRepository(db)
+	personalTokenRepository := repository.NewPersonalTokenRepository(db)
+	oauthRepository := repository.NewOAuthRepository(db)
+	inviteRepository := repository.NewInviteRepository(db)
+	audienceListRepository := repository.NewAudienceListRepository(db)
+	feedRepository := repository.NewFeedRepository(db)
+	searchRepository := repository.NewSearchRepository(db)
+	tagRepository := repository.NewTagRepository(db)
+	mentionRepository := repository.NewMentionRepository(db)
+
+	// Client addresses (sessions, login lockout) only come from X-Forwarded-For behind a trusted proxy
+	util.SetTrustedProxies(cfg.App.TrustedProxies)
+
+	mailer, err := mail.New(cfg.Mail)
+	if err != nil {
+		log.Fatal("Error setting up the mailer: ", err)
+	}
+
+	// Routes opt in to one of four access levels by being registered on the matching subrouter:
+	// public routes need no session, authenticated routes need a valid session, verified routes additionally
+	// a verified email address (see REQUIRE_EMAIL_VERIFICATION) and admin routes an admin user.
+	// The auth middleware puts the identity of the user into the request context, see auth.UserIDFromRequest.
+	// Personal access tokens and OAuth access tokens (Authorization: Bearer) only work on routes registered with auth.Scoped.
+	sessionManager := auth.NewSessionManager(sessionRepository, cfg.Session)
+	authenticator := auth.NewAuthenticator(sessionRepository, userRepository, personalTokenRepository, oauthRepository, sessionManager, cfg.Account)
+	public := mux.NewRoute().Subrouter()
+	authed := mux.NewRoute().Subrouter()
+	authed.Use(authenticator.RequireAuthentication)
+	verified := mux.NewRoute().Subrouter()
+	verified.Use(authenticator.RequireVerifiedEmail)
+	admin := mux.NewRoute().Subrouter()
+	admin.Use(authenticator.RequireAdmin)
 
 	notificationHandler := handler.NewNotificationHandler(notificationRepository, sessionRepository, groupMemberRepository, groupRepository, userRepository, invitationRepository, eventRepository)
-	voteHandler := handler.NewVoteHandler(voteRepository, sessionRepository)
+	reactionHandler := handler.NewReactionHandler(reactionRepository, postRepository, commentRepository, cfg.Reactions)
+	mentionHandler := handler.NewMentionHandler(mentionRepository, postRepository, userRepository, notificationHandler)
 	chatRepository := ws.NewChatRepository(db)
 
-	chatHandler := ws.NewChatHandler(chatRepository, sessionRepository)
+	chatHandler := ws.NewChatHandler(chatRepository, sessionRepository, reactionRepository, mentionRepository, notificationRepository, userRepository, cfg.Reactions)
 	hub := ws.NewHub(chatHandler)
-	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		hub.ServeWs(w, r)
-	})
+	http.Handle("/ws", authenticator.RequireVerifiedEmail(auth.Scoped(model.ScopeChat, hub.ServeWs)))
 
-	userHandler := handler.NewUserHandler(userRepository, sessionRepository, friendsRepository)
-	mux.HandleFunc("/api/users/register", userHandler.UserRegisterHandler).Methods("POST")
+	accountHandler := handler.NewAccountHandler(userRepository, userTokenRepository, sessionRepository, mailer, notificationHandler, cfg.App, cfg.Account)
+	loginLimiter := auth.NewLoginLimiter(loginThrottleRepository, cfg.Lockout)
+	twoFactorHandler := handler.NewTwoFactorHandler(twoFactorRepository, userRepository, sessionManager, loginLimiter, notificationHandler, cfg.TwoFactor)
+	inviteHandler := handler.NewInviteHandler(inviteRepository, cfg.Registration)
+	userHandler := handler.NewUserHandler(userRepository, sessionRepository, friendsRepository, sessionManager, accountHandler, twoFactorHandler, loginLimiter, notificationHandler, inviteHandler)
+	public.HandleFunc("/api/users/register", userHandler.UserRegisterHandler).Methods("POST")
+	public.HandleFunc("/api/users/registration", inviteHandler.GetRegistrationHandler).Methods("GET") // registration mode, ?invite_code= checks a code
+	// Invitation codes, needed to sign up when REGISTRATION_MODE is invite
+	authed.HandleFunc("/api/invites", inviteHandler.GetInvitesHandler).Methods("GET")
+	verified.HandleFunc("/api/invites", inviteHandler.CreateInviteHandler).Methods("POST")
+	authed.HandleFunc("/api/invites/invitees", inviteHandler.GetInviteesHandler).Methods("GET")
+	authed.HandleFunc("/api/invites/{id}", inviteHandler.DeleteInviteHandler).Methods("DELETE")
 	// User login and logout
-	mux.HandleFunc("/api/users/logout", handler.LogoutHandler).Methods("POST")
-	mux.HandleFunc("/api/users/login", userHandler.LoginHandler).Methods("POST")
-	mux.HandleFunc("/api/users/check-auth", userHandler.CheckAuth)
-	mux.HandleFunc("/api/users/auth-update", userHandler.UpdateAuth).Methods("PUT")
-	mux.HandleFunc("/api/users/list", userHandler.ListUsersHandler).Methods("GET")
+	public.HandleFunc("/api/users/logout", userHandler.LogoutHandler).Methods("POST") // ?all=true logs out everywhere
+	public.HandleFunc("/api/users/login", userHandler.LoginHandler).Methods("POST")
+	public.HandleFunc("/api/users/login/2fa", twoFactorHandler.LoginTwoFactorHandler).Methods("POST") // second step for users with 2FA
+	public.HandleFunc("/api/users/check-auth", userHandler.CheckAuth)
+	authed.HandleFunc("/api/users/auth-update", userHandler.UpdateAuth).Methods("PUT")
+	authed.HandleFunc("/api/users/list", userHandler.ListUsersHandler).Methods("GET")
+	// Users to suggest after typing @ in posts, comments and chat messages, ?q=&limit=
+	authed.HandleFunc("/api/users/mentions", mentionHandler.GetMentionSuggestionsHandler).Methods("GET")
+	// Active sessions of the user, one per logged in device
+	authed.HandleFunc("/api/users/sessions", userHandler.GetActiveSessionsHandler).Methods("GET")
+	authed.HandleFunc("/api/users/sessions", userHandler.RevokeOtherSessionsHandler).Methods("DELETE")
+	authed.HandleFunc("/api/users/sessions/{id}", userHandler.RevokeSessionHandler).Methods("DELETE")
+	// Password reset and email verification
+	public.HandleFunc("/api/users/password/forgot", accountHandler.ForgotPasswordHandler).Methods("POST")
+	public.HandleFunc("/api/users/password/reset", accountHandler.ResetPasswordHandler).Methods("POST")
+	public.HandleFunc("/api/users/verify-email", accountHandler.VerifyEmailLinkHandler).Methods("GET") // link in the verification email
+	public.HandleFunc("/api/users/verify-email", accountHandler.VerifyEmailHandler).Methods("POST")
+	authed.HandleFunc("/api/users/verify-email/resend", accountHandler.ResendVerificationEmailHandler).Methods("POST")
+	// Password and email change, both need the current password
+	authed.HandleFunc("/api/users/password", accountHandler.ChangePasswordHandler).Methods("PUT")
+	authed.HandleFunc("/api/users/email", accountHandler.ChangeEmailHandler).Methods("PUT")
+	// Two-factor authentication (TOTP) with recovery codes
+	authed.HandleFunc("/api/users/2fa", twoFactorHandler.GetTwoFactorStatusHandler).Methods("GET")
+	authed.HandleFunc("/api/users/2fa/totp/setup", twoFactorHandler.SetupTOTPHandler).Methods("POST")
+	authed.HandleFunc("/api/users/2fa/totp/verify", twoFactorHandler.VerifyTOTPHandler).Methods("POST")
+	authed.HandleFunc("/api/users/2fa/totp/disable", twoFactorHandler.DisableTOTPHandler).Methods("POST")
+	authed.HandleFunc("/api/users/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodesHandler).Methods("POST")
+	// Passkeys (WebAuthn), login without a password
+	passkeyHandler := handler.NewPasskeyHandler(passkeyRepository, userRepository, sessionManager, cfg.WebAuthn)
+	public.HandleFunc("/api/users/passkeys/login/begin", passkeyHandler.BeginLoginHandler).Methods("POST")
+	public.HandleFunc("/api/users/passkeys/login/finish", passkeyHandler.FinishLoginHandler).Methods("POST")
+	authed.HandleFunc("/api/users/passkeys/register/begin", passkeyHandler.BeginRegistrationHandler).Methods("POST")
+	authed.HandleFunc("/api/users/passkeys/register/finish", passkeyHandler.FinishRegistrationHandler).Methods("POST")
+	authed.HandleFunc("/api/users/passkeys", passkeyHandler.GetPasskeysHandler).Methods("GET")
+	authed.HandleFunc("/api/users/passkeys/{id}", passkeyHandler.DeletePasskeyHandler).Methods("DELETE")
+	// Login with external OpenID Connect identity providers, the browser is redirected to the provider and back
+	oidcHandler := handler.NewOIDCHandler(identityRepository, userRepository, sessionManager, accountHandler, twoFactorHandler, notificationHandler, inviteHandler, cfg.App, cfg.OIDC)
+	public.HandleFunc("/api/users/oidc/providers", oidcHandler.GetProvidersHandler).Methods("GET")
+	public.HandleFunc("/api/users/oidc/{provider}/login", oidcHandler.LoginHandler).Methods("GET") // ?remember_me=true
+	public.HandleFunc("/api/users/oidc/{provider}/callback", oidcHandler.CallbackHandler).Methods("GET")
+	authed.HandleFunc("/api/users/oidc/{provider}/link", oidcHandler.LinkHandler).Methods("GET")
+	authed.HandleFunc("/api/users/identities", oidcHandler.GetIdentitiesHandler).Methods("GET")
+	authed.HandleFunc("/api/users/identities/{id}", oidcHandler.DeleteIdentityHandler).Methods("DELETE")
+	// Personal access tokens for scripts and bots
+	personalTokenHandler := handler.NewPersonalTokenHandler(personalTokenRepository, notificationHandler)
+	authed.HandleFunc("/api/users/tokens", personalTokenHandler.GetTokensHandler).Methods("GET")
+	authed.HandleFunc("/api/users/tokens", personalTokenHandler.CreateTokenHandler).Methods("POST")
+	authed.HandleFunc("/api/users/tokens/{id}", personalTokenHandler.DeleteTokenHandler).Methods("DELETE")
+	// OAuth 2.0 authorization server for third-party apps, the token and revocation endpoints are called by the apps
+	oauthHandler := handler.NewOAuthHandler(oauthRepository, notificationHandler, cfg.OAuth)
+	authed.HandleFunc("/api/oauth/clients", oauthHandler.GetClientsHandler).Methods("GET")
+	authed.HandleFunc("/api/oauth/clients", oauthHandler.CreateClientHandler).Methods("POST")
+	authed.HandleFunc("/api/oauth/clients/{id}", oauthHandler.DeleteClientHandler).Methods("DELETE")
+	authed.HandleFunc("/api/oauth/authorize", oauthHandler.GetAuthorizeHandler).Methods("GET") // consent screen
+	authed.HandleFunc("/api/oauth/authorize", oauthHandler.AuthorizeHandler).Methods("POST")
+	public.HandleFunc("/api/oauth/token", oauthHandler.TokenHandler).Methods("POST")
+	public.HandleFunc("/api/oauth/revoke", oauthHandler.RevokeHandler).Methods("POST")
+	authed.HandleFunc("/api/oauth/authorizations", oauthHandler.GetAuthorizationsHandler).Methods("GET")
+	authed.HandleFunc("/api/oauth/authorizations/{clientId}", oauthHandler.DeleteAuthorizationHandler).Methods("DELETE")
+	admin.HandleFunc("/api/admin/users/{id}/sessions", userHandler.RevokeUserSessionsHandler).Methods("DELETE")
+	// Accounts and IP addresses locked after too many failed logins
+	admin.HandleFunc("/api/admin/lockouts", userHandler.GetLockoutsHandler).Methods("GET")
+	admin.HandleFunc("/api/admin/invites", inviteHandler.GetAllInvitesHandler).Methods("GET")
+	admin.HandleFunc("/api/admin/users/{id}/lockout", userHandler.UnlockUserHandler).Methods("DELETE")
 
 	// Posts
-	postHandler := handler.NewPostHandler(postRepository, sessionRepository, friendsRepository, groupMemberRepository, userRepository, voteHandler)
-	mux.HandleFunc("/posts", postHandler.GetAllPostsHandler).Methods("GET") // Main feed, all public posts + user groups posts
-	mux.HandleFunc("/post", postHandler.CreatePostHandler).Methods("POST")
-	// mux.HandleFunc("/post/{id}", handler.GetPostByIDHandler).Methods("GET")
-	mux.HandleFunc("/post/{id}", postHandler.EditPostHandler).Methods("PUT")      // Edit a post
-	mux.HandleFunc("/post/{id}", postHandler.DeletePostHandler).Methods("DELETE") // Delete a post
-	mux.HandleFunc("/groups/{groupId}/posts", postHandler.GetPostsByGroupIDHandler).Methods("GET")
+	postHandler := handler.NewPostHandler(postRepository, sessionRepository, friendsRepository, groupMemberRepository, userRepository, reactionHandler, mentionHandler, audienceListRepository, feedRepository, ranking.NewWeightedRanker(cfg.Feed.RankWeights), cfg.Feed)
+	authed.Handle("/posts", auth.Scoped(model.ScopePostsRead, postHandler.GetAllPostsHandler)).Methods("GET") // Main feed, all public posts + user groups posts, ?sort=ranked for the ranked feed
+	verified.Handle("/post", auth.Scoped(model.ScopePostsWrite, postHandler.CreatePostHandler)).Methods("POST")
+	// authed.HandleFunc("/post/{id}", handler.GetPostByIDHandler).Methods("GET")
+	verified.Handle("/post/{id}", auth.Scoped(model.ScopePostsWrite, postHandler.EditPostHandler)).Methods("PUT")      // Edit a post
+	verified.Handle("/post/{id}", auth.Scoped(model.ScopePostsWrite, postHandler.DeletePostHandler)).Methods("DELETE") // Delete a post
+	authed.Handle("/groups/{groupId}/posts", auth.Scoped(model.ScopePostsRead, postHandler.GetPostsByGroupIDHandler)).Methods("GET")
+	// Who can see a custom post, only for its author
+	authed.Handle("/post/{id}/audience", auth.Scoped(model.ScopePostsRead, postHandler.GetPostAudienceHandler)).Methods("GET")
+	// Named lists of friends to pick as audience of custom posts
+	audienceListHandler := handler.NewAudienceListHandler(audienceListRepository, friendsRepository)
+	authed.Handle("/audience-lists", auth.Scoped(model.ScopePostsRead, audienceListHandler.GetAudienceListsHandler)).Methods("GET")
+	verified.Handle("/audience-lists", auth.Scoped(model.ScopePostsWrite, audienceListHandler.CreateAudienceListHandler)).Methods("POST")
+	authed.Handle("/audience-lists/{id}", auth.Scoped(model.ScopePostsRead, audienceListHandler.GetAudienceListHandler)).Methods("GET")
+	verified.Handle("/audience-lists/{id}", auth.Scoped(model.ScopePostsWrite, audienceListHandler.UpdateAudienceListHandler)).Methods("PUT")
+	verified.Handle("/audience-lists/{id}", auth.Scoped(model.ScopePostsWrite, audienceListHandler.DeleteAudienceListHandler)).Methods("DELETE")
 
 	// Profile
-	mux.HandleFunc("/profile/users/{id}", userHandler.GetUserProfileByIDHandler).Methods("GET")
-	mux.HandleFunc("/profile/users/{id}", userHandler.EditUserProfileHandler).Methods("PUT")
+	authed.HandleFunc("/profile/users/{id}", userHandler.GetUserProfileByIDHandler).Methods("GET")
+	authed.HandleFunc("/profile/users/{id}", userHandler.EditUserProfileHandler).Methods("PATCH") // {id} is "me" or the own user ID
 	// Profile feed, all posts by user
-	mux.HandleFunc("/profile/posts/{id}", postHandler.GetAllUserPostsHandler).Methods("GET")
+	authed.Handle("/profile/posts/{id}", auth.Scoped(model.ScopePostsRead, postHandler.GetAllUserPostsHandler)).Methods("GET")
 
 	// Comments
-	commentHandler := handler.NewCommentHandler(commentRepository, sessionRepository, notificationHandler, postRepository, userRepository, voteHandler)
-	mux.HandleFunc("/post/{id}/comments", commentHandler.GetCommentsByPostID).Methods("GET")
-	mux.HandleFunc("/post/{id}/comment", commentHandler.CreateCommentHandler).Methods("POST")
-	mux.HandleFunc("/post/comment", commentHandler.CreateCommentHandler).Methods("POST")
-	mux.HandleFunc("/post/comment/{id}", commentHandler.DeleteCommentHandler).Methods("DELETE")
+	commentHandler := handler.NewCommentHandler(commentRepository, sessionRepository, notificationHandler, postRepository, userRepository, reactionHandler, mentionHandler, cfg.Comments)
+	// Comments in thread order, ?view=collapsed only returns one level and ?parent= the replies to a comment
+	authed.Handle("/post/{id}/comments", auth.Scoped(model.ScopePostsRead, commentHandler.GetCommentsByPostID)).Methods("GET")
+	verified.Handle("/post/{id}/comment", auth.Scoped(model.ScopePostsWrite, commentHandler.CreateCommentHandler)).Methods("POST") // parent_id replies to a comment
+	verified.Handle("/post/comment", auth.Scoped(model.ScopePostsWrite, commentHandler.CreateCommentHandler)).Methods("POST")
+	verified.Handle("/post/comment/{id}", auth.Scoped(model.ScopePostsWrite, commentHandler.DeleteCommentHandler)).Methods("DELETE")
+	// Edit a comment within COMMENT_EDIT_WINDOW, the previous versions are kept as revisions
+	verified.Handle("/post/comment/{id}", auth.Scoped(model.ScopePostsWrite, commentHandler.EditCommentHandler)).Methods("PUT")
+	authed.Handle("/post/comment/{id}/revisions", auth.Scoped(model.ScopePostsRead, commentHandler.GetCommentRevisionsHandler)).Methods("GET")
+
+	// Reactions to comments and posts ... the getPosts and getComments methods return the reaction counts with each post/comment
+	authed.HandleFunc("/reactions", reactionHandler.GetReactionTypesHandler).Methods("GET")
+	verified.Handle("/reactions", auth.Scoped(model.ScopePostsWrite, reactionHandler.ReactHandler)).Methods("POST")
+	// Likes & dislikes of older clients, {"action": "like"} works like {"reaction": "like"}
+	verified.Handle("/vote", auth.Scoped(model.ScopePostsWrite, reactionHandler.ReactHandler)).Methods("POST")
+
+	// Hashtags of posts and comments: the posts of a tag (?cursor=&limit=) and the tags trending in public posts (?window=&limit=)
+	tagHandler := handler.NewTagHandler(tagRepository, cfg.Tags)
+	authed.Handle("/tags/trending", auth.Scoped(model.ScopePostsRead, tagHandler.GetTrendingTagsHandler)).Methods("GET")
+	authed.Handle("/tags/{tag}/posts", auth.Scoped(model.ScopePostsRead, postHandler.GetPostsByTagHandler)).Methods("GET")
 
-	// Likes & dislikes for comments and posts ... the getPosts and getComments methods return the number of likes and dislikes with each post/comment
-	mux.HandleFunc("/vote", voteHandler.VotePostOrCommentHandler).Methods("POST")
+	// Full-text search of posts, comments, users, groups and events, ?q=&type=&limit=&offset=
+	searchHandler := handler.NewSearchHandler(searchRepository)
+	authed.HandleFunc("/search", searchHandler.SearchHandler).Methods("GET")
 
 	// Groups
 	groupHandler := handler.NewGroupHandler(groupRepository, sessionRepository, groupMemberRepository, notificationHandler, userRepository, friendsRepository)
-	mux.HandleFunc("/groups", groupHandler.GetAllGroupsHandler).Methods("GET")
-	mux.HandleFunc("/groups", groupHandler.CreateGroupHandler).Methods("POST")
-	mux.HandleFunc("/groups/{id}", groupHandler.GetGroupByIDHandler).Methods("GET")
-	mux.HandleFunc("/groups/{id}", groupHandler.EditGroupHandler).Methods("PUT")
-	mux.HandleFunc("/groups/{id}", groupHandler.DeleteGroupHandler).Methods("DELETE")
+	authed.HandleFunc("/groups", groupHandler.GetAllGroupsHandler).Methods("GET")
+	verified.HandleFunc("/groups", groupHandler.CreateGroupHandler).Methods("POST")
+	authed.HandleFunc("/groups/{id}", groupHandler.GetGroupByIDHandler).Methods("GET")
+	verified.HandleFunc("/groups/{id}", groupHandler.EditGroupHandler).Methods("PUT")
+	verified.HandleFunc("/groups/{id}", groupHandler.DeleteGroupHandler).Methods("DELETE")
 
 	// Group invitations & requests
 	groupMemberHandler := handler.NewGroupMemberHandler(groupMemberRepository, invitationRepository, sessionRepository, notificationHandler, groupRepository, userRepository)
 	// for all members
-	mux.HandleFunc("/invitations/invite/{groupId}/{userId}", groupMemberHandler.InviteGroupMemberHandler).Methods("POST")
+	verified.HandleFunc("/invitations/invite/{groupId}/{userId}", groupMemberHandler.InviteGroupMemberHandler).Methods("POST")
 	// for group member
-	mux.HandleFunc("/invitations", groupMemberHandler.GetAllGroupInvitationsHandler).Methods("GET")
-	mux.HandleFunc("/invitations/{groupId}", groupMemberHandler.GetGroupInvitationByIDHandler).Methods("GET")
-	mux.HandleFunc("/invitations/decline/{groupId}", groupMemberHandler.DeclineGroupInvitationHandler).Methods("POST")
-	mux.HandleFunc("/invitations/accept/{groupId}", groupMemberHandler.AcceptGroupInvitationHandler).Methods("POST")
-	mux.HandleFunc("/invitations/request/{groupId}", groupMemberHandler.RequestGroupMembershipHandler).Methods("POST")
+	authed.HandleFunc("/invitations", groupMemberHandler.GetAllGroupInvitationsHandler).Methods("GET")
+	authed.HandleFunc("/invitations/{groupId}", groupMemberHandler.GetGroupInvitationByIDHandler).Methods("GET")
+	verified.HandleFunc("/invitations/decline/{groupId}", groupMemberHandler.DeclineGroupInvitationHandler).Methods("POST")
+	verified.HandleFunc("/invitations/accept/{groupId}", groupMemberHandler.AcceptGroupInvitationHandler).Methods("POST")
+	verified.HandleFunc("/invitations/request/{groupId}", groupMemberHandler.RequestGroupMembershipHandler).Methods("POST")
 	// for group owner
-	mux.HandleFunc("/groups/{groupId}/non-members", groupMemberHandler.GetAllNonMembersHandler).Methods("GET")
-	mux.HandleFunc("/groups/{groupId}/members", groupMemberHandler.GetAllMembersHandler).Methods("GET")
-	mux.HandleFunc("/groups/{groupId}/members/{userId}", groupMemberHandler.RemoveMemberHandler).Methods("DELETE")
-	mux.HandleFunc("/invitations/approve/{groupId}/{userId}", groupMemberHandler.ApproveGroupMembershipHandler).Methods("PUT")
-	mux.HandleFunc("/invitations/decline/{groupId}/{userId}", groupMemberHandler.DeclineGroupMembershipHandler).Methods("PUT")
-	mux.HandleFunc("/groups/{groupId}/requests", groupMemberHandler.GetAllGroupRequestsHandler).Methods("GET")
+	authed.HandleFunc("/groups/{groupId}/non-members", groupMemberHandler.GetAllNonMembersHandler).Methods("GET")
+	authed.HandleFunc("/groups/{groupId}/members", groupMemberHandler.GetAllMembersHandler).Methods("GET")
+	verified.HandleFunc("/groups/{groupId}/members/{userId}", groupMemberHandler.RemoveMemberHandler).Methods("DELETE")
+	verified.HandleFunc("/invitations/approve/{groupId}/{userId}", groupMemberHandler.ApproveGroupMembershipHandler).Methods("PUT")
+	verified.HandleFunc("/invitations/decline/{groupId}/{userId}", groupMemberHandler.DeclineGroupMembershipHandler).Methods("PUT")
+	authed.HandleFunc("/groups/{groupId}/requests", groupMemberHandler.GetAllGroupRequestsHandler).Methods("GET")
 
 	// Events
 	eventHandler := handler.NewEventHandler(eventRepository, sessionRepository, groupMemberRepository, userRepository, notificationHandler, groupRepository)
-	mux.HandleFunc("/events/group/{groupId}", eventHandler.GetAllGroupEventsHandler).Methods("GET")
-	mux.HandleFunc("/events", eventHandler.CreateEventHandler).Methods("POST")
-	mux.HandleFunc("/events/me", eventHandler.GetAllUserEvents).Methods("GET")
-	mux.HandleFunc("/events/{id}", eventHandler.EditEventHandler).Methods("PUT")
-	mux.HandleFunc("/events/{id}", eventHandler.DeleteEventHandler).Methods("DELETE")
-	mux.HandleFunc("/events/{id}", eventHandler.GetEventsByGroupIDHandler).Methods("GET")
-	mux.HandleFunc("/events/{eventId}/{status}", eventHandler.AddOrUpdateAttendanceHandler).Methods("PUT")
-	mux.HandleFunc("/events/attendance/{eventId}", eventHandler.GetAttendanceByEventIDHandler).Methods("GET")
+	authed.Handle("/events/group/{groupId}", auth.Scoped(model.ScopeEvents, eventHandler.GetAllGroupEventsHandler)).Methods("GET")
+	verified.Handle("/events", auth.Scoped(model.ScopeEvents, eventHandler.CreateEventHandler)).Methods("POST")
+	authed.Handle("/events/me", auth.Scoped(model.ScopeEvents, eventHandler.GetAllUserEvents)).Methods("GET")
+	verified.Handle("/events/{id}", auth.Scoped(model.ScopeEvents, eventHandler.EditEventHandler)).Methods("PUT")
+	verified.Handle("/events/{id}", auth.Scoped(model.ScopeEvents, eventHandler.DeleteEventHandler)).Methods("DELETE")
+	authed.Handle("/events/{id}", auth.Scoped(model.ScopeEvents, eventHandler.GetEventsByGroupIDHandler)).Methods("GET")
+	verified.Handle("/events/{eventId}/{status}", auth.Scoped(model.ScopeEvents, eventHandler.AddOrUpdateAttendanceHandler)).Methods("PUT")
+	authed.Handle("/events/attendance/{eventId}", auth.Scoped(model.ScopeEvents, eventHandler.GetAttendanceByEventIDHandler)).Methods("GET")
 
 	// Notifications
-	mux.HandleFunc("/notifications", notificationHandler.GetAllNotificationsForUserHandler).Methods("GET")
-	mux.HandleFunc("/notifications/{id}", notificationHandler.GetNotificationByIDHandler).Methods("GET")
-	mux.HandleFunc("/notifications/{id}", notificationHandler.MarkNotificationAsReadHandler).Methods("PUT")
-	mux.HandleFunc("/notifications/{id}", notificationHandler.DeleteNotificationHandler).Methods("DELETE")
+	authed.HandleFunc("/notifications", notificationHandler.GetAllNotificationsForUserHandler).Methods("GET")
+	authed.HandleFunc("/notifications/{id}", notificationHandler.GetNotificationByIDHandler).Methods("GET")
+	authed.HandleFunc("/notifications/{id}", notificationHandler.MarkNotificationAsReadHandler).Methods("PUT")
+	authed.HandleFunc("/notifications/{id}", notificationHandler.DeleteNotificationHandler).Methods("DELETE")
 
 	// Friends
 	friendHandler := handler.NewFriendHandler(friendsRepository, sessionRepository, notificationHandler, userRepository)
-	mux.HandleFunc("/friends/requests", friendHandler.GetFriendRequestsHandler).Methods("GET")
-	mux.HandleFunc("/friends/request/{id}", friendHandler.SendFriendRequestHandler).Methods("POST")
-	mux.HandleFunc("/friends/accept/{id}", friendHandler.AcceptFriendRequestHandler).Methods("POST")
-	mux.HandleFunc("/friends/decline/{id}", friendHandler.DeclineFriendRequestHandler).Methods("POST")
-	mux.HandleFunc("/friends/check/{id}", friendHandler.CheckFriendStatusHandler).Methods("GET")
+	authed.HandleFunc("/friends/requests", friendHandler.GetFriendRequestsHandler).Methods("GET")
+	verified.HandleFunc("/friends/request/{id}", friendHandler.SendFriendRequestHandler).Methods("POST")
+	verified.HandleFunc("/friends/accept/{id}", friendHandler.AcceptFriendRequestHandler).Methods("POST")
+	verified.HandleFunc("/friends/decline/{id}", friendHandler.DeclineFriendRequestHandler).Methods("POST")
+	authed.HandleFunc("/friends/check/{id}", friendHandler.CheckFriendStatusHandler).Methods("GET")
 
-	mux.HandleFunc("/friends/{id}", friendHandler.GetFriendsHandler).Methods("GET")
+	authed.HandleFunc("/friends/{id}", friendHandler.GetFriendsHandler).Methods("GET")
 
 	// route to serve images
 	http.HandleFunc("/images/", func(w http.ResponseWriter, r *http.Request) {
@@ -131,6 +267,7 @@ func Router(mux *mux.Router, db *sql.DB) {
 	})
 
 	go hub.Run()
+	go handler.RunSessionJanitor(sessionRepository, cfg.Session.JanitorInterval)
 
 	address := os.Getenv("NEXT_PUBLIC_URL")
 	port := os.Getenv("NEXT_PUBLIC_HTTPS_PORT")
@@ -144,10 +281,10 @@ func Router(mux *mux.Router, db *sql.DB) {
 
 	// CORS
 	corsOptions := cors.New(cors.Options{
-		AllowedOrigins:   []string{address + ":" + port},                      // Replace with your frontend's origin
-		AllowCredentials: true,                                                // Important for cookies, authorization headers with HTTPS
-		AllowedHeaders:   []string{"Authorization", "Content-Type"},           // You can adjust this based on your needs
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}, // Adjust the methods based on your requirements
+		AllowedOrigins:   []string{address + ":" + port},                               // Replace with your frontend's origin
+		AllowCredentials: true,                                                         // Important for cookies, authorization headers with HTTPS
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},                    // You can adjust this based on your needs
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}, // Adjust the methods based on your requirements
 		// You can include other settings like ExposedHeaders, MaxAge, etc., according to your needs
 	})
 	mux_cors := corsOptions.Handler(mux)
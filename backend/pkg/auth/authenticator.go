@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"backend/pkg/config"
+	"backend/pkg/model"
+	"backend/pkg/repository"
+	"backend/util"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoCredentials  = errors.New("authentication required")
+	ErrInvalidSession = errors.New("invalid session")
+	ErrSessionExpired = errors.New("session expired")
+	ErrInvalidToken   = errors.New("invalid token")
+	ErrTokenExpired   = errors.New("token expired")
+)
+
+const RoleAdmin = "admin"
+
+// Prefixes of the tokens issued by the OAuth authorization server, they tell access tokens apart from personal access tokens
+const (
+	OAuthAccessTokenPrefix  = "isa_"
+	OAuthRefreshTokenPrefix = "isr_"
+)
+
+// Authenticator resolves the user behind a request from the session cookie, a personal access token
+// or an OAuth access token.
+type Authenticator struct {
+	sessionRepo *repository.SessionRepository
+	userRepo    *repository.UserRepository
+	tokenRepo   *repository.PersonalTokenRepository
+	oauthRepo   *repository.OAuthRepository
+	sessions    *SessionManager
+	// requireEmailVerification makes RequireVerifiedEmail reject users with an unverified email address
+	requireEmailVerification bool
+}
+
+func NewAuthenticator(sessionRepo *repository.SessionRepository, userRepo *repository.UserRepository, tokenRepo *repository.PersonalTokenRepository, oauthRepo *repository.OAuthRepository, sessions *SessionManager, account config.Account) *Authenticator {
+	return &Authenticator{sessionRepo: sessionRepo, userRepo: userRepo, tokenRepo: tokenRepo, oauthRepo: oauthRepo, sessions: sessions, requireEmailVerification: account.RequireEmailVerification}
+}
+
+// Authenticate validates the session token or the bearer token of the request and returns the identity of its owner.
+// Expired sessions are removed from the database right away.
+func (a *Authenticator) Authenticate(r *http.Request) (model.Identity, error) {
+	identity, _, err := a.authenticateRequest(r)
+	return identity, err
+}
+
+// authenticateRequest uses the bearer token if the request has one and the session cookie otherwise.
+// The returned session is nil for token requests.
+func (a *Authenticator) authenticateRequest(r *http.Request) (model.Identity, *model.Session, error) {
+	if token, ok := BearerToken(r); ok {
+		if strings.HasPrefix(token, OAuthAccessTokenPrefix) {
+			identity, err := a.authenticateOAuthToken(token)
+			return identity, nil, err
+		}
+		identity, err := a.authenticateToken(token)
+		return identity, nil, err
+	}
+	return a.authenticateSession(r)
+}
+
+func (a *Authenticator) authenticateSession(r *http.Request) (model.Identity, *model.Session, error) {
+	sessionToken := util.GetSessionToken(r)
+	if sessionToken == "" {
+		return model.Identity{}, nil, ErrNoCredentials
+	}
+
+	session, err := a.sessionRepo.GetSessionBySessionToken(sessionToken)
+	if err == sql.ErrNoRows {
+		return model.Identity{}, nil, ErrInvalidSession
+	} else if err != nil {
+		return model.Identity{}, nil, err
+	}
+	now := time.Now()
+	if now.After(session.ExpiresAt) || now.After(session.AbsoluteExpiresAt) {
+		a.sessionRepo.DeleteSessionByToken(sessionToken)
+		return model.Identity{}, nil, ErrSessionExpired
+	}
+
+	role, emailVerified, err := a.userRepo.GetUserAccessByID(session.UserID)
+	if err == sql.ErrNoRows {
+		return model.Identity{}, nil, ErrInvalidSession
+	} else if err != nil {
+		return model.Identity{}, nil, err
+	}
+
+	identity := model.Identity{
+		UserID:        session.UserID,
+		SessionID:     session.Id,
+		Role:          role,
+		EmailVerified: emailVerified,
+	}
+	return identity, &session, nil
+}
+
+// authenticateToken looks up a personal access token. The identity carries the scopes of the token.
+func (a *Authenticator) authenticateToken(token string) (model.Identity, error) {
+	personalToken, err := a.tokenRepo.GetTokenByHash(HashToken(token))
+	if err == sql.ErrNoRows {
+		return model.Identity{}, ErrInvalidToken
+	} else if err != nil {
+		return model.Identity{}, err
+	}
+	if personalToken.ExpiresAt != nil && time.Now().After(*personalToken.ExpiresAt) {
+		return model.Identity{}, ErrTokenExpired
+	}
+
+	role, emailVerified, err := a.userRepo.GetUserAccessByID(personalToken.UserID)
+	if err == sql.ErrNoRows {
+		return model.Identity{}, ErrInvalidToken
+	} else if err != nil {
+		return model.Identity{}, err
+	}
+	if err := a.tokenRepo.TouchToken(personalToken.Id); err != nil {
+		log.Println("Error updating token usage: ", err)
+	}
+
+	identity := model.Identity{
+		UserID:        personalToken.UserID,
+		Role:          role,
+		EmailVerified: emailVerified,
+		TokenID:       personalToken.Id,
+		Scopes:        personalToken.Scopes,
+	}
+	return identity, nil
+}
+
+// authenticateOAuthToken looks up an access token issued to a third-party app by the OAuth authorization server.
+func (a *Authenticator) authenticateOAuthToken(token string) (model.Identity, error) {
+	oauthToken, err := a.oauthRepo.GetTokenByAccessHash(HashToken(token))
+	if err == sql.ErrNoRows {
+		return model.Identity{}, ErrInvalidToken
+	} else if err != nil {
+		return model.Identity{}, err
+	}
+	if time.Now().After(oauthToken.AccessExpiresAt) {
+		return model.Identity{}, ErrTokenExpired
+	}
+	client, err := a.oauthRepo.GetClientByID(oauthToken.ClientID)
+	if err == sql.ErrNoRows {
+		return model.Identity{}, ErrInvalidToken
+	} else if err != nil {
+		return model.Identity{}, err
+	}
+
+	role, emailVerified, err := a.userRepo.GetUserAccessByID(oauthToken.UserID)
+	if err == sql.ErrNoRows {
+		return model.Identity{}, ErrInvalidToken
+	} else if err != nil {
+		return model.Identity{}, err
+	}
+
+	identity := model.Identity{
+		UserID:        oauthToken.UserID,
+		Role:          role,
+		EmailVerified: emailVerified,
+		TokenID:       oauthToken.Id,
+		Scopes:        oauthToken.Scopes,
+		ClientID:      client.ClientID,
+	}
+	return identity, nil
+}
+
+// BearerToken returns the token of an Authorization: Bearer header.
+func BearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
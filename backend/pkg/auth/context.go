@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"backend/pkg/model"
+	"context"
+	"net/http"
+)
+
+type contextKey string
+
+const identityKey contextKey = "identity"
+
+// WithIdentity returns a copy of the context carrying the authenticated user.
+func WithIdentity(ctx context.Context, identity model.Identity) context.Context {
+	return context.WithValue(ctx, identityKey, identity)
+}
+
+// IdentityFromContext returns the authenticated user stored in the context by the auth middleware.
+func IdentityFromContext(ctx context.Context) (model.Identity, bool) {
+	identity, ok := ctx.Value(identityKey).(model.Identity)
+	return identity, ok
+}
+
+// UserIDFromRequest returns the ID of the authenticated user making the request.
+// It fails with ErrNoCredentials on routes that are not behind the auth middleware.
+func UserIDFromRequest(r *http.Request) (int, error) {
+	identity, ok := IdentityFromContext(r.Context())
+	if !ok {
+		return 0, ErrNoCredentials
+	}
+	return identity.UserID, nil
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers (RFC 9053) of the public keys passkeys can be registered with.
+const (
+	coseAlgES256 = -7
+	coseAlgEdDSA = -8
+	coseAlgRS256 = -257
+)
+
+// COSE key parameters
+const (
+	coseKeyKty = 1
+	coseKeyAlg = 3
+	coseKeyCrv = -1 // n for RSA keys
+	coseKeyX   = -2 // e for RSA keys
+	coseKeyY   = -3
+)
+
+var errUnsupportedKey = errors.New("unsupported public key algorithm")
+
+// parseCOSEKey turns a CBOR encoded COSE public key into a crypto public key and returns its algorithm.
+func parseCOSEKey(data []byte) (crypto.PublicKey, int64, error) {
+	item, _, err := decodeCBOR(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	key, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, 0, errors.New("public key is not a COSE key")
+	}
+	alg, _ := key[int64(coseKeyAlg)].(int64)
+	kty, _ := key[int64(coseKeyKty)].(int64)
+
+	switch alg {
+	case coseAlgES256:
+		crv, _ := key[int64(coseKeyCrv)].(int64)
+		x, _ := key[int64(coseKeyX)].([]byte)
+		y, _ := key[int64(coseKeyY)].([]byte)
+		if kty != 2 || crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("invalid ES256 public key")
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, 0, errors.New("ES256 public key is not on the curve")
+		}
+		return pub, alg, nil
+	case coseAlgEdDSA:
+		crv, _ := key[int64(coseKeyCrv)].(int64)
+		x, _ := key[int64(coseKeyX)].([]byte)
+		if kty != 1 || crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("invalid EdDSA public key")
+		}
+		return ed25519.PublicKey(x), alg, nil
+	case coseAlgRS256:
+		n, _ := key[int64(coseKeyCrv)].([]byte)
+		e, _ := key[int64(coseKeyX)].([]byte)
+		if kty != 3 || len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errors.New("invalid RS256 public key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, alg, nil
+	default:
+		return nil, 0, fmt.Errorf("%w %d", errUnsupportedKey, alg)
+	}
+}
+
+// verifyCOSESignature checks a signature made with the private key belonging to the COSE public key.
+func verifyCOSESignature(coseKey, message, signature []byte) error {
+	pub, alg, err := parseCOSEKey(coseKey)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(message)
+	switch alg {
+	case coseAlgES256:
+		if !ecdsa.VerifyASN1(pub.(*ecdsa.PublicKey), digest[:], signature) {
+			return errors.New("invalid signature")
+		}
+	case coseAlgEdDSA:
+		if !ed25519.Verify(pub.(ed25519.PublicKey), message, signature) {
+			return errors.New("invalid signature")
+		}
+	case coseAlgRS256:
+		if err := rsa.VerifyPKCS1v15(pub.(*rsa.PublicKey), crypto.SHA256, digest[:], signature); err != nil {
+			return errors.New("invalid signature")
+		}
+	}
+	return nil
+}
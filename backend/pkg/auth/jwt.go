@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// jwk is a public key from a JSON Web Key Set (RFC 7517), only the fields of RSA and EC keys.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jwtHeader is the part of the JOSE header needed to pick the key.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+var errJWTMalformed = errors.New("jwt: malformed token")
+
+// publicKey turns the JWK into a crypto public key.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+		e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+		if err1 != nil || err2 != nil || len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("jwt: invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+		y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+		if err1 != nil || err2 != nil || k.Crv != "P-256" || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("jwt: invalid EC key")
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("jwt: EC key is not on the curve")
+		}
+		return pub, nil
+	default:
+		return nil, fmt.Errorf("jwt: unsupported key type %q", k.Kty)
+	}
+}
+
+// splitJWT decodes the header of a compact serialized JWS and returns it with the other parts.
+func splitJWT(token string) (header jwtHeader, signingInput string, payload, signature []byte, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return jwtHeader{}, "", nil, nil, errJWTMalformed
+	}
+	rawHeader, err1 := base64.RawURLEncoding.DecodeString(parts[0])
+	payload, err2 := base64.RawURLEncoding.DecodeString(parts[1])
+	signature, err3 := base64.RawURLEncoding.DecodeString(parts[2])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return jwtHeader{}, "", nil, nil, errJWTMalformed
+	}
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return jwtHeader{}, "", nil, nil, errJWTMalformed
+	}
+	return header, parts[0] + "." + parts[1], payload, signature, nil
+}
+
+// verifyJWTSignature checks the signature of a JWS with the given algorithm. Only asymmetric algorithms
+// are accepted, "none" and the HMAC algorithms are rejected.
+func verifyJWTSignature(alg string, key crypto.PublicKey, signingInput string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signingInput))
+	switch alg {
+	case "RS256", "PS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("jwt: key doesn't match the algorithm")
+		}
+		var err error
+		if alg == "RS256" {
+			err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature)
+		} else {
+			err = rsa.VerifyPSS(pub, crypto.SHA256, digest[:], signature, nil)
+		}
+		if err != nil {
+			return errors.New("jwt: invalid signature")
+		}
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("jwt: key doesn't match the algorithm")
+		}
+		// JWS uses the raw r || s form, not ASN.1 like WebAuthn
+		if len(signature) != 64 {
+			return errors.New("jwt: invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return errors.New("jwt: invalid signature")
+		}
+	default:
+		return fmt.Errorf("jwt: unsupported algorithm %q", alg)
+	}
+	return nil
+}
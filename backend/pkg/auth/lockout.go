@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"backend/pkg/config"
+	"backend/pkg/model"
+	"backend/pkg/repository"
+	"strconv"
+	"time"
+)
+
+// LoginLimiter slows down password guessing. It counts failed logins per account and per IP address
+// and locks them with an exponential backoff once a threshold is reached.
+type LoginLimiter struct {
+	throttleRepo *repository.LoginThrottleRepository
+	config       config.Lockout
+}
+
+func NewLoginLimiter(throttleRepo *repository.LoginThrottleRepository, config config.Lockout) *LoginLimiter {
+	return &LoginLimiter{throttleRepo: throttleRepo, config: config}
+}
+
+// Check returns how long the account or the IP address is still locked, 0 if logins are allowed.
+// Pass a user ID of 0 or an empty IP address to check only the other one.
+func (l *LoginLimiter) Check(userID int, ip string) (time.Duration, error) {
+	var wait time.Duration
+	for _, throttle := range l.throttleKeys(userID, ip) {
+		current, err := l.throttleRepo.GetThrottle(throttle.Scope, throttle.Key)
+		if err != nil {
+			return 0, err
+		}
+		if current.LockedUntil != nil {
+			if remaining := time.Until(*current.LockedUntil); remaining > wait {
+				wait = remaining
+			}
+		}
+	}
+	return wait, nil
+}
+
+// Failure records a failed login. It returns the end of the account lockout if this failure locked the account,
+// so the owner can be notified, and a zero time otherwise.
+func (l *LoginLimiter) Failure(userID int, ip string) (time.Time, error) {
+	var accountLockedUntil time.Time
+	for _, throttle := range l.throttleKeys(userID, ip) {
+		now := time.Now()
+		failures, err := l.throttleRepo.AddFailure(throttle.Scope, throttle.Key, now, now.Add(-l.config.ResetAfter))
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		threshold := l.config.IPThreshold
+		if throttle.Scope == model.ThrottleScopeAccount {
+			threshold = l.config.AccountThreshold
+		}
+		if failures >= threshold {
+			lockedUntil := now.Add(l.lockoutDuration(failures - threshold))
+			if err := l.throttleRepo.Lock(throttle.Scope, throttle.Key, lockedUntil); err != nil {
+				return time.Time{}, err
+			}
+			if throttle.Scope == model.ThrottleScopeAccount {
+				accountLockedUntil = lockedUntil
+			}
+		}
+	}
+	return accountLockedUntil, nil
+}
+
+// Success forgets the failed logins of the account after a successful login.
+// The IP address counter is kept, so an attacker can't reset it by logging into an own account.
+func (l *LoginLimiter) Success(userID int) error {
+	_, err := l.throttleRepo.DeleteThrottle(model.ThrottleScopeAccount, strconv.Itoa(userID))
+	return err
+}
+
+// Unlock lifts the lockout of an account. It returns false if the account had no failed logins.
+func (l *LoginLimiter) Unlock(userID int) (bool, error) {
+	return l.throttleRepo.DeleteThrottle(model.ThrottleScopeAccount, strconv.Itoa(userID))
+}
+
+// ActiveLockouts returns the accounts and IP addresses that are locked right now.
+func (l *LoginLimiter) ActiveLockouts() ([]model.LoginThrottle, error) {
+	return l.throttleRepo.GetActiveLockouts()
+}
+
+// lockoutDuration doubles the base duration for every failure past the threshold, up to the maximum.
+func (l *LoginLimiter) lockoutDuration(failuresPastThreshold int) time.Duration {
+	duration := l.config.BaseDuration
+	for i := 0; i < failuresPastThreshold && duration < l.config.MaxDuration; i++ {
+		duration *= 2
+	}
+	if duration > l.config.MaxDuration {
+		duration = l.config.MaxDuration
+	}
+	return duration
+}
+
+func (l *LoginLimiter) throttleKeys(userID int, ip string) []model.LoginThrottle {
+	var keys []model.LoginThrottle
+	if userID != 0 {
+		keys = append(keys, model.LoginThrottle{Scope: model.ThrottleScopeAccount, Key: strconv.Itoa(userID)})
+	}
+	if ip != "" {
+		keys = append(keys, model.LoginThrottle{Scope: model.ThrottleScopeIP, Key: ip})
+	}
+	return keys
+}
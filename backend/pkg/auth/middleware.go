@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"backend/pkg/model"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// RequireAuthentication is a mux middleware that rejects requests without a valid session or personal access token.
+// The identity of the authenticated user is put into the request context, handlers read it with UserIDFromRequest.
+// Every accepted request with a session slides the expiration of the session forward.
+// Token requests only get through to routes registered with Scoped and a scope the token has.
+func (a *Authenticator) RequireAuthentication(next http.Handler) http.Handler {
+	return a.require(next, nil)
+}
+
+// RequireAdmin works like RequireAuthentication but only lets administrators through.
+func (a *Authenticator) RequireAdmin(next http.Handler) http.Handler {
+	return a.require(next, func(w http.ResponseWriter, identity model.Identity) bool {
+		if identity.Role != RoleAdmin {
+			WriteJSONError(w, http.StatusForbidden, "Admin privileges required")
+			return false
+		}
+		return true
+	})
+}
+
+// RequireVerifiedEmail works like RequireAuthentication but rejects users that haven't verified their email address yet,
+// unless email verification is turned off with REQUIRE_EMAIL_VERIFICATION=false.
+func (a *Authenticator) RequireVerifiedEmail(next http.Handler) http.Handler {
+	return a.require(next, func(w http.ResponseWriter, identity model.Identity) bool {
+		if a.requireEmailVerification && !identity.EmailVerified {
+			WriteJSONError(w, http.StatusForbidden, "Email address not verified")
+			return false
+		}
+		return true
+	})
+}
+
+// require authenticates the request and lets it through to next if allow (when given) agrees.
+// Mux passes the route handler as next, so the scope of routes registered with Scoped can be read from it.
+func (a *Authenticator) require(next http.Handler, allow func(http.ResponseWriter, model.Identity) bool) http.Handler {
+	scope := requiredScope(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, session, err := a.authenticateRequest(r)
+		if err != nil {
+			writeAuthError(w, err)
+			return
+		}
+		if !HasScope(identity, scope) {
+			writeScopeError(w, scope)
+			return
+		}
+		if allow != nil && !allow(w, identity) {
+			return
+		}
+		if session != nil {
+			if err := a.sessions.Renew(w, *session, false); err != nil {
+				log.Println("Error renewing session: ", err)
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
+	})
+}
+
+// scopedHandler is a route handler personal access tokens with the scope may call.
+type scopedHandler struct {
+	http.HandlerFunc
+	scope string
+}
+
+// Scoped registers a route for personal access tokens with the scope, for example
+// authed.Handle("/posts", auth.Scoped(model.ScopePostsRead, postHandler.GetAllPostsHandler)).
+// Routes registered without it only accept sessions.
+func Scoped(scope string, handler http.HandlerFunc) http.Handler {
+	return scopedHandler{HandlerFunc: handler, scope: scope}
+}
+
+func requiredScope(handler http.Handler) string {
+	if scoped, ok := handler.(scopedHandler); ok {
+		return scoped.scope
+	}
+	return ""
+}
+
+// HasScope reports whether the identity may call a route that requires the scope. Sessions may call every route,
+// tokens only routes with one of their scopes.
+func HasScope(identity model.Identity, scope string) bool {
+	if identity.TokenID == 0 {
+		return true
+	}
+	for _, s := range identity.Scopes {
+		if scope != "" && s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteJSONError writes an error response in the same {"error": "..."} format the login endpoint uses.
+func WriteJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func writeScopeError(w http.ResponseWriter, scope string) {
+	if scope == "" {
+		WriteJSONError(w, http.StatusForbidden, "This endpoint can't be used with an access token")
+		return
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+	WriteJSONError(w, http.StatusForbidden, "Access token is missing the "+scope+" scope")
+}
+
+func writeAuthError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrNoCredentials:
+		WriteJSONError(w, http.StatusUnauthorized, "Authentication required")
+	case ErrInvalidSession:
+		WriteJSONError(w, http.StatusUnauthorized, "Invalid session")
+	case ErrSessionExpired:
+		WriteJSONError(w, http.StatusUnauthorized, "Session expired")
+	case ErrInvalidToken, ErrTokenExpired:
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		WriteJSONError(w, http.StatusUnauthorized, "Invalid or expired token")
+	default:
+		log.Println("Error authenticating request: ", err)
+		WriteJSONError(w, http.StatusInternalServerError, "Error authenticating request")
+	}
+}
@@ -0,0 +1,326 @@
+package auth
+
+import (
+	"backend/pkg/config"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDCProvider logs users in with an external OpenID Connect identity provider, using the authorization
+// code flow with PKCE. The endpoints and signing keys come from the discovery document of the issuer and are cached.
+type OIDCProvider struct {
+	cfg    config.OIDCProvider
+	client *http.Client
+
+	mu          sync.Mutex
+	discovery   *oidcDiscovery
+	keys        map[string]jwk
+	keysFetched time.Time
+}
+
+func NewOIDCProvider(cfg config.OIDCProvider) *OIDCProvider {
+	return &OIDCProvider{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+const (
+	// oidcClockSkew is the time the clocks of the provider and the server may differ
+	oidcClockSkew = time.Minute
+	// oidcKeysRefresh limits how often the signing keys are fetched again for an unknown key ID
+	oidcKeysRefresh = time.Minute
+	oidcKeysMaxAge  = time.Hour
+)
+
+var ErrOIDCProvider = errors.New("oidc: identity provider error")
+
+// oidcDiscovery is the part of the provider's discovery document (OpenID Connect Discovery 1.0) the login needs.
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCClaims are the claims of a verified ID token. The profile claims are used to create accounts.
+type OIDCClaims struct {
+	Issuer            string       `json:"iss"`
+	Subject           string       `json:"sub"`
+	Audience          oidcAudience `json:"aud"`
+	AuthorizedParty   string       `json:"azp"`
+	Expiry            int64        `json:"exp"`
+	IssuedAt          int64        `json:"iat"`
+	Nonce             string       `json:"nonce"`
+	Email             string       `json:"email"`
+	EmailVerified     oidcBool     `json:"email_verified"`
+	PreferredUsername string       `json:"preferred_username"`
+	Name              string       `json:"name"`
+	GivenName         string       `json:"given_name"`
+	FamilyName        string       `json:"family_name"`
+	Picture           string       `json:"picture"`
+	Birthdate         string       `json:"birthdate"`
+}
+
+// oidcAudience is the aud claim, a single string or an array of strings.
+type oidcAudience []string
+
+func (a *oidcAudience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = oidcAudience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// oidcBool accepts true as well as "true", some providers send email_verified as a string.
+type oidcBool bool
+
+func (b *oidcBool) UnmarshalJSON(data []byte) error {
+	*b = oidcBool(string(data) == "true" || string(data) == `"true"`)
+	return nil
+}
+
+func (p *OIDCProvider) Name() string {
+	return p.cfg.Name
+}
+
+func (p *OIDCProvider) DisplayName() string {
+	return p.cfg.DisplayName
+}
+
+func (p *OIDCProvider) Issuer() string {
+	return p.cfg.Issuer
+}
+
+// NewCodeVerifier returns a random PKCE code verifier (RFC 7636).
+func NewCodeVerifier() (string, error) {
+	verifier, _, err := GenerateToken()
+	return verifier, err
+}
+
+// CodeChallenge returns the S256 code challenge of the PKCE code verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the address of the provider's login page the user is redirected to.
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {CodeChallenge(codeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems the authorization code at the token endpoint and returns the claims of the verified ID token.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (OIDCClaims, error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return OIDCClaims{}, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	if p.cfg.ClientSecret == "" {
+		form.Set("client_id", p.cfg.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return OIDCClaims{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		// client_secret_basic, the credentials are form encoded first (RFC 6749 section 2.3.1)
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return OIDCClaims{}, fmt.Errorf("%w: %v", ErrOIDCProvider, err)
+	}
+	defer resp.Body.Close()
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return OIDCClaims{}, fmt.Errorf("%w: invalid token response: %v", ErrOIDCProvider, err)
+	}
+	if resp.StatusCode != http.StatusOK || token.Error != "" {
+		return OIDCClaims{}, fmt.Errorf("%w: token request failed with %d %s %s", ErrOIDCProvider, resp.StatusCode, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return OIDCClaims{}, fmt.Errorf("%w: no ID token in the token response", ErrOIDCProvider)
+	}
+	return p.VerifyIDToken(ctx, token.IDToken, nonce)
+}
+
+// VerifyIDToken checks the signature, issuer, audience, lifetime and nonce of the ID token and returns its claims.
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, idToken, nonce string) (OIDCClaims, error) {
+	header, signingInput, payload, signature, err := splitJWT(idToken)
+	if err != nil {
+		return OIDCClaims{}, err
+	}
+	key, err := p.getKey(ctx, header)
+	if err != nil {
+		return OIDCClaims{}, err
+	}
+	if err := verifyJWTSignature(header.Alg, key, signingInput, signature); err != nil {
+		return OIDCClaims{}, err
+	}
+
+	var claims OIDCClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return OIDCClaims{}, errJWTMalformed
+	}
+	now := time.Now()
+	switch {
+	case claims.Issuer != p.cfg.Issuer:
+		return OIDCClaims{}, errors.New("oidc: ID token from another issuer")
+	case !containsString(claims.Audience, p.cfg.ClientID):
+		return OIDCClaims{}, errors.New("oidc: ID token for another client")
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != p.cfg.ClientID:
+		return OIDCClaims{}, errors.New("oidc: ID token authorized for another client")
+	case claims.Expiry == 0 || now.After(time.Unix(claims.Expiry, 0).Add(oidcClockSkew)):
+		return OIDCClaims{}, errors.New("oidc: ID token expired")
+	case time.Unix(claims.IssuedAt, 0).After(now.Add(oidcClockSkew)):
+		return OIDCClaims{}, errors.New("oidc: ID token issued in the future")
+	case claims.Nonce != nonce:
+		return OIDCClaims{}, errors.New("oidc: nonce mismatch")
+	case claims.Subject == "":
+		return OIDCClaims{}, errors.New("oidc: ID token without subject")
+	}
+	return claims, nil
+}
+
+func (p *OIDCProvider) getDiscovery(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := p.getJSON(ctx, p.cfg.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	if discovery.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("%w: discovery document is for issuer %q", ErrOIDCProvider, discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: discovery document is missing endpoints", ErrOIDCProvider)
+	}
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// getKey returns the signing key the token header points to. Providers rotate their keys,
+// so the key set is fetched again when it is old or doesn't have the key yet.
+func (p *OIDCProvider) getKey(ctx context.Context, header jwtHeader) (crypto.PublicKey, error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key, ok := p.findKey(header)
+	if (!ok && time.Since(p.keysFetched) > oidcKeysRefresh) || time.Since(p.keysFetched) > oidcKeysMaxAge {
+		var set struct {
+			Keys []jwk `json:"keys"`
+		}
+		if err := p.getJSON(ctx, discovery.JWKSURI, &set); err != nil {
+			return nil, err
+		}
+		p.keys = make(map[string]jwk, len(set.Keys))
+		for _, k := range set.Keys {
+			if k.Use == "" || k.Use == "sig" {
+				p.keys[k.Kid] = k
+			}
+		}
+		p.keysFetched = time.Now()
+		key, ok = p.findKey(header)
+	}
+	if !ok {
+		return nil, fmt.Errorf("oidc: unknown signing key %q", header.Kid)
+	}
+	if key.Alg != "" && key.Alg != header.Alg {
+		return nil, errors.New("oidc: token algorithm doesn't match the key")
+	}
+	return key.publicKey()
+}
+
+// findKey looks the key up by its ID. Tokens without a key ID are accepted if the set has a single key.
+func (p *OIDCProvider) findKey(header jwtHeader) (jwk, bool) {
+	if header.Kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[header.Kid]
+	return key, ok
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrOIDCProvider, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: GET %s returned %d", ErrOIDCProvider, url, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v); err != nil {
+		return fmt.Errorf("%w: invalid JSON from %s: %v", ErrOIDCProvider, url, err)
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"backend/pkg/config"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID     = "iriesphere"
+	testClientSecret = "secret"
+	testCode         = "good-code"
+	testNonce        = "the-nonce"
+	testKeyID        = "key-1"
+)
+
+// mockIssuer is a local OpenID Connect provider with discovery, key set and token endpoint.
+// The token endpoint answers a valid code with the ID token the test put into idToken.
+type mockIssuer struct {
+	server  *httptest.Server
+	key     *rsa.PrivateKey
+	idToken string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testKeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, _ := r.BasicAuth()
+		if r.Method != http.MethodPost || clientID != testClientID || secret != testClientSecret ||
+			r.FormValue("grant_type") != "authorization_code" || r.FormValue("code_verifier") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		if r.FormValue("code") != testCode {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "access", "token_type": "Bearer", "id_token": m.idToken})
+	})
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockIssuer) provider() *OIDCProvider {
+	return NewOIDCProvider(config.OIDCProvider{
+		Name:         "mock",
+		DisplayName:  "Mock",
+		Issuer:       m.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		Scopes:       []string{"openid", "email", "profile"},
+		RedirectURL:  "http://localhost:8080/api/users/oidc/mock/callback",
+	})
+}
+
+// claims returns valid ID token claims for the test client.
+func (m *mockIssuer) claims() map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss":            m.server.URL,
+		"sub":            "user-42",
+		"aud":            testClientID,
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          testNonce,
+		"email":          "ada@example.test",
+		"email_verified": true,
+		"name":           "Ada Lovelace",
+	}
+}
+
+// sign returns the compact serialized RS256 JWS of the claims with the given header.
+func (m *mockIssuer) sign(t *testing.T, header, claims map[string]interface{}) string {
+	t.Helper()
+	rawHeader, _ := json.Marshal(header)
+	rawClaims, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(rawHeader) + "." + base64.RawURLEncoding.EncodeToString(rawClaims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func rs256Header() map[string]interface{} {
+	return map[string]interface{}{"alg": "RS256", "kid": testKeyID, "typ": "JWT"}
+}
+
+func TestOIDCAuthCodeURL(t *testing.T) {
+	issuer := newMockIssuer(t)
+	authURL, err := issuer.provider().AuthCodeURL(context.Background(), "the-state", testNonce, "the-verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(authURL, issuer.server.URL+"/authorize?") {
+		t.Fatalf("login page %s isn't the authorization endpoint", authURL)
+	}
+	query := u.Query()
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"state":                 "the-state",
+		"nonce":                 testNonce,
+		"code_challenge":        CodeChallenge("the-verifier"),
+		"code_challenge_method": "S256",
+		"scope":                 "openid email profile",
+	}
+	for name, value := range want {
+		if query.Get(name) != value {
+			t.Errorf("%s = %q, want %q", name, query.Get(name), value)
+		}
+	}
+}
+
+func TestOIDCExchange(t *testing.T) {
+	issuer := newMockIssuer(t)
+	issuer.idToken = issuer.sign(t, rs256Header(), issuer.claims())
+
+	claims, err := issuer.provider().Exchange(context.Background(), testCode, "the-verifier", testNonce)
+	if err != nil {
+		t.Fatalf("exchange failed: %v", err)
+	}
+	if claims.Issuer != issuer.server.URL || claims.Subject != "user-42" || claims.Email != "ada@example.test" || !bool(claims.EmailVerified) {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+}
+
+func TestOIDCExchangeRejectsBadCode(t *testing.T) {
+	issuer := newMockIssuer(t)
+	issuer.idToken = issuer.sign(t, rs256Header(), issuer.claims())
+
+	_, err := issuer.provider().Exchange(context.Background(), "stolen-code", "the-verifier", testNonce)
+	if !errors.Is(err, ErrOIDCProvider) {
+		t.Fatalf("error = %v, want %v", err, ErrOIDCProvider)
+	}
+}
+
+func TestOIDCExchangeRejectsIDToken(t *testing.T) {
+	issuer := newMockIssuer(t)
+	unsigned := func(header, claims map[string]interface{}) string {
+		rawHeader, _ := json.Marshal(header)
+		rawClaims, _ := json.Marshal(claims)
+		return base64.RawURLEncoding.EncodeToString(rawHeader) + "." + base64.RawURLEncoding.EncodeToString(rawClaims) + "."
+	}
+
+	tests := []struct {
+		name    string
+		idToken func() string
+	}{
+		{name: "wrong issuer", idToken: func() string {
+			claims := issuer.claims()
+			claims["iss"] = "https://evil.test"
+			return issuer.sign(t, rs256Header(), claims)
+		}},
+		{name: "wrong audience", idToken: func() string {
+			claims := issuer.claims()
+			claims["aud"] = "another-client"
+			return issuer.sign(t, rs256Header(), claims)
+		}},
+		{name: "several audiences without azp", idToken: func() string {
+			claims := issuer.claims()
+			claims["aud"] = []string{testClientID, "another-client"}
+			return issuer.sign(t, rs256Header(), claims)
+		}},
+		{name: "wrong nonce", idToken: func() string {
+			claims := issuer.claims()
+			claims["nonce"] = "replayed-nonce"
+			return issuer.sign(t, rs256Header(), claims)
+		}},
+		{name: "expired", idToken: func() string {
+			claims := issuer.claims()
+			claims["iat"] = time.Now().Add(-2 * time.Hour).Unix()
+			claims["exp"] = time.Now().Add(-time.Hour).Unix()
+			return issuer.sign(t, rs256Header(), claims)
+		}},
+		{name: "alg none", idToken: func() string {
+			return unsigned(map[string]interface{}{"alg": "none", "kid": testKeyID}, issuer.claims())
+		}},
+		{name: "unknown kid", idToken: func() string {
+			header := rs256Header()
+			header["kid"] = "key-2"
+			return issuer.sign(t, header, issuer.claims())
+		}},
+		{name: "bad signature", idToken: func() string {
+			token := issuer.sign(t, rs256Header(), issuer.claims())
+			claims := issuer.claims()
+			claims["sub"] = "admin"
+			rawClaims, _ := json.Marshal(claims)
+			parts := strings.Split(token, ".")
+			return parts[0] + "." + base64.RawURLEncoding.EncodeToString(rawClaims) + "." + parts[2]
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer.idToken = tt.idToken()
+			if claims, err := issuer.provider().Exchange(context.Background(), testCode, "the-verifier", testNonce); err == nil {
+				t.Fatalf("ID token accepted: %+v", claims)
+			}
+		})
+	}
+}
+
+// Key sets don't have to name the algorithm of a key, the signature check alone has to refuse unsigned and HMAC tokens.
+func TestVerifyJWTSignatureRejectsUnsafeAlgorithms(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, alg := range []string{"none", "None", "HS256", ""} {
+		if err := verifyJWTSignature(alg, &key.PublicKey, "header.payload", nil); err == nil {
+			t.Errorf("alg %q accepted", alg)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"backend/pkg/config"
+	"backend/pkg/model"
+	"backend/pkg/repository"
+	"backend/util"
+	"net/http"
+	"time"
+)
+
+const sessionCookieName = "session_token"
+
+// SessionManager creates and renews sessions according to the configured lifetimes
+// and keeps the session cookie in sync with the session stored in the database.
+type SessionManager struct {
+	sessionRepo *repository.SessionRepository
+	config      config.Session
+}
+
+func NewSessionManager(sessionRepo *repository.SessionRepository, config config.Session) *SessionManager {
+	return &SessionManager{sessionRepo: sessionRepo, config: config}
+}
+
+// Start creates a new session for the user and sets the session cookie.
+// Remember me sessions get the long lived timeouts.
+func (m *SessionManager) Start(w http.ResponseWriter, r *http.Request, userID int, rememberMe bool) error {
+	idle, absolute := m.config.Timeouts(rememberMe)
+	now := time.Now()
+	session := model.Session{
+		SessionToken:      util.GenerateSessionToken(),
+		UserID:            userID,
+		ExpiresAt:         now.Add(idle),
+		AbsoluteExpiresAt: now.Add(absolute),
+		RememberMe:        rememberMe,
+		UserAgent:         r.UserAgent(),
+		IPAddress:         util.GetClientIP(r),
+		CreatedAt:         now,
+		LastSeenAt:        now,
+	}
+	if session.ExpiresAt.After(session.AbsoluteExpiresAt) {
+		session.ExpiresAt = session.AbsoluteExpiresAt
+	}
+	if err := m.sessionRepo.StoreSessionInDB(&session); err != nil {
+		return err
+	}
+	setSessionCookie(w, session.SessionToken, session.ExpiresAt)
+	return nil
+}
+
+// Renew slides the expiration time of the session forward by the idle timeout, capped by the absolute timeout.
+// Sessions seen less than RenewAfter ago are left alone unless force is set, so busy clients don't write on every request.
+func (m *SessionManager) Renew(w http.ResponseWriter, session model.Session, force bool) error {
+	now := time.Now()
+	if !force && now.Sub(session.LastSeenAt) < m.config.RenewAfter {
+		return nil
+	}
+	idle, _ := m.config.Timeouts(session.RememberMe)
+	expiresAt := now.Add(idle)
+	if expiresAt.After(session.AbsoluteExpiresAt) {
+		expiresAt = session.AbsoluteExpiresAt
+	}
+	if err := m.sessionRepo.RenewSession(session.SessionToken, expiresAt); err != nil {
+		return err
+	}
+	setSessionCookie(w, session.SessionToken, expiresAt)
+	return nil
+}
+
+// ClearCookie expires the session cookie in the browser.
+func ClearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:    sessionCookieName,
+		Value:   "",
+		MaxAge:  -1, // Setting MaxAge to -1 immediately expires the cookie
+		Expires: time.Unix(0, 0),
+		Path:    "/",
+	})
+}
+
+func setSessionCookie(w http.ResponseWriter, sessionToken string, expiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:   sessionCookieName,
+		Value:  sessionToken,
+		MaxAge: int(time.Until(expiresAt).Seconds()),
+		Path:   "/", // Make cookie available for all paths
+	})
+}
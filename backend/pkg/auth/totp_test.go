@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// The RFC 6238 SHA-1 secret "12345678901234567890" in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// Appendix D of RFC 4226.
+func TestHOTPRFC4226(t *testing.T) {
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range want {
+		if got := hotp([]byte("12345678901234567890"), int64(counter)); got != code {
+			t.Errorf("counter %d: code = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+// Appendix B of RFC 6238 for SHA-1, the 8 digit values cut to the last 6 digits.
+func TestTOTPRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		now := time.Unix(tt.unix, 0)
+		code, err := TOTPCode(rfcSecret, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != tt.code {
+			t.Errorf("TOTPCode at %d = %s, want %s", tt.unix, code, tt.code)
+		}
+		step, ok := ValidateTOTP(rfcSecret, tt.code, now)
+		if !ok || step != tt.unix/30 {
+			t.Errorf("ValidateTOTP at %d = %d, %v, want step %d", tt.unix, step, ok, tt.unix/30)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := totpStep(now)
+	for offset := int64(-2); offset <= 2; offset++ {
+		code, _ := TOTPCode(rfcSecret, now.Add(time.Duration(offset)*totpPeriod))
+		step, ok := ValidateTOTP(rfcSecret, code, now)
+		if offset < -totpSkew || offset > totpSkew {
+			if ok {
+				t.Errorf("code %d steps away accepted", offset)
+			}
+			continue
+		}
+		if !ok || step != current+offset {
+			t.Errorf("code %d steps away: step = %d, %v, want %d", offset, step, ok, current+offset)
+		}
+	}
+}
+
+func TestValidateTOTPRejects(t *testing.T) {
+	now := time.Unix(59, 0)
+	tests := []struct {
+		name, secret, code string
+	}{
+		{"wrong code", rfcSecret, "287083"},
+		{"8 digits", rfcSecret, "94287082"},
+		{"too short", rfcSecret, "28708"},
+		{"empty", rfcSecret, ""},
+		{"invalid secret", "not base32!", "287082"},
+	}
+	for _, tt := range tests {
+		if _, ok := ValidateTOTP(tt.secret, tt.code, now); ok {
+			t.Errorf("%s: code accepted", tt.name)
+		}
+	}
+	// Secrets typed in lower case and codes with surrounding spaces still work
+	if _, ok := ValidateTOTP(strings.ToLower(rfcSecret), " 287082 ", now); !ok {
+		t.Error("lower case secret or padded code rejected")
+	}
+}
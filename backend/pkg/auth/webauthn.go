@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"backend/pkg/config"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// WebAuthn verifies passkey registrations and logins (W3C Web Authentication level 2) for one relying party.
+// Attestation statements are not checked, the public key is trusted on first use like most consumer sites do.
+type WebAuthn struct {
+	rpID     string
+	rpIDHash [32]byte
+	rpName   string
+	origins  []string
+	timeout  int64
+}
+
+func NewWebAuthn(cfg config.WebAuthn) *WebAuthn {
+	return &WebAuthn{
+		rpID:     cfg.RPID,
+		rpIDHash: sha256.Sum256([]byte(cfg.RPID)),
+		rpName:   cfg.RPName,
+		origins:  cfg.Origins,
+		timeout:  cfg.ChallengeTTL.Milliseconds(),
+	}
+}
+
+// Authenticator data flags
+const (
+	flagUserPresent   = 0x01
+	flagUserVerified  = 0x04
+	flagAttestedData  = 0x40
+	authDataMinLength = 37
+)
+
+var (
+	ErrWebAuthnChallenge = errors.New("webauthn: challenge mismatch")
+	ErrWebAuthnCloned    = errors.New("webauthn: signature counter went backwards, the authenticator may be cloned")
+)
+
+// PublicKeyCredentialDescriptor points to a registered credential.
+type PublicKeyCredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// CreationOptions are the options for navigator.credentials.create, in the JSON form
+// PublicKeyCredential.parseCreationOptionsFromJSON understands.
+type CreationOptions struct {
+	Challenge string `json:"challenge"`
+	RP        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	PubKeyCredParams []struct {
+		Type string `json:"type"`
+		Alg  int    `json:"alg"`
+	} `json:"pubKeyCredParams"`
+	Timeout                int64                           `json:"timeout"`
+	Attestation            string                          `json:"attestation"`
+	ExcludeCredentials     []PublicKeyCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection struct {
+		ResidentKey      string `json:"residentKey"`
+		UserVerification string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+}
+
+// RequestOptions are the options for navigator.credentials.get, in the JSON form
+// PublicKeyCredential.parseRequestOptionsFromJSON understands.
+type RequestOptions struct {
+	Challenge        string                          `json:"challenge"`
+	RPID             string                          `json:"rpId"`
+	Timeout          int64                           `json:"timeout"`
+	AllowCredentials []PublicKeyCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                          `json:"userVerification"`
+}
+
+// NewChallenge returns a random base64url encoded challenge.
+func NewChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CreationOptions builds the options to register a new passkey for the user.
+// Credentials the user already has are excluded, so an authenticator isn't registered twice.
+func (wa *WebAuthn) CreationOptions(challenge string, userHandle []byte, username string, exclude []PublicKeyCredentialDescriptor) CreationOptions {
+	var options CreationOptions
+	options.Challenge = challenge
+	options.RP.ID = wa.rpID
+	options.RP.Name = wa.rpName
+	options.User.ID = base64.RawURLEncoding.EncodeToString(userHandle)
+	options.User.Name = username
+	options.User.DisplayName = username
+	for _, alg := range []int{coseAlgES256, coseAlgEdDSA, coseAlgRS256} {
+		options.PubKeyCredParams = append(options.PubKeyCredParams, struct {
+			Type string `json:"type"`
+			Alg  int    `json:"alg"`
+		}{Type: "public-key", Alg: alg})
+	}
+	options.Timeout = wa.timeout
+	options.Attestation = "none"
+	options.ExcludeCredentials = exclude
+	if options.ExcludeCredentials == nil {
+		options.ExcludeCredentials = []PublicKeyCredentialDescriptor{}
+	}
+	options.AuthenticatorSelection.ResidentKey = "preferred"
+	options.AuthenticatorSelection.UserVerification = "required"
+	return options
+}
+
+// RequestOptions builds the options to log in with a passkey. Without allowed credentials
+// the browser offers all passkeys it has for the site (discoverable credentials).
+func (wa *WebAuthn) RequestOptions(challenge string, allow []PublicKeyCredentialDescriptor) RequestOptions {
+	if allow == nil {
+		allow = []PublicKeyCredentialDescriptor{}
+	}
+	return RequestOptions{
+		Challenge:        challenge,
+		RPID:             wa.rpID,
+		Timeout:          wa.timeout,
+		AllowCredentials: allow,
+		UserVerification: "required",
+	}
+}
+
+// clientData is the part of CollectedClientData the server checks.
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// ChallengeFromClientData returns the challenge the browser signed, used to look up the stored challenge.
+func ChallengeFromClientData(clientDataJSON []byte) (string, error) {
+	var data clientData
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil {
+		return "", errors.New("webauthn: invalid client data")
+	}
+	return data.Challenge, nil
+}
+
+func (wa *WebAuthn) verifyClientData(clientDataJSON []byte, ceremony, challenge string) error {
+	var data clientData
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil {
+		return errors.New("webauthn: invalid client data")
+	}
+	if data.Type != ceremony {
+		return errors.New("webauthn: wrong client data type " + data.Type)
+	}
+	if subtle.ConstantTimeCompare([]byte(data.Challenge), []byte(challenge)) != 1 {
+		return ErrWebAuthnChallenge
+	}
+	for _, origin := range wa.origins {
+		if data.Origin == origin {
+			return nil
+		}
+	}
+	return errors.New("webauthn: origin " + data.Origin + " not allowed")
+}
+
+// authenticatorData is the parsed authenticator data of a registration or login.
+type authenticatorData struct {
+	raw          []byte
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte // CBOR encoded COSE key, only present on registration
+}
+
+func (wa *WebAuthn) parseAuthenticatorData(raw []byte) (authenticatorData, error) {
+	if len(raw) < authDataMinLength {
+		return authenticatorData{}, errors.New("webauthn: authenticator data too short")
+	}
+	if subtle.ConstantTimeCompare(raw[:32], wa.rpIDHash[:]) != 1 {
+		return authenticatorData{}, errors.New("webauthn: relying party ID mismatch")
+	}
+	data := authenticatorData{raw: raw, flags: raw[32], signCount: binary.BigEndian.Uint32(raw[33:37])}
+	if data.flags&flagUserPresent == 0 {
+		return authenticatorData{}, errors.New("webauthn: user not present")
+	}
+	if data.flags&flagUserVerified == 0 {
+		return authenticatorData{}, errors.New("webauthn: user not verified")
+	}
+
+	if data.flags&flagAttestedData != 0 {
+		rest := raw[authDataMinLength:]
+		// AAGUID (16 bytes) and credential ID length (2 bytes)
+		if len(rest) < 18 {
+			return authenticatorData{}, errors.New("webauthn: attested credential data too short")
+		}
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < idLength {
+			return authenticatorData{}, errors.New("webauthn: credential ID truncated")
+		}
+		data.credentialID = rest[:idLength]
+		_, remaining, err := decodeCBOR(rest[idLength:])
+		if err != nil {
+			return authenticatorData{}, errors.New("webauthn: invalid credential public key")
+		}
+		data.publicKey = rest[idLength : len(rest)-len(remaining)]
+	}
+	return data, nil
+}
+
+// RegisteredCredential is a verified new passkey, ready to be stored.
+type RegisteredCredential struct {
+	ID        []byte
+	PublicKey []byte
+	SignCount uint32
+}
+
+// VerifyRegistration checks the response of navigator.credentials.create against the expected challenge
+// and returns the new credential.
+func (wa *WebAuthn) VerifyRegistration(challenge string, clientDataJSON, attestationObject []byte) (RegisteredCredential, error) {
+	if err := wa.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return RegisteredCredential{}, err
+	}
+
+	item, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return RegisteredCredential{}, errors.New("webauthn: invalid attestation object")
+	}
+	attestation, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return RegisteredCredential{}, errors.New("webauthn: invalid attestation object")
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return RegisteredCredential{}, errors.New("webauthn: attestation object without authenticator data")
+	}
+
+	authData, err := wa.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return RegisteredCredential{}, err
+	}
+	if authData.publicKey == nil {
+		return RegisteredCredential{}, errors.New("webauthn: no credential in registration")
+	}
+	if _, _, err := parseCOSEKey(authData.publicKey); err != nil {
+		return RegisteredCredential{}, err
+	}
+
+	return RegisteredCredential{
+		ID:        bytes.Clone(authData.credentialID),
+		PublicKey: bytes.Clone(authData.publicKey),
+		SignCount: authData.signCount,
+	}, nil
+}
+
+// VerifyAssertion checks the response of navigator.credentials.get against the expected challenge and the
+// stored public key of the credential. It returns the new signature counter to store.
+func (wa *WebAuthn) VerifyAssertion(challenge string, publicKey []byte, storedSignCount uint32, clientDataJSON, rawAuthData, signature []byte) (uint32, error) {
+	if err := wa.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+	authData, err := wa.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	message := append(bytes.Clone(authData.raw), clientDataHash[:]...)
+	if err := verifyCOSESignature(publicKey, message, signature); err != nil {
+		return 0, errors.New("webauthn: " + err.Error())
+	}
+
+	// Authenticators that don't count always send 0, a counter that doesn't grow means a copy of the key exists
+	if (authData.signCount != 0 || storedSignCount != 0) && authData.signCount <= storedSignCount {
+		return 0, ErrWebAuthnCloned
+	}
+	return authData.signCount, nil
+}
+
+// DecodeBase64URL decodes the base64url values WebAuthn JSON uses, with or without padding.
+func DecodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
@@ -0,0 +1,297 @@
+package auth
+
+import (
+	"backend/pkg/config"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+const (
+	testRPID   = "iriesphere.test"
+	testOrigin = "https://iriesphere.test"
+)
+
+func newTestWebAuthn() *WebAuthn {
+	return NewWebAuthn(config.WebAuthn{RPID: testRPID, RPName: "IrieSphere", Origins: []string{testOrigin}, ChallengeTTL: time.Minute})
+}
+
+// softAuthenticator is an ES256 authenticator in software, it answers the ceremonies like a browser with a passkey would.
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	signCount    uint32
+	rpID         string
+	origin       string
+	flags        byte
+	noCounter    bool // always sends sign count 0, like most platform authenticators
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialID := make([]byte, 16)
+	rand.Read(credentialID)
+	return &softAuthenticator{key: key, credentialID: credentialID, rpID: testRPID, origin: testOrigin,
+		flags: flagUserPresent | flagUserVerified}
+}
+
+func (a *softAuthenticator) clientData(t *testing.T, ceremony, challenge string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{"type": ceremony, "challenge": challenge, "origin": a.origin, "crossOrigin": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func (a *softAuthenticator) authData(attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append(rpIDHash[:], a.flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if attested != nil {
+		data[32] |= flagAttestedData
+		data = append(data, attested...)
+	}
+	return data
+}
+
+// coseKey is the CBOR encoded COSE form of the public key.
+func (a *softAuthenticator) coseKey() []byte {
+	x := a.key.PublicKey.X.FillBytes(make([]byte, 32))
+	y := a.key.PublicKey.Y.FillBytes(make([]byte, 32))
+	key := cborHead(5, 5)
+	key = append(key, cborInt(coseKeyKty)...)
+	key = append(key, cborInt(2)...)
+	key = append(key, cborInt(coseKeyAlg)...)
+	key = append(key, cborInt(coseAlgES256)...)
+	key = append(key, cborInt(coseKeyCrv)...)
+	key = append(key, cborInt(1)...)
+	key = append(key, cborInt(coseKeyX)...)
+	key = append(key, cborBytes(x)...)
+	key = append(key, cborInt(coseKeyY)...)
+	key = append(key, cborBytes(y)...)
+	return key
+}
+
+// create answers navigator.credentials.create with a "none" attestation.
+func (a *softAuthenticator) create(t *testing.T, challenge string) (clientDataJSON, attestationObject []byte) {
+	attested := make([]byte, 16) // AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialID)))
+	attested = append(attested, a.credentialID...)
+	attested = append(attested, a.coseKey()...)
+
+	attestationObject = cborHead(5, 3)
+	attestationObject = append(attestationObject, cborText("fmt")...)
+	attestationObject = append(attestationObject, cborText("none")...)
+	attestationObject = append(attestationObject, cborText("attStmt")...)
+	attestationObject = append(attestationObject, cborHead(5, 0)...)
+	attestationObject = append(attestationObject, cborText("authData")...)
+	attestationObject = append(attestationObject, cborBytes(a.authData(attested))...)
+	return a.clientData(t, "webauthn.create", challenge), attestationObject
+}
+
+// get answers navigator.credentials.get, counting the signature like hardware authenticators do.
+func (a *softAuthenticator) get(t *testing.T, challenge string) (clientDataJSON, authData, signature []byte) {
+	t.Helper()
+	if !a.noCounter {
+		a.signCount++
+	}
+	clientDataJSON = a.clientData(t, "webauthn.get", challenge)
+	authData = a.authData(nil)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clientDataJSON, authData, signature
+}
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	default:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	}
+}
+
+func cborInt(n int64) []byte {
+	if n < 0 {
+		return cborHead(1, uint64(-1-n))
+	}
+	return cborHead(0, uint64(n))
+}
+
+func cborBytes(b []byte) []byte {
+	return append(cborHead(2, uint64(len(b))), b...)
+}
+
+func cborText(s string) []byte {
+	return append(cborHead(3, uint64(len(s))), s...)
+}
+
+// register runs a registration ceremony and returns the stored credential.
+func register(t *testing.T, wa *WebAuthn, authenticator *softAuthenticator) RegisteredCredential {
+	t.Helper()
+	challenge, err := NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientDataJSON, attestationObject := authenticator.create(t, challenge)
+	credential, err := wa.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		t.Fatalf("registration failed: %v", err)
+	}
+	return credential
+}
+
+func TestWebAuthnRegistrationAndAssertion(t *testing.T) {
+	wa := newTestWebAuthn()
+	authenticator := newSoftAuthenticator(t)
+
+	credential := register(t, wa, authenticator)
+	if string(credential.ID) != string(authenticator.credentialID) {
+		t.Fatalf("credential ID = %x, want %x", credential.ID, authenticator.credentialID)
+	}
+	if credential.SignCount != 0 {
+		t.Fatalf("sign count = %d, want 0", credential.SignCount)
+	}
+
+	signCount := credential.SignCount
+	for i := 0; i < 3; i++ {
+		challenge, _ := NewChallenge()
+		clientDataJSON, authData, signature := authenticator.get(t, challenge)
+		got, err := wa.VerifyAssertion(challenge, credential.PublicKey, signCount, clientDataJSON, authData, signature)
+		if err != nil {
+			t.Fatalf("assertion %d failed: %v", i, err)
+		}
+		if got != authenticator.signCount {
+			t.Fatalf("assertion %d: sign count = %d, want %d", i, got, authenticator.signCount)
+		}
+		signCount = got
+	}
+}
+
+func TestWebAuthnRegistrationRejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(a *softAuthenticator)
+		wrong  string // challenge the server expects instead of the one signed
+	}{
+		{name: "wrong challenge", wrong: "another-challenge"},
+		{name: "wrong origin", modify: func(a *softAuthenticator) { a.origin = "https://evil.test" }},
+		{name: "wrong relying party", modify: func(a *softAuthenticator) { a.rpID = "evil.test" }},
+		{name: "user not verified", modify: func(a *softAuthenticator) { a.flags = flagUserPresent }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wa := newTestWebAuthn()
+			authenticator := newSoftAuthenticator(t)
+			if tt.modify != nil {
+				tt.modify(authenticator)
+			}
+			challenge, _ := NewChallenge()
+			clientDataJSON, attestationObject := authenticator.create(t, challenge)
+			expected := challenge
+			if tt.wrong != "" {
+				expected = tt.wrong
+			}
+			if _, err := wa.VerifyRegistration(expected, clientDataJSON, attestationObject); err == nil {
+				t.Fatal("registration accepted")
+			}
+		})
+	}
+}
+
+func TestWebAuthnAssertionRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(a *softAuthenticator)
+		tamper  func(clientDataJSON, authData, signature []byte) ([]byte, []byte, []byte)
+		wrong   string
+		wantErr error
+	}{
+		{name: "wrong challenge", wrong: "another-challenge", wantErr: ErrWebAuthnChallenge},
+		{name: "wrong origin", modify: func(a *softAuthenticator) { a.origin = "https://evil.test" }},
+		{name: "bad signature", tamper: func(c, a, s []byte) ([]byte, []byte, []byte) {
+			s[len(s)-1] ^= 0xff
+			return c, a, s
+		}},
+		{name: "signed other data", tamper: func(c, a, s []byte) ([]byte, []byte, []byte) {
+			a[36]++ // raise the sign count after signing
+			return c, a, s
+		}},
+		{name: "key of another authenticator", modify: func(a *softAuthenticator) {
+			a.key, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wa := newTestWebAuthn()
+			authenticator := newSoftAuthenticator(t)
+			credential := register(t, wa, authenticator)
+			if tt.modify != nil {
+				tt.modify(authenticator)
+			}
+
+			challenge, _ := NewChallenge()
+			clientDataJSON, authData, signature := authenticator.get(t, challenge)
+			if tt.tamper != nil {
+				clientDataJSON, authData, signature = tt.tamper(clientDataJSON, authData, signature)
+			}
+			expected := challenge
+			if tt.wrong != "" {
+				expected = tt.wrong
+			}
+			_, err := wa.VerifyAssertion(expected, credential.PublicKey, credential.SignCount, clientDataJSON, authData, signature)
+			if err == nil {
+				t.Fatal("assertion accepted")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebAuthnSignCountRegression(t *testing.T) {
+	wa := newTestWebAuthn()
+	authenticator := newSoftAuthenticator(t)
+	credential := register(t, wa, authenticator)
+
+	// A copy of the key that fell behind the stored counter
+	authenticator.signCount = 4
+	challenge, _ := NewChallenge()
+	clientDataJSON, authData, signature := authenticator.get(t, challenge)
+	if _, err := wa.VerifyAssertion(challenge, credential.PublicKey, 7, clientDataJSON, authData, signature); !errors.Is(err, ErrWebAuthnCloned) {
+		t.Fatalf("lower counter: error = %v, want %v", err, ErrWebAuthnCloned)
+	}
+	// The same counter again is a replay from a copy as well
+	if _, err := wa.VerifyAssertion(challenge, credential.PublicKey, 5, clientDataJSON, authData, signature); !errors.Is(err, ErrWebAuthnCloned) {
+		t.Fatalf("equal counter: error = %v, want %v", err, ErrWebAuthnCloned)
+	}
+
+	// Authenticators without a counter always send 0
+	authenticator.signCount = 0
+	authenticator.noCounter = true
+	for i := 0; i < 2; i++ {
+		challenge, _ := NewChallenge()
+		clientDataJSON, authData, signature := authenticator.get(t, challenge)
+		if got, err := wa.VerifyAssertion(challenge, credential.PublicKey, 0, clientDataJSON, authData, signature); err != nil || got != 0 {
+			t.Fatalf("authenticator without counter: got %d, %v", got, err)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package config
+
+import "time"
+
+// Account configures password reset and email verification.
+type Account struct {
+	PasswordResetTTL     time.Duration // PASSWORD_RESET_TTL, how long a password reset link stays valid
+	EmailVerificationTTL time.Duration // EMAIL_VERIFICATION_TTL, how long an email verification link stays valid
+	// REQUIRE_EMAIL_VERIFICATION, when set users with an unverified email address
+	// can log in and read but not create or change content
+	RequireEmailVerification bool
+}
+
+func loadAccount() Account {
+	return Account{
+		PasswordResetTTL:         getDuration("PASSWORD_RESET_TTL", time.Hour),
+		EmailVerificationTTL:     getDuration("EMAIL_VERIFICATION_TTL", 48*time.Hour),
+		RequireEmailVerification: getBool("REQUIRE_EMAIL_VERIFICATION", true),
+	}
+}
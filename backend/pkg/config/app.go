@@ -0,0 +1,50 @@
+package config
+
+import (
+	"log"
+	"net"
+	"strings"
+)
+
+// App holds the public addresses of the frontend and the backend, used to build links in emails,
+// and the reverse proxies in front of the backend.
+type App struct {
+	FrontendURL string // NEXT_PUBLIC_URL:NEXT_PUBLIC_HTTPS_PORT
+	BackendURL  string // NEXT_PUBLIC_URL:NEXT_PUBLIC_BACKEND_PORT
+	// TRUSTED_PROXIES, comma separated IP addresses and CIDR ranges of the reverse proxies allowed to
+	// pass on the client address in X-Forwarded-For. Empty means the backend is reached directly.
+	TrustedProxies []*net.IPNet
+}
+
+func loadApp() App {
+	address := getString("NEXT_PUBLIC_URL", "http://localhost")
+	return App{
+		FrontendURL:    address + ":" + getString("NEXT_PUBLIC_HTTPS_PORT", "3000"),
+		BackendURL:     address + ":" + getString("NEXT_PUBLIC_BACKEND_PORT", "8080"),
+		TrustedProxies: loadTrustedProxies(),
+	}
+}
+
+func loadTrustedProxies() []*net.IPNet {
+	var proxies []*net.IPNet
+	for _, value := range strings.Split(getString("TRUSTED_PROXIES", ""), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+		_, proxy, err := net.ParseCIDR(value)
+		if err != nil {
+			log.Printf("Invalid trusted proxy %q in TRUSTED_PROXIES, ignoring it", value)
+			continue
+		}
+		proxies = append(proxies, proxy)
+	}
+	return proxies
+}
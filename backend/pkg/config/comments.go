@@ -0,0 +1,27 @@
+package config
+
+import (
+	"log"
+	"time"
+)
+
+// Comments configures the comments of posts.
+type Comments struct {
+	// COMMENT_MAX_DEPTH, how deep replies can be nested: 1 allows replies to comments on the post but no replies to replies,
+	// 0 turns replies off
+	MaxDepth int
+	// COMMENT_EDIT_WINDOW, how long after writing a comment its author can edit it
+	EditWindow time.Duration
+}
+
+func loadComments() Comments {
+	comments := Comments{
+		MaxDepth:   getInt("COMMENT_MAX_DEPTH", 3),
+		EditWindow: getDuration("COMMENT_EDIT_WINDOW", 15*time.Minute),
+	}
+	if comments.MaxDepth < 0 {
+		log.Printf("Invalid COMMENT_MAX_DEPTH %d, using default 3", comments.MaxDepth)
+		comments.MaxDepth = 3
+	}
+	return comments
+}
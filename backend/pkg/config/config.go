@@ -0,0 +1,109 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the application settings read from the environment (.env file).
+// Every setting has a default, so an empty environment gives a working development setup.
+type Config struct {
+	App          App
+	Session      Session
+	Mail         Mail
+	Account      Account
+	TwoFactor    TwoFactor
+	WebAuthn     WebAuthn
+	Lockout      Lockout
+	OIDC         OIDC
+	OAuth        OAuth
+	Registration Registration
+	Feed         Feed
+	Reactions    Reactions
+	Tags         Tags
+	Comments     Comments
+}
+
+// Load reads the configuration from the environment. Call it after the .env file has been loaded.
+func Load() *Config {
+	app := loadApp()
+	return &Config{
+		App:          app,
+		Session:      loadSession(),
+		Mail:         loadMail(),
+		Account:      loadAccount(),
+		TwoFactor:    loadTwoFactor(),
+		WebAuthn:     loadWebAuthn(app),
+		Lockout:      loadLockout(),
+		OIDC:         loadOIDC(app),
+		OAuth:        loadOAuth(),
+		Registration: loadRegistration(),
+		Feed:         loadFeed(),
+		Reactions:    loadReactions(),
+		Tags:         loadTags(),
+		Comments:     loadComments(),
+	}
+}
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Printf("Invalid duration %q for %s, using default %s", value, key, fallback)
+		return fallback
+	}
+	return duration
+}
+
+func getString(key, fallback string) string {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func getInt(key string, fallback int) int {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return fallback
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid number %q for %s, using default %d", value, key, fallback)
+		return fallback
+	}
+	return number
+}
+
+func getFloat(key string, fallback float64) float64 {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return fallback
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid number %q for %s, using default %g", value, key, fallback)
+		return fallback
+	}
+	return number
+}
+
+func getBool(key string, fallback bool) bool {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid boolean %q for %s, using default %t", value, key, fallback)
+		return fallback
+	}
+	return b
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"log"
+	"time"
+)
+
+// Feed configures the pages of the post feeds (main feed, profile and group posts) and the ranked main feed.
+type Feed struct {
+	PageSize    int // FEED_PAGE_SIZE, posts per page when the request has no limit
+	MaxPageSize int // FEED_MAX_PAGE_SIZE, the largest limit a request can ask for
+	// FEED_RANK_CANDIDATES, the ranked feed ranks this many of the newest posts the user can see
+	RankCandidates int
+	RankWeights    RankWeights
+}
+
+// RankWeights tune how the ranked feed scores a post, a weight of 0 turns a signal off.
+// Counts are scored by their logarithm, so the first reactions and comments matter most.
+type RankWeights struct {
+	Recency         float64       // FEED_RANK_RECENCY_WEIGHT, for a post created right now
+	RecencyHalfLife time.Duration // FEED_RANK_RECENCY_HALF_LIFE, after which a post gets half of the recency weight
+	Friend          float64       // FEED_RANK_FRIEND_WEIGHT, for posts of friends
+	Affinity        float64       // FEED_RANK_AFFINITY_WEIGHT, per reactions and comments of the user on posts of the author
+	Reactions       float64       // FEED_RANK_REACTIONS_WEIGHT, per reactions to the post
+	Comments        float64       // FEED_RANK_COMMENTS_WEIGHT, per comments on the post
+	Group           float64       // FEED_RANK_GROUP_WEIGHT, for posts in groups of the user
+}
+
+func loadFeed() Feed {
+	feed := Feed{
+		PageSize:       getInt("FEED_PAGE_SIZE", 20),
+		MaxPageSize:    getInt("FEED_MAX_PAGE_SIZE", 100),
+		RankCandidates: getInt("FEED_RANK_CANDIDATES", 500),
+		RankWeights: RankWeights{
+			Recency:         getFloat("FEED_RANK_RECENCY_WEIGHT", 3),
+			RecencyHalfLife: getDuration("FEED_RANK_RECENCY_HALF_LIFE", 24*time.Hour),
+			Friend:          getFloat("FEED_RANK_FRIEND_WEIGHT", 1),
+			Affinity:        getFloat("FEED_RANK_AFFINITY_WEIGHT", 0.5),
+			Reactions:       getFloat("FEED_RANK_REACTIONS_WEIGHT", 0.5),
+			Comments:        getFloat("FEED_RANK_COMMENTS_WEIGHT", 0.7),
+			Group:           getFloat("FEED_RANK_GROUP_WEIGHT", 0.8),
+		},
+	}
+	if feed.MaxPageSize < 1 {
+		log.Printf("Invalid FEED_MAX_PAGE_SIZE %d, using default 100", feed.MaxPageSize)
+		feed.MaxPageSize = 100
+	}
+	if feed.PageSize < 1 || feed.PageSize > feed.MaxPageSize {
+		log.Printf("FEED_PAGE_SIZE %d is not between 1 and FEED_MAX_PAGE_SIZE, using %d", feed.PageSize, feed.MaxPageSize)
+		feed.PageSize = feed.MaxPageSize
+	}
+	if feed.RankCandidates < 1 {
+		log.Printf("Invalid FEED_RANK_CANDIDATES %d, using default 500", feed.RankCandidates)
+		feed.RankCandidates = 500
+	}
+	return feed
+}
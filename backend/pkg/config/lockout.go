@@ -0,0 +1,24 @@
+package config
+
+import "time"
+
+// Lockout configures the protection against password guessing. Failed logins are counted per account
+// and per IP address. Once a counter reaches its threshold the account or address is locked, first for
+// BaseDuration, and every further failure doubles the lockout up to MaxDuration.
+type Lockout struct {
+	AccountThreshold int           // LOGIN_LOCKOUT_ACCOUNT_THRESHOLD, failed logins before the account is locked
+	IPThreshold      int           // LOGIN_LOCKOUT_IP_THRESHOLD, failed logins before the IP address is locked
+	BaseDuration     time.Duration // LOGIN_LOCKOUT_BASE_DURATION
+	MaxDuration      time.Duration // LOGIN_LOCKOUT_MAX_DURATION
+	ResetAfter       time.Duration // LOGIN_LOCKOUT_RESET_AFTER, failures are forgotten after this long without new ones
+}
+
+func loadLockout() Lockout {
+	return Lockout{
+		AccountThreshold: getInt("LOGIN_LOCKOUT_ACCOUNT_THRESHOLD", 5),
+		IPThreshold:      getInt("LOGIN_LOCKOUT_IP_THRESHOLD", 20),
+		BaseDuration:     getDuration("LOGIN_LOCKOUT_BASE_DURATION", time.Minute),
+		MaxDuration:      getDuration("LOGIN_LOCKOUT_MAX_DURATION", time.Hour),
+		ResetAfter:       getDuration("LOGIN_LOCKOUT_RESET_AFTER", 24*time.Hour),
+	}
+}
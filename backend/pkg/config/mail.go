@@ -0,0 +1,28 @@
+package config
+
+// Mail configures how outgoing emails are delivered.
+//
+// The "log" driver prints emails to the server log and the "file" driver writes them as .eml files
+// into Dir, both are meant for development. The "smtp" driver sends them through an SMTP server,
+// for local testing a catcher like MailHog on localhost:1025 works without credentials.
+type Mail struct {
+	Driver   string // MAIL_DRIVER, one of log, file or smtp
+	From     string // MAIL_FROM
+	Dir      string // MAIL_DIR, target directory of the file driver
+	Host     string // MAIL_SMTP_HOST
+	Port     int    // MAIL_SMTP_PORT
+	Username string // MAIL_SMTP_USERNAME, leave empty for servers without authentication
+	Password string // MAIL_SMTP_PASSWORD
+}
+
+func loadMail() Mail {
+	return Mail{
+		Driver:   getString("MAIL_DRIVER", "log"),
+		From:     getString("MAIL_FROM", "IrieSphere <no-reply@iriesphere.local>"),
+		Dir:      getString("MAIL_DIR", "./pkg/db/mail"),
+		Host:     getString("MAIL_SMTP_HOST", "localhost"),
+		Port:     getInt("MAIL_SMTP_PORT", 1025),
+		Username: getString("MAIL_SMTP_USERNAME", ""),
+		Password: getString("MAIL_SMTP_PASSWORD", ""),
+	}
+}
@@ -0,0 +1,18 @@
+package config
+
+import "time"
+
+// OAuth configures the OAuth 2.0 authorization server third-party apps use to act on behalf of users.
+type OAuth struct {
+	CodeTTL         time.Duration // OAUTH_CODE_TTL, time to exchange an authorization code for tokens
+	AccessTokenTTL  time.Duration // OAUTH_ACCESS_TOKEN_TTL
+	RefreshTokenTTL time.Duration // OAUTH_REFRESH_TOKEN_TTL, every refresh issues a new refresh token with a new lifetime
+}
+
+func loadOAuth() OAuth {
+	return OAuth{
+		CodeTTL:         getDuration("OAUTH_CODE_TTL", time.Minute),
+		AccessTokenTTL:  getDuration("OAUTH_ACCESS_TOKEN_TTL", time.Hour),
+		RefreshTokenTTL: getDuration("OAUTH_REFRESH_TOKEN_TTL", 30*24*time.Hour),
+	}
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"log"
+	"strings"
+	"time"
+)
+
+// OIDC configures login with external OpenID Connect identity providers.
+// OIDC_PROVIDERS lists the provider names, every provider is configured with variables named after it,
+// e.g. OIDC_GOOGLE_ISSUER for the provider "google".
+type OIDC struct {
+	Providers []OIDCProvider
+	StateTTL  time.Duration // OIDC_STATE_TTL, time to finish the login at the provider
+}
+
+// OIDCProvider is one identity provider, registered there as a confidential or public client.
+type OIDCProvider struct {
+	Name         string   // the name in OIDC_PROVIDERS, used in the login and callback URLs
+	DisplayName  string   // OIDC_<NAME>_DISPLAY_NAME, shown on the login button
+	Issuer       string   // OIDC_<NAME>_ISSUER, the discovery document is read from <issuer>/.well-known/openid-configuration
+	ClientID     string   // OIDC_<NAME>_CLIENT_ID
+	ClientSecret string   // OIDC_<NAME>_CLIENT_SECRET, empty for public clients that only use PKCE
+	Scopes       []string // OIDC_<NAME>_SCOPES, space separated
+	RedirectURL  string   // <backend URL>/api/users/oidc/<name>/callback, has to be registered at the provider
+}
+
+func loadOIDC(app App) OIDC {
+	var providers []OIDCProvider
+	for _, name := range strings.Split(getString("OIDC_PROVIDERS", ""), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider := OIDCProvider{
+			Name:         name,
+			DisplayName:  getString(prefix+"DISPLAY_NAME", name),
+			Issuer:       strings.TrimRight(getString(prefix+"ISSUER", ""), "/"),
+			ClientID:     getString(prefix+"CLIENT_ID", ""),
+			ClientSecret: getString(prefix+"CLIENT_SECRET", ""),
+			Scopes:       strings.Fields(getString(prefix+"SCOPES", "openid email profile")),
+			RedirectURL:  app.BackendURL + "/api/users/oidc/" + name + "/callback",
+		}
+		if !contains(provider.Scopes, "openid") {
+			provider.Scopes = append([]string{"openid"}, provider.Scopes...)
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			log.Printf("OIDC provider %q needs %sISSUER and %sCLIENT_ID, skipping it", name, prefix, prefix)
+			continue
+		}
+		providers = append(providers, provider)
+	}
+	return OIDC{
+		Providers: providers,
+		StateTTL:  getDuration("OIDC_STATE_TTL", 10*time.Minute),
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"log"
+	"regexp"
+	"strings"
+)
+
+// reactionName keeps reaction names short and safe to use as JSON keys and in URLs.
+var reactionName = regexp.MustCompile(`^[a-z_]{1,20}$`)
+
+// Reaction is one of the reactions users can give posts, comments and chat messages.
+type Reaction struct {
+	Name  string `json:"name"`  // stored and sent by the clients, like "love"
+	Emoji string `json:"emoji"` // shown by the clients, like "❤️"
+}
+
+// Reactions configures the reactions users can pick from.
+type Reactions struct {
+	// REACTIONS, comma separated name:emoji pairs in the order the clients show them.
+	// Removing a reaction hides it from the counts, the stored reactions are kept.
+	Types []Reaction
+}
+
+// Allowed reports whether name is one of the configured reactions.
+func (r Reactions) Allowed(name string) bool {
+	for _, reaction := range r.Types {
+		if reaction.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the counts of the configured reactions, never nil so it encodes as {}.
+func (r Reactions) Filter(counts map[string]int) map[string]int {
+	filtered := make(map[string]int, len(counts))
+	for name, count := range counts {
+		if r.Allowed(name) {
+			filtered[name] = count
+		}
+	}
+	return filtered
+}
+
+const defaultReactions = "like:👍,dislike:👎,love:❤️,laugh:😂,wow:😮,sad:😢,angry:😠"
+
+func loadReactions() Reactions {
+	reactions := parseReactions(getString("REACTIONS", defaultReactions))
+	if len(reactions.Types) == 0 {
+		log.Printf("REACTIONS has no valid reactions, using the defaults")
+		return parseReactions(defaultReactions)
+	}
+	return reactions
+}
+
+func parseReactions(value string) Reactions {
+	var reactions Reactions
+	for _, pair := range strings.Split(value, ",") {
+		name, emoji, _ := strings.Cut(strings.TrimSpace(pair), ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !reactionName.MatchString(name) {
+			log.Printf("Invalid reaction name %q in REACTIONS, skipping it", name)
+			continue
+		}
+		if reactions.Allowed(name) {
+			continue
+		}
+		reactions.Types = append(reactions.Types, Reaction{Name: name, Emoji: strings.TrimSpace(emoji)})
+	}
+	return reactions
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"log"
+	"time"
+)
+
+// Registration modes
+const (
+	RegistrationOpen   = "open"   // anyone can sign up, invitation codes are optional
+	RegistrationInvite = "invite" // signing up needs an invitation code
+	RegistrationClosed = "closed" // nobody can sign up
+)
+
+// Registration configures who can create an account and the invitation codes for invite-only registration.
+type Registration struct {
+	Mode string // REGISTRATION_MODE, open, invite or closed
+	// INVITE_QUOTA, the number of unused, unexpired codes a user can have at the same time.
+	// 0 leaves creating codes to admins, who have no quota.
+	InviteQuota   int
+	InviteMaxUses int           // INVITE_MAX_USES, the most signups a code created by a user can be used for
+	InviteCodeTTL time.Duration // INVITE_CODE_TTL, default and, for users, maximum lifetime of a code
+}
+
+func loadRegistration() Registration {
+	mode := getString("REGISTRATION_MODE", RegistrationOpen)
+	if !contains([]string{RegistrationOpen, RegistrationInvite, RegistrationClosed}, mode) {
+		log.Printf("Invalid registration mode %q for REGISTRATION_MODE, using default %s", mode, RegistrationOpen)
+		mode = RegistrationOpen
+	}
+	return Registration{
+		Mode:          mode,
+		InviteQuota:   getInt("INVITE_QUOTA", 5),
+		InviteMaxUses: getInt("INVITE_MAX_USES", 10),
+		InviteCodeTTL: getDuration("INVITE_CODE_TTL", 7*24*time.Hour),
+	}
+}
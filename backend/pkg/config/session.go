@@ -0,0 +1,36 @@
+package config
+
+import "time"
+
+// Session configures how long sessions live.
+//
+// Sessions use a sliding expiration: every authenticated request pushes the expiration time
+// forward by the idle timeout, but never past the absolute timeout counted from the login.
+// "Remember me" logins use their own, longer, pair of timeouts.
+type Session struct {
+	IdleTimeout               time.Duration // SESSION_IDLE_TIMEOUT
+	AbsoluteTimeout           time.Duration // SESSION_ABSOLUTE_TIMEOUT
+	RememberMeIdleTimeout     time.Duration // SESSION_REMEMBER_ME_IDLE_TIMEOUT
+	RememberMeAbsoluteTimeout time.Duration // SESSION_REMEMBER_ME_ABSOLUTE_TIMEOUT
+	RenewAfter                time.Duration // SESSION_RENEW_AFTER, minimum time between two renewals of the same session
+	JanitorInterval           time.Duration // SESSION_JANITOR_INTERVAL, how often expired sessions are removed
+}
+
+func loadSession() Session {
+	return Session{
+		IdleTimeout:               getDuration("SESSION_IDLE_TIMEOUT", 30*time.Minute),
+		AbsoluteTimeout:           getDuration("SESSION_ABSOLUTE_TIMEOUT", 24*time.Hour),
+		RememberMeIdleTimeout:     getDuration("SESSION_REMEMBER_ME_IDLE_TIMEOUT", 14*24*time.Hour),
+		RememberMeAbsoluteTimeout: getDuration("SESSION_REMEMBER_ME_ABSOLUTE_TIMEOUT", 90*24*time.Hour),
+		RenewAfter:                getDuration("SESSION_RENEW_AFTER", time.Minute),
+		JanitorInterval:           getDuration("SESSION_JANITOR_INTERVAL", time.Hour),
+	}
+}
+
+// Timeouts returns the idle and absolute timeout for a session.
+func (s Session) Timeouts(rememberMe bool) (idle, absolute time.Duration) {
+	if rememberMe {
+		return s.RememberMeIdleTimeout, s.RememberMeAbsoluteTimeout
+	}
+	return s.IdleTimeout, s.AbsoluteTimeout
+}
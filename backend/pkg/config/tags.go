@@ -0,0 +1,30 @@
+package config
+
+import (
+	"log"
+	"time"
+)
+
+// Tags configures the trending hashtags.
+type Tags struct {
+	TrendingWindow    time.Duration // TRENDING_TAGS_WINDOW, tags used in posts and comments this long ago are counted
+	MaxTrendingWindow time.Duration // TRENDING_TAGS_MAX_WINDOW, the longest window a request can ask for with ?window=
+	TrendingLimit     int           // TRENDING_TAGS_LIMIT, how many tags are listed
+}
+
+func loadTags() Tags {
+	tags := Tags{
+		TrendingWindow:    getDuration("TRENDING_TAGS_WINDOW", 24*time.Hour),
+		MaxTrendingWindow: getDuration("TRENDING_TAGS_MAX_WINDOW", 30*24*time.Hour),
+		TrendingLimit:     getInt("TRENDING_TAGS_LIMIT", 10),
+	}
+	if tags.TrendingWindow > tags.MaxTrendingWindow {
+		log.Printf("TRENDING_TAGS_WINDOW %s is longer than TRENDING_TAGS_MAX_WINDOW, using %s", tags.TrendingWindow, tags.MaxTrendingWindow)
+		tags.TrendingWindow = tags.MaxTrendingWindow
+	}
+	if tags.TrendingLimit < 1 {
+		log.Printf("Invalid TRENDING_TAGS_LIMIT %d, using default 10", tags.TrendingLimit)
+		tags.TrendingLimit = 10
+	}
+	return tags
+}
@@ -0,0 +1,20 @@
+package config
+
+import "time"
+
+// TwoFactor configures TOTP two-factor authentication.
+type TwoFactor struct {
+	Issuer            string        // TOTP_ISSUER, the name authenticator apps show next to the code
+	ChallengeTTL      time.Duration // MFA_CHALLENGE_TTL, time to enter the code after the password was accepted
+	MaxAttempts       int           // MFA_MAX_ATTEMPTS, wrong codes before the login has to start over
+	RecoveryCodeCount int           // MFA_RECOVERY_CODES, number of recovery codes handed out
+}
+
+func loadTwoFactor() TwoFactor {
+	return TwoFactor{
+		Issuer:            getString("TOTP_ISSUER", "IrieSphere"),
+		ChallengeTTL:      getDuration("MFA_CHALLENGE_TTL", 5*time.Minute),
+		MaxAttempts:       getInt("MFA_MAX_ATTEMPTS", 5),
+		RecoveryCodeCount: getInt("MFA_RECOVERY_CODES", 10),
+	}
+}
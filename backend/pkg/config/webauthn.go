@@ -0,0 +1,35 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// WebAuthn configures passkey login. The relying party ID is the domain passkeys are bound to,
+// the origins are the addresses of the frontend the browser may run the WebAuthn ceremonies on.
+type WebAuthn struct {
+	RPID         string        // WEBAUTHN_RP_ID, defaults to the host of NEXT_PUBLIC_URL
+	RPName       string        // WEBAUTHN_RP_NAME
+	Origins      []string      // WEBAUTHN_ORIGINS, comma separated, defaults to the frontend URL
+	ChallengeTTL time.Duration // WEBAUTHN_CHALLENGE_TTL, time to finish a registration or login
+}
+
+func loadWebAuthn(app App) WebAuthn {
+	rpID := "localhost"
+	if u, err := url.Parse(app.FrontendURL); err == nil && u.Hostname() != "" {
+		rpID = u.Hostname()
+	}
+	var origins []string
+	for _, origin := range strings.Split(getString("WEBAUTHN_ORIGINS", app.FrontendURL), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, strings.TrimRight(origin, "/"))
+		}
+	}
+	return WebAuthn{
+		RPID:         getString("WEBAUTHN_RP_ID", rpID),
+		RPName:       getString("WEBAUTHN_RP_NAME", "IrieSphere"),
+		Origins:      origins,
+		ChallengeTTL: getDuration("WEBAUTHN_CHALLENGE_TTL", 5*time.Minute),
+	}
+}
@@ -0,0 +1,21 @@
+-- Allow several concurrent sessions per user and keep device metadata for each of them
+CREATE TABLE IF NOT EXISTS sessions_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sessionToken TEXT UNIQUE NOT NULL,
+    userID INTEGER NOT NULL,
+    expiresAt TIMESTAMP,
+    userAgent TEXT NOT NULL DEFAULT '',
+    ipAddress TEXT NOT NULL DEFAULT '',
+    createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    lastSeenAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(userID) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO sessions_new (id, sessionToken, userID, expiresAt)
+SELECT id, sessionToken, userID, expiresAt FROM sessions;
+
+DROP TABLE sessions;
+
+ALTER TABLE sessions_new RENAME TO sessions;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(userID);
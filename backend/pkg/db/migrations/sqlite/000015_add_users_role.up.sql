@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL CHECK(role IN ('user', 'admin')) DEFAULT 'user';
@@ -0,0 +1,2 @@
+ALTER TABLE sessions DROP COLUMN absoluteExpiresAt;
+ALTER TABLE sessions DROP COLUMN rememberMe;
@@ -0,0 +1,22 @@
+DROP TABLE IF EXISTS login_throttles;
+
+CREATE TABLE notifications_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    group_id INTEGER,
+    sender_id INTEGER,
+    type TEXT NOT NULL CHECK(type IN ('group', 'friend', 'post')),
+    message TEXT NOT NULL,
+    is_read BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (sender_id) REFERENCES users(id),
+    FOREIGN KEY (group_id) REFERENCES groups(id)
+);
+
+INSERT INTO notifications_old (id, user_id, group_id, sender_id, type, message, is_read, created_at)
+SELECT id, user_id, group_id, sender_id, type, message, is_read, created_at FROM notifications WHERE type != 'security';
+
+DROP TABLE notifications;
+
+ALTER TABLE notifications_old RENAME TO notifications;
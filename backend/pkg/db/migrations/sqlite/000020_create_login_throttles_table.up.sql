@@ -0,0 +1,31 @@
+-- Failed login attempts per account (key is the user ID) and per IP address
+CREATE TABLE IF NOT EXISTS login_throttles (
+    scope TEXT NOT NULL CHECK(scope IN ('account', 'ip')),
+    key TEXT NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP,
+    locked_until TIMESTAMP,
+    PRIMARY KEY (scope, key)
+);
+
+-- Security notifications, for example about account lockouts, need their own notification type
+CREATE TABLE notifications_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    group_id INTEGER,
+    sender_id INTEGER,
+    type TEXT NOT NULL CHECK(type IN ('group', 'friend', 'post', 'security')),
+    message TEXT NOT NULL,
+    is_read BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (sender_id) REFERENCES users(id),
+    FOREIGN KEY (group_id) REFERENCES groups(id)
+);
+
+INSERT INTO notifications_new (id, user_id, group_id, sender_id, type, message, is_read, created_at)
+SELECT id, user_id, group_id, sender_id, type, message, is_read, created_at FROM notifications;
+
+DROP TABLE notifications;
+
+ALTER TABLE notifications_new RENAME TO notifications;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_personal_tokens_user_id;
+DROP TABLE IF EXISTS personal_tokens;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_oauth_tokens_user_id;
+DROP TABLE IF EXISTS oauth_tokens;
+DROP TABLE IF EXISTS oauth_codes;
+DROP TABLE IF EXISTS oauth_clients;
@@ -0,0 +1,4 @@
+ALTER TABLE oidc_states DROP COLUMN invite_code;
+ALTER TABLE users DROP COLUMN invite_code_id;
+ALTER TABLE users DROP COLUMN invited_by;
+DROP TABLE IF EXISTS invite_codes;
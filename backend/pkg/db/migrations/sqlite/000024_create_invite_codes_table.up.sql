@@ -0,0 +1,20 @@
+-- Invitation codes for invite-only registration, max_uses 0 means unlimited
+CREATE TABLE IF NOT EXISTS invite_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code TEXT NOT NULL UNIQUE,
+    creator_id INTEGER NOT NULL,
+    max_uses INTEGER NOT NULL DEFAULT 1,
+    uses INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (creator_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_invite_codes_creator_id ON invite_codes(creator_id);
+
+-- Who invited a user and with which code
+ALTER TABLE users ADD COLUMN invited_by INTEGER;
+ALTER TABLE users ADD COLUMN invite_code_id INTEGER;
+
+-- Invitation code of a user signing up with an OpenID Connect provider
+ALTER TABLE oidc_states ADD COLUMN invite_code TEXT NOT NULL DEFAULT '';
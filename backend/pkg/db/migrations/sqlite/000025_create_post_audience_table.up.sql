@@ -0,0 +1,10 @@
+-- Users who can see a post with the 'custom' privacy setting, besides its author
+CREATE TABLE IF NOT EXISTS post_audience (
+    post_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    PRIMARY KEY (post_id, user_id),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_audience_user_id ON post_audience(user_id);
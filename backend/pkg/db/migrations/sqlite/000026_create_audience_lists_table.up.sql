@@ -0,0 +1,30 @@
+-- Named lists of friends a user can pick as the audience of custom posts
+CREATE TABLE IF NOT EXISTS audience_lists (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (owner_id, name),
+    FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS audience_list_members (
+    list_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    PRIMARY KEY (list_id, user_id),
+    FOREIGN KEY (list_id) REFERENCES audience_lists(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_audience_list_members_user_id ON audience_list_members(user_id);
+
+-- Lists in the audience of a custom post, the current members of a list can see the post
+CREATE TABLE IF NOT EXISTS post_audience_lists (
+    post_id INTEGER NOT NULL,
+    list_id INTEGER NOT NULL,
+    PRIMARY KEY (post_id, list_id),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (list_id) REFERENCES audience_lists(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_audience_lists_list_id ON post_audience_lists(list_id);
@@ -0,0 +1,4 @@
+-- Feed pages are ordered by (created_at, id), newest first
+CREATE INDEX IF NOT EXISTS idx_posts_created_at_id ON posts(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_posts_user_id_created_at_id ON posts(user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_posts_group_id_created_at_id ON posts(group_id, created_at, id);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_votes_comment_id_user_id;
+DROP INDEX IF EXISTS idx_votes_post_id_user_id;
+ALTER TABLE comments DROP COLUMN dislikes;
+ALTER TABLE comments DROP COLUMN likes;
+ALTER TABLE posts DROP COLUMN dislikes;
+ALTER TABLE posts DROP COLUMN likes;
@@ -0,0 +1,15 @@
+-- Likes and dislikes are counted on the posts and comments, VoteItem keeps them in step with the votes table
+ALTER TABLE posts ADD COLUMN likes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN dislikes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE comments ADD COLUMN likes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE comments ADD COLUMN dislikes INTEGER NOT NULL DEFAULT 0;
+
+UPDATE posts SET
+    likes = (SELECT COUNT(*) FROM votes WHERE votes.postID = posts.id AND votes.type = 'like'),
+    dislikes = (SELECT COUNT(*) FROM votes WHERE votes.postID = posts.id AND votes.type = 'dislike');
+UPDATE comments SET
+    likes = (SELECT COUNT(*) FROM votes WHERE votes.commentID = comments.id AND votes.type = 'like'),
+    dislikes = (SELECT COUNT(*) FROM votes WHERE votes.commentID = comments.id AND votes.type = 'dislike');
+
+CREATE INDEX IF NOT EXISTS idx_votes_post_id_user_id ON votes(postID, userID);
+CREATE INDEX IF NOT EXISTS idx_votes_comment_id_user_id ON votes(commentID, userID);
@@ -0,0 +1,31 @@
+-- Only likes and dislikes of posts and comments fit into the votes table, other reactions are lost
+CREATE TABLE IF NOT EXISTS votes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT NOT NULL CHECK(type IN ('like', 'dislike')),
+    userID INTEGER NOT NULL,
+    postID INTEGER,
+    commentID INTEGER,
+    FOREIGN KEY (commentID) REFERENCES comments(id) ON DELETE CASCADE,
+    FOREIGN KEY (userID) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (postID) REFERENCES posts(id) ON DELETE CASCADE
+);
+INSERT INTO votes (type, userID, postID)
+    SELECT reaction, user_id, item_id FROM reactions WHERE item_type = 'post' AND reaction IN ('like', 'dislike') ORDER BY id;
+INSERT INTO votes (type, userID, commentID)
+    SELECT reaction, user_id, item_id FROM reactions WHERE item_type = 'comment' AND reaction IN ('like', 'dislike') ORDER BY id;
+CREATE INDEX IF NOT EXISTS idx_votes_post_id_user_id ON votes(postID, userID);
+CREATE INDEX IF NOT EXISTS idx_votes_comment_id_user_id ON votes(commentID, userID);
+
+ALTER TABLE posts ADD COLUMN likes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN dislikes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE comments ADD COLUMN likes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE comments ADD COLUMN dislikes INTEGER NOT NULL DEFAULT 0;
+UPDATE posts SET
+    likes = (SELECT COUNT(*) FROM votes WHERE votes.postID = posts.id AND votes.type = 'like'),
+    dislikes = (SELECT COUNT(*) FROM votes WHERE votes.postID = posts.id AND votes.type = 'dislike');
+UPDATE comments SET
+    likes = (SELECT COUNT(*) FROM votes WHERE votes.commentID = comments.id AND votes.type = 'like'),
+    dislikes = (SELECT COUNT(*) FROM votes WHERE votes.commentID = comments.id AND votes.type = 'dislike');
+
+DROP TABLE IF EXISTS reaction_counts;
+DROP TABLE IF EXISTS reactions;
@@ -0,0 +1,35 @@
+-- One reaction per user on a post, comment or chat message, the allowed reactions are configured with REACTIONS
+CREATE TABLE IF NOT EXISTS reactions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    item_type TEXT NOT NULL CHECK(item_type IN ('post', 'comment', 'message')),
+    item_id INTEGER NOT NULL,
+    reaction TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (item_type, item_id, user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Counters per item and reaction, ReactionRepository.React keeps them in step with the reactions table
+CREATE TABLE IF NOT EXISTS reaction_counts (
+    item_type TEXT NOT NULL,
+    item_id INTEGER NOT NULL,
+    reaction TEXT NOT NULL,
+    count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (item_type, item_id, reaction)
+);
+
+INSERT OR IGNORE INTO reactions (user_id, item_type, item_id, reaction)
+    SELECT userID, 'post', postID, type FROM votes WHERE postID IS NOT NULL ORDER BY id;
+INSERT OR IGNORE INTO reactions (user_id, item_type, item_id, reaction)
+    SELECT userID, 'comment', commentID, type FROM votes WHERE commentID IS NOT NULL AND postID IS NULL ORDER BY id;
+INSERT INTO reaction_counts (item_type, item_id, reaction, count)
+    SELECT item_type, item_id, reaction, COUNT(*) FROM reactions GROUP BY item_type, item_id, reaction;
+
+DROP INDEX IF EXISTS idx_votes_comment_id_user_id;
+DROP INDEX IF EXISTS idx_votes_post_id_user_id;
+DROP TABLE IF EXISTS votes;
+ALTER TABLE comments DROP COLUMN dislikes;
+ALTER TABLE comments DROP COLUMN likes;
+ALTER TABLE posts DROP COLUMN dislikes;
+ALTER TABLE posts DROP COLUMN likes;
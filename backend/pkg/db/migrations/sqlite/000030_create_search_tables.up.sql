@@ -0,0 +1,67 @@
+-- Full-text search indexes, kept in sync with their tables by triggers. They need SQLite with FTS5,
+-- the backend has to be built with -tags sqlite_fts5. Emails and other private columns are not indexed.
+
+CREATE VIRTUAL TABLE IF NOT EXISTS posts_fts USING fts5(title, content, content='posts', content_rowid='id', tokenize='unicode61 remove_diacritics 2');
+CREATE TRIGGER IF NOT EXISTS posts_fts_insert AFTER INSERT ON posts BEGIN
+    INSERT INTO posts_fts (rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
+CREATE TRIGGER IF NOT EXISTS posts_fts_delete AFTER DELETE ON posts BEGIN
+    INSERT INTO posts_fts (posts_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+END;
+CREATE TRIGGER IF NOT EXISTS posts_fts_update AFTER UPDATE OF title, content ON posts BEGIN
+    INSERT INTO posts_fts (posts_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+    INSERT INTO posts_fts (rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
+INSERT INTO posts_fts (posts_fts) VALUES ('rebuild');
+
+CREATE VIRTUAL TABLE IF NOT EXISTS comments_fts USING fts5(content, content='comments', content_rowid='id', tokenize='unicode61 remove_diacritics 2');
+CREATE TRIGGER IF NOT EXISTS comments_fts_insert AFTER INSERT ON comments BEGIN
+    INSERT INTO comments_fts (rowid, content) VALUES (new.id, new.content);
+END;
+CREATE TRIGGER IF NOT EXISTS comments_fts_delete AFTER DELETE ON comments BEGIN
+    INSERT INTO comments_fts (comments_fts, rowid, content) VALUES ('delete', old.id, old.content);
+END;
+CREATE TRIGGER IF NOT EXISTS comments_fts_update AFTER UPDATE OF content ON comments BEGIN
+    INSERT INTO comments_fts (comments_fts, rowid, content) VALUES ('delete', old.id, old.content);
+    INSERT INTO comments_fts (rowid, content) VALUES (new.id, new.content);
+END;
+INSERT INTO comments_fts (comments_fts) VALUES ('rebuild');
+
+CREATE VIRTUAL TABLE IF NOT EXISTS users_fts USING fts5(username, first_name, last_name, about_me, content='users', content_rowid='id', tokenize='unicode61 remove_diacritics 2');
+CREATE TRIGGER IF NOT EXISTS users_fts_insert AFTER INSERT ON users BEGIN
+    INSERT INTO users_fts (rowid, username, first_name, last_name, about_me) VALUES (new.id, new.username, new.first_name, new.last_name, new.about_me);
+END;
+CREATE TRIGGER IF NOT EXISTS users_fts_delete AFTER DELETE ON users BEGIN
+    INSERT INTO users_fts (users_fts, rowid, username, first_name, last_name, about_me) VALUES ('delete', old.id, old.username, old.first_name, old.last_name, old.about_me);
+END;
+CREATE TRIGGER IF NOT EXISTS users_fts_update AFTER UPDATE OF username, first_name, last_name, about_me ON users BEGIN
+    INSERT INTO users_fts (users_fts, rowid, username, first_name, last_name, about_me) VALUES ('delete', old.id, old.username, old.first_name, old.last_name, old.about_me);
+    INSERT INTO users_fts (rowid, username, first_name, last_name, about_me) VALUES (new.id, new.username, new.first_name, new.last_name, new.about_me);
+END;
+INSERT INTO users_fts (users_fts) VALUES ('rebuild');
+
+CREATE VIRTUAL TABLE IF NOT EXISTS groups_fts USING fts5(title, description, content='groups', content_rowid='id', tokenize='unicode61 remove_diacritics 2');
+CREATE TRIGGER IF NOT EXISTS groups_fts_insert AFTER INSERT ON groups BEGIN
+    INSERT INTO groups_fts (rowid, title, description) VALUES (new.id, new.title, new.description);
+END;
+CREATE TRIGGER IF NOT EXISTS groups_fts_delete AFTER DELETE ON groups BEGIN
+    INSERT INTO groups_fts (groups_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+END;
+CREATE TRIGGER IF NOT EXISTS groups_fts_update AFTER UPDATE OF title, description ON groups BEGIN
+    INSERT INTO groups_fts (groups_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+    INSERT INTO groups_fts (rowid, title, description) VALUES (new.id, new.title, new.description);
+END;
+INSERT INTO groups_fts (groups_fts) VALUES ('rebuild');
+
+CREATE VIRTUAL TABLE IF NOT EXISTS events_fts USING fts5(title, description, location, content='events', content_rowid='id', tokenize='unicode61 remove_diacritics 2');
+CREATE TRIGGER IF NOT EXISTS events_fts_insert AFTER INSERT ON events BEGIN
+    INSERT INTO events_fts (rowid, title, description, location) VALUES (new.id, new.title, new.description, new.location);
+END;
+CREATE TRIGGER IF NOT EXISTS events_fts_delete AFTER DELETE ON events BEGIN
+    INSERT INTO events_fts (events_fts, rowid, title, description, location) VALUES ('delete', old.id, old.title, old.description, old.location);
+END;
+CREATE TRIGGER IF NOT EXISTS events_fts_update AFTER UPDATE OF title, description, location ON events BEGIN
+    INSERT INTO events_fts (events_fts, rowid, title, description, location) VALUES ('delete', old.id, old.title, old.description, old.location);
+    INSERT INTO events_fts (rowid, title, description, location) VALUES (new.id, new.title, new.description, new.location);
+END;
+INSERT INTO events_fts (events_fts) VALUES ('rebuild');
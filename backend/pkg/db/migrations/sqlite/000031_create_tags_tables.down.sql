@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS comment_tags;
+DROP TABLE IF EXISTS post_tags;
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,37 @@
+-- @username mentions in posts, comments and chat messages
+CREATE TABLE IF NOT EXISTS mentions (
+    item_type TEXT NOT NULL CHECK(item_type IN ('post', 'comment', 'message')),
+    item_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL, -- the mentioned user
+    username TEXT NOT NULL, -- as written after the @, so clients can find the mention in the text
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (item_type, item_id, user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX IF NOT EXISTS idx_mentions_user_id ON mentions(user_id);
+
+-- Mentioned users get a mention notification, which links to the post and the comment
+CREATE TABLE notifications_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    group_id INTEGER,
+    sender_id INTEGER,
+    type TEXT NOT NULL CHECK(type IN ('group', 'friend', 'post', 'security', 'mention')),
+    message TEXT NOT NULL,
+    is_read BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    post_id INTEGER,
+    comment_id INTEGER,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (sender_id) REFERENCES users(id),
+    FOREIGN KEY (group_id) REFERENCES groups(id),
+    FOREIGN KEY (post_id) REFERENCES posts(id),
+    FOREIGN KEY (comment_id) REFERENCES comments(id)
+);
+
+INSERT INTO notifications_new (id, user_id, group_id, sender_id, type, message, is_read, created_at)
+SELECT id, user_id, group_id, sender_id, type, message, is_read, created_at FROM notifications;
+
+DROP TABLE notifications;
+
+ALTER TABLE notifications_new RENAME TO notifications;
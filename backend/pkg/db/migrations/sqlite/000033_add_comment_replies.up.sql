@@ -0,0 +1,5 @@
+-- Replies to comments: parent_id is the comment replied to, NULL for comments on the post,
+-- depth counts the comments above, 0 for comments on the post
+ALTER TABLE comments ADD COLUMN parent_id INTEGER;
+ALTER TABLE comments ADD COLUMN depth INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments(parent_id);
@@ -0,0 +1,10 @@
+-- Previous versions of edited comments, one row per edit
+CREATE TABLE IF NOT EXISTS comment_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    comment_id INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    written_at TIMESTAMP NOT NULL, -- when this version was written, the comment's updated_at before the edit
+    replaced_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, -- when the edit replaced it
+    FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_comment_revisions_comment_id ON comment_revisions(comment_id);
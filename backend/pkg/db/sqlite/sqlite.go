@@ -24,7 +24,10 @@ func createURL(path, prefix string) string {
 func ConnectAndMigrate(dbPath string, migrationsPath string) (*sql.DB, error) {
 	fmt.Printf("Connecting to SQLite database at path: %s\n", dbPath)
 
-	db, err := sql.Open("sqlite3", dbPath)
+	// Transactions take the write lock when they begin, so two transactions that read and then write,
+	// like two reactions of one user, can't both read the old state. The second one waits for the lock
+	// instead of failing with "database is locked".
+	db, err := sql.Open("sqlite3", dbPath+"?_txlock=immediate&_busy_timeout=5000")
 	if err != nil {
 		fmt.Println("Could not connect to SQLite database:", err)
 		return nil, err
@@ -37,6 +40,12 @@ func ConnectAndMigrate(dbPath string, migrationsPath string) (*sql.DB, error) {
 
 	fmt.Println("Connected to SQLite database successfully.")
 
+	// The search tables use FTS5, which go-sqlite3 only compiles in with the sqlite_fts5 build tag
+	var fts5 bool
+	if err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err != nil || !fts5 {
+		return nil, fmt.Errorf("SQLite is built without FTS5, build the backend with -tags sqlite_fts5")
+	}
+
 	migrationsURL := createURL(migrationsPath, "file")
 	dbURL := createURL(dbPath, "sqlite")
 
@@ -0,0 +1,340 @@
+package handler
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/config"
+	"backend/pkg/mail"
+	"backend/pkg/model"
+	"backend/pkg/repository"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AccountHandler handles the account credentials: password reset and change, email verification and change.
+// Links sent by email are single use and expire after the time set in the account configuration.
+type AccountHandler struct {
+	userRepo      *repository.UserRepository
+	tokenRepo     *repository.UserTokenRepository
+	sessionRepo   *repository.SessionRepository
+	mailer        mail.Mailer
+	notifications *NotificationHandler
+	app           config.App
+	config        config.Account
+}
+
+func NewAccountHandler(uRepo *repository.UserRepository, tRepo *repository.UserTokenRepository, sRepo *repository.SessionRepository, mailer mail.Mailer, notifications *NotificationHandler, app config.App, config config.Account) *AccountHandler {
+	return &AccountHandler{userRepo: uRepo, tokenRepo: tRepo, sessionRepo: sRepo, mailer: mailer, notifications: notifications, app: app, config: config}
+}
+
+// SendVerificationEmail sends the user a link that confirms the email address. Earlier links stop working.
+func (h *AccountHandler) SendVerificationEmail(userID int) error {
+	email, username, _, err := h.userRepo.GetUserEmailByID(userID)
+	if err != nil {
+		return err
+	}
+	token, hash, err := auth.GenerateToken()
+	if err != nil {
+		return err
+	}
+	err = h.tokenRepo.CreateToken(userID, model.TokenPurposeEmailVerification, hash, time.Now().Add(h.config.EmailVerificationTTL))
+	if err != nil {
+		return err
+	}
+
+	link := h.app.FrontendURL + "/auth/verify-email?token=" + url.QueryEscape(token)
+	return h.mailer.Send(mail.Message{
+		To:      email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nplease confirm your email address by opening the link below:\n\n%s\n\nThe link is valid for %s.\n",
+			username, link, h.config.EmailVerificationTTL),
+	})
+}
+
+// ForgotPasswordHandler sends a password reset link to the user with the given email address or username.
+// The response is the same whether the account exists or not, so it can't be used to find out registered addresses.
+func (h *AccountHandler) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var data model.ForgotPasswordData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Error parsing JSON data: "+err.Error())
+		return
+	}
+
+	user, err := h.userRepo.GetUserByEmailOrNickname(data.Email)
+	if err == nil {
+		if err := h.sendPasswordResetEmail(user); err != nil {
+			log.Println("Error sending password reset email: ", err)
+		}
+	} else if err != sql.ErrNoRows {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting user: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "If an account with this email address exists, a password reset link has been sent",
+	})
+}
+
+func (h *AccountHandler) sendPasswordResetEmail(user model.User) error {
+	token, hash, err := auth.GenerateToken()
+	if err != nil {
+		return err
+	}
+	err = h.tokenRepo.CreateToken(user.Id, model.TokenPurposePasswordReset, hash, time.Now().Add(h.config.PasswordResetTTL))
+	if err != nil {
+		return err
+	}
+
+	link := h.app.FrontendURL + "/auth/reset-password?token=" + url.QueryEscape(token)
+	return h.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nsomeone asked to reset the password of your account. Open the link below to choose a new password:\n\n%s\n\n"+
+			"The link is valid for %s. If you didn't ask for this, you can ignore this email.\n",
+			user.Username, link, h.config.PasswordResetTTL),
+	})
+}
+
+// ResetPasswordHandler sets a new password using the token from the password reset email.
+// All sessions of the user are ended, so whoever knew the old password is logged out.
+// Receiving the email proves the address, so it is marked as verified as well.
+func (h *AccountHandler) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var data model.ResetPasswordData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Error parsing JSON data: "+err.Error())
+		return
+	}
+	if data.Password == "" {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Password is required")
+		return
+	}
+
+	userID, err := h.tokenRepo.ConsumeToken(model.TokenPurposePasswordReset, auth.HashToken(data.Token))
+	if err == sql.ErrNoRows {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Invalid or expired password reset link")
+		return
+	} else if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error checking token: "+err.Error())
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(data.Password), bcrypt.DefaultCost)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error hashing password")
+		return
+	}
+	if err := h.userRepo.UpdatePassword(userID, string(hashedPassword)); err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error updating password: "+err.Error())
+		return
+	}
+	if _, err := h.sessionRepo.DeleteAllSessionsByUserID(userID); err != nil {
+		log.Println("Error ending sessions after password reset: ", err)
+	}
+	if err := h.userRepo.MarkEmailVerified(userID); err != nil {
+		log.Println("Error marking email as verified: ", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Password reset successful",
+	})
+}
+
+// VerifyEmailHandler confirms the email address of the user with the token from the verification email.
+func (h *AccountHandler) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var data model.VerifyEmailData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Error parsing JSON data: "+err.Error())
+		return
+	}
+
+	err := h.verifyEmail(data.Token)
+	if err == sql.ErrNoRows {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Invalid or expired verification link")
+		return
+	} else if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error verifying email: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Email address verified",
+	})
+}
+
+// VerifyEmailLinkHandler is the target of the links in verification emails sent before they pointed to the frontend.
+// It doesn't use up the token, mail scanners and link prefetchers open links too: it redirects to the frontend page
+// that sends the token to VerifyEmailHandler when the user confirms.
+func (h *AccountHandler) VerifyEmailLinkHandler(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, h.app.FrontendURL+"/auth/verify-email?token="+url.QueryEscape(r.URL.Query().Get("token")), http.StatusSeeOther)
+}
+
+func (h *AccountHandler) verifyEmail(token string) error {
+	userID, err := h.tokenRepo.ConsumeToken(model.TokenPurposeEmailVerification, auth.HashToken(token))
+	if err != nil {
+		return err
+	}
+	return h.userRepo.MarkEmailVerified(userID)
+}
+
+// ResendVerificationEmailHandler sends the authenticated user a new verification link.
+func (h *AccountHandler) ResendVerificationEmailHandler(w http.ResponseWriter, r *http.Request) {
+	identity, ok := auth.IdentityFromContext(r.Context())
+	if !ok {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	if identity.EmailVerified {
+		auth.WriteJSONError(w, http.StatusConflict, "Email address already verified")
+		return
+	}
+
+	if err := h.SendVerificationEmail(identity.UserID); err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error sending verification email: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Verification email sent",
+	})
+}
+
+// ChangePasswordHandler sets a new password for the authenticated user after checking the current one.
+// All other sessions of the user are ended, only the device that changed the password stays logged in.
+func (h *AccountHandler) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	identity, ok := auth.IdentityFromContext(r.Context())
+	if !ok {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var data model.ChangePasswordData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Error parsing JSON data: "+err.Error())
+		return
+	}
+	if data.NewPassword == "" {
+		auth.WriteJSONError(w, http.StatusBadRequest, "New password is required")
+		return
+	}
+	if !h.checkPassword(w, identity.UserID, data.CurrentPassword) {
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(data.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error hashing password")
+		return
+	}
+	if err := h.userRepo.UpdatePassword(identity.UserID, string(hashedPassword)); err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error updating password: "+err.Error())
+		return
+	}
+	revoked, err := h.sessionRepo.DeleteOtherSessions(identity.UserID, identity.SessionID)
+	if err != nil {
+		log.Println("Error ending other sessions after password change: ", err)
+	}
+	if err := h.notifications.CreateSecurityNotification(identity.UserID, "Your password was changed"); err != nil {
+		log.Println("Error creating security notification: ", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Password changed",
+		"revoked": revoked,
+	})
+}
+
+// ChangeEmailHandler changes the email address of the authenticated user after checking the password.
+// The new address has to be verified again, and the old address is told about the change.
+func (h *AccountHandler) ChangeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	identity, ok := auth.IdentityFromContext(r.Context())
+	if !ok {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var data model.ChangeEmailData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Error parsing JSON data: "+err.Error())
+		return
+	}
+	data.Email = strings.TrimSpace(data.Email)
+	if _, err := netmail.ParseAddress(data.Email); err != nil || strings.ContainsAny(data.Email, "<> ") {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Invalid email address")
+		return
+	}
+	if !h.checkPassword(w, identity.UserID, data.CurrentPassword) {
+		return
+	}
+
+	oldEmail, username, _, err := h.userRepo.GetUserEmailByID(identity.UserID)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting user: "+err.Error())
+		return
+	}
+	if strings.EqualFold(oldEmail, data.Email) {
+		auth.WriteJSONError(w, http.StatusConflict, "This is already your email address")
+		return
+	}
+	// Usernames and email addresses share one namespace, since both can be used to log in
+	taken, err := h.userRepo.IsUsernameOrEmailTaken(data.Email, identity.UserID)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error checking email address: "+err.Error())
+		return
+	}
+	if taken {
+		auth.WriteJSONError(w, http.StatusConflict, "Email address already in use")
+		return
+	}
+
+	if err := h.userRepo.UpdateEmail(identity.UserID, data.Email); err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error updating email address: "+err.Error())
+		return
+	}
+	if err := h.SendVerificationEmail(identity.UserID); err != nil {
+		log.Println("Error sending verification email: ", err)
+	}
+	err = h.mailer.Send(mail.Message{
+		To:      oldEmail,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf("Hi %s,\n\nthe email address of your account was changed to %s. "+
+			"If you didn't do this, reset your password and contact us.\n", username, data.Email),
+	})
+	if err != nil {
+		log.Println("Error notifying old email address: ", err)
+	}
+	if err := h.notifications.CreateSecurityNotification(identity.UserID, "Your email address was changed to "+data.Email); err != nil {
+		log.Println("Error creating security notification: ", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Email address changed, please confirm the new address",
+	})
+}
+
+// checkPassword compares the password with the one of the user and writes the error response if it doesn't match.
+func (h *AccountHandler) checkPassword(w http.ResponseWriter, userID int, password string) bool {
+	hashedPassword, err := h.userRepo.GetUserPasswordByID(userID)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting user: "+err.Error())
+		return false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)) != nil {
+		auth.WriteJSONError(w, http.StatusForbidden, "Current password is incorrect")
+		return false
+	}
+	return true
+}
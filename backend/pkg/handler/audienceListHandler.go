@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/model"
+	"backend/pkg/repository"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// maxAudienceListNameLength limits the names of audience lists
+const maxAudienceListNameLength = 50
+
+// AudienceListHandler manages the named lists of friends users pick as the audience of custom posts.
+type AudienceListHandler struct {
+	listRepo    *repository.AudienceListRepository
+	friendsRepo *repository.FriendsRepository
+}
+
+func NewAudienceListHandler(listRepo *repository.AudienceListRepository, friendsRepo *repository.FriendsRepository) *AudienceListHandler {
+	return &AudienceListHandler{listRepo: listRepo, friendsRepo: friendsRepo}
+}
+
+// GetAudienceListsHandler lists the audience lists of the authenticated user with their members.
+func (h *AudienceListHandler) GetAudienceListsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	lists, err := h.listRepo.GetListsByOwnerID(userID)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting audience lists: "+err.Error())
+		return
+	}
+	if lists == nil {
+		lists = []model.AudienceList{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lists)
+}
+
+// GetAudienceListHandler returns one audience list of the authenticated user.
+func (h *AudienceListHandler) GetAudienceListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := h.ownList(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// CreateAudienceListHandler creates a list from a name and the IDs of accepted friends.
+func (h *AudienceListHandler) CreateAudienceListHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	var data model.AudienceListData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Error parsing JSON data: "+err.Error())
+		return
+	}
+	if data.Name == nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "The list needs a name")
+		return
+	}
+	members := []int{}
+	if data.Members != nil {
+		members = *data.Members
+	}
+
+	name, members, status, err := h.checkList(userID, 0, *data.Name, members)
+	if err != nil {
+		auth.WriteJSONError(w, status, err.Error())
+		return
+	}
+	id, err := h.listRepo.CreateList(userID, name, members)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error saving audience list: "+err.Error())
+		return
+	}
+	list, err := h.listRepo.GetListByID(int(id))
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting audience list: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(list)
+}
+
+// UpdateAudienceListHandler renames a list or replaces its members. Membership changes also apply
+// to the posts already shared with the list.
+func (h *AudienceListHandler) UpdateAudienceListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := h.ownList(w, r)
+	if !ok {
+		return
+	}
+	var data model.AudienceListData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Error parsing JSON data: "+err.Error())
+		return
+	}
+
+	name, members := list.Name, list.Members
+	if data.Name != nil {
+		name = *data.Name
+	}
+	if data.Members != nil {
+		members = *data.Members
+	}
+	name, members, status, err := h.checkList(list.OwnerID, list.Id, name, members)
+	if err != nil {
+		auth.WriteJSONError(w, status, err.Error())
+		return
+	}
+	if data.Name != nil {
+		data.Name = &name
+	}
+	if data.Members != nil {
+		data.Members = &members
+	}
+	if err := h.listRepo.UpdateList(list.Id, data.Name, data.Members); err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error saving audience list: "+err.Error())
+		return
+	}
+	list, err = h.listRepo.GetListByID(list.Id)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting audience list: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// DeleteAudienceListHandler deletes a list, its members lose access to the posts shared with it.
+func (h *AudienceListHandler) DeleteAudienceListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := h.ownList(w, r)
+	if !ok {
+		return
+	}
+	if err := h.listRepo.DeleteList(list.Id); err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error deleting audience list: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Audience list deleted",
+	})
+}
+
+// ownList loads the list in the path, answering 404 when it doesn't belong to the authenticated user.
+func (h *AudienceListHandler) ownList(w http.ResponseWriter, r *http.Request) (model.AudienceList, bool) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return model.AudienceList{}, false
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Invalid audience list ID")
+		return model.AudienceList{}, false
+	}
+	list, err := h.listRepo.GetListByID(id)
+	if err == sql.ErrNoRows || (err == nil && list.OwnerID != userID) {
+		auth.WriteJSONError(w, http.StatusNotFound, "Audience list not found")
+		return model.AudienceList{}, false
+	} else if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting audience list: "+err.Error())
+		return model.AudienceList{}, false
+	}
+	return list, true
+}
+
+// checkList validates the name and members of a list. Names are unique per user and members must be
+// accepted friends, duplicate members are dropped. On error it also returns the status code to answer with.
+func (h *AudienceListHandler) checkList(ownerID, listID int, name string, members []int) (string, []int, int, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxAudienceListNameLength {
+		return "", nil, http.StatusBadRequest, fmt.Errorf("List names must be 1 to %d characters long", maxAudienceListNameLength)
+	}
+	exists, err := h.listRepo.ListNameExists(ownerID, name, listID)
+	if err != nil {
+		return "", nil, http.StatusInternalServerError, fmt.Errorf("Error checking audience lists: %v", err)
+	}
+	if exists {
+		return "", nil, http.StatusConflict, fmt.Errorf("You already have a list named %q", name)
+	}
+
+	isFriend, err := friendSet(h.friendsRepo, ownerID)
+	if err != nil {
+		return "", nil, http.StatusInternalServerError, fmt.Errorf("Failed to retrieve friends: %v", err)
+	}
+	checked, err := onlyFriends(isFriend, members, "list")
+	if err != nil {
+		return "", nil, http.StatusBadRequest, err
+	}
+	return name, checked, 0, nil
+}
+
+// friendSet returns the accepted friends of the user as a set.
+func friendSet(friendsRepo *repository.FriendsRepository, userID int) (map[int]bool, error) {
+	friends, err := friendsRepo.GetFriends(userID)
+	if err != nil {
+		return nil, err
+	}
+	isFriend := make(map[int]bool, len(friends))
+	for _, friend := range friends {
+		isFriend[friend.UserID] = true
+	}
+	return isFriend, nil
+}
+
+// onlyFriends drops duplicate user IDs and fails if one of them is not a friend, what names the list or
+// audience they were added to for the error message.
+func onlyFriends(isFriend map[int]bool, userIDs []int, what string) ([]int, error) {
+	checked := []int{}
+	seen := make(map[int]bool, len(userIDs))
+	for _, id := range userIDs {
+		if seen[id] {
+			continue
+		}
+		if !isFriend[id] {
+			return nil, fmt.Errorf("User %d is not your friend and can't be in the %s", id, what)
+		}
+		seen[id] = true
+		checked = append(checked, id)
+	}
+	return checked, nil
+}
@@ -1,10 +1,14 @@
 package handler
 
 import (
+	"backend/pkg/auth"
+	"backend/pkg/config"
 	"backend/pkg/model"
 	"backend/pkg/repository"
 	"backend/util"
+	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"os"
 	"strconv"
@@ -19,11 +23,13 @@ type CommentHandler struct {
 	notificationHandler *NotificationHandler
 	postRepo            *repository.PostRepository
 	userRepo            *repository.UserRepository
-	VoteHandler         *VoteHandler
+	reactionHandler     *ReactionHandler
+	mentionHandler      *MentionHandler
+	comments            config.Comments
 }
 
-func NewCommentHandler(commentRepo *repository.CommentRepository, sessionRepo *repository.SessionRepository, notificationHandler *NotificationHandler, postRepo *repository.PostRepository, userRepo *repository.UserRepository, voteHandler *VoteHandler) *CommentHandler {
-	return &CommentHandler{commentRepo: commentRepo, sessionRepo: sessionRepo, notificationHandler: notificationHandler, postRepo: postRepo, userRepo: userRepo, VoteHandler: voteHandler}
+func NewCommentHandler(commentRepo *repository.CommentRepository, sessionRepo *repository.SessionRepository, notificationHandler *NotificationHandler, postRepo *repository.PostRepository, userRepo *repository.UserRepository, reactionHandler *ReactionHandler, mentionHandler *MentionHandler, comments config.Comments) *CommentHandler {
+	return &CommentHandler{commentRepo: commentRepo, sessionRepo: sessionRepo, notificationHandler: notificationHandler, postRepo: postRepo, userRepo: userRepo, reactionHandler: reactionHandler, mentionHandler: mentionHandler, comments: comments}
 }
 
 func (h *CommentHandler) CreateCommentHandler(w http.ResponseWriter, r *http.Request) {
@@ -43,16 +49,51 @@ func (h *CommentHandler) CreateCommentHandler(w http.ResponseWriter, r *http.Req
 		http.Error(w, "Error decoding id for comment request: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "User not authenticated: "+err.Error(), http.StatusUnauthorized)
 		return
 	}
+	// Only users who can see the post can comment on it
+	canSee, err := h.postRepo.CanUserSeePost(intPostId, userID)
+	if err != nil {
+		http.Error(w, "Failed to check access to the post: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !canSee {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+
 	var newComment model.Comment
 	newComment.Content = r.FormValue("content")
 	newComment.PostID = intPostId
 	newComment.UserID = userID
 
+	// A reply names the comment it answers as parent_id, which has to be a comment of the same post
+	var parent model.Comment
+	if value := r.FormValue("parent_id"); value != "" {
+		parentID, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid parent_id", http.StatusBadRequest)
+			return
+		}
+		parent, err = h.commentRepo.GetCommentByID(parentID)
+		if err == sql.ErrNoRows || (err == nil && parent.PostID != intPostId) {
+			http.Error(w, "Parent comment not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Failed to get the parent comment: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if parent.Depth >= h.comments.MaxDepth {
+			http.Error(w, fmt.Sprintf("Replies can be nested at most %d deep", h.comments.MaxDepth), http.StatusBadRequest)
+			return
+		}
+		newComment.ParentID = parent.Id
+		newComment.Depth = parent.Depth + 1
+	}
+
 	_, _, err = r.FormFile("image")
 	if err != nil {
 		newComment.Image.String = ""
@@ -95,6 +136,21 @@ func (h *CommentHandler) CreateCommentHandler(w http.ResponseWriter, r *http.Req
 			return
 		}
 	}
+	// The author of the comment replied to hears about the reply, unless it's the post owner who got the notification above
+	if newComment.ParentID != 0 && parent.UserID != userID && parent.UserID != int(postOwnerId) {
+		message := username + " replied to your comment on: " + post.Title
+		err = h.notificationHandler.CreateReplyNotification(parent.UserID, userID, newComment.PostID, int(commentID), message)
+		if err != nil {
+			http.Error(w, "Failed to create notification: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	mentions, err := h.mentionHandler.MentionInComment(int(commentID), newComment.PostID, userID, newComment.Content)
+	if err != nil {
+		http.Error(w, "Failed to store the mentions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	user, err := h.userRepo.GetUserProfileByID(newComment.UserID)
 	if err != nil {
@@ -114,10 +170,12 @@ func (h *CommentHandler) CreateCommentHandler(w http.ResponseWriter, r *http.Req
 		Content:   newComment.Content,
 		Image:     commentImageUrl,
 		CreatedAt: time.Now(),
-		Likes:     0,
-		Dislikes:  0,
+		Reactions: map[string]int{},
 		Username:  username,
 		ImageURL:  user.AvatarURL, // Set the avatar URL here
+		Mentions:  mentionsOrEmpty(mentions),
+		ParentID:  newComment.ParentID,
+		Depth:     newComment.Depth,
 	}
 
 	// Successful response
@@ -138,31 +196,117 @@ func (h *CommentHandler) GetCommentsByPostID(w http.ResponseWriter, r *http.Requ
 		http.Error(w, "Error decoding id for comment request: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	comments, err := h.commentRepo.GetAllPostComments(intPostId)
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
-		http.Error(w, "Error retrieving comments: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "User not authenticated: "+err.Error(), http.StatusUnauthorized)
 		return
 	}
-	// get votes for each comment and append to CommentResponse
-	commentsWithVotes, err := h.VoteHandler.AppendVotesToCommentsResponse(comments)
+	// The comments are as private as the post
+	canSee, err := h.postRepo.CanUserSeePost(intPostId, userID)
 	if err != nil {
-		http.Error(w, "Error appending votes to comments: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Failed to check access to the post: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !canSee {
+		http.Error(w, "Post not found", http.StatusNotFound)
 		return
 	}
 
-	// we need user information per comment aswell - username, profile picture
-	for i, comment := range commentsWithVotes {
-		user, err := h.userRepo.GetUserProfileByID(comment.UserID)
+	// ?view=collapsed only returns the comments on the post, or the direct replies to ?parent=, with their reply counts.
+	// Expanded, the default, adds all replies below them in thread order.
+	params := r.URL.Query()
+	view := params.Get("view")
+	if view != "" && view != "expanded" && view != "collapsed" {
+		http.Error(w, "view must be expanded or collapsed", http.StatusBadRequest)
+		return
+	}
+	parentID := 0
+	if value := params.Get("parent"); value != "" {
+		parentID, err = strconv.Atoi(value)
 		if err != nil {
-			http.Error(w, "Error getting user profile: "+err.Error(), http.StatusInternalServerError)
+			http.Error(w, "Invalid parent", http.StatusBadRequest)
 			return
 		}
-		commentsWithVotes[i].Username = user.Username
-		commentsWithVotes[i].ImageURL = user.AvatarURL
+	}
+
+	comments, err := h.commentRepo.GetAllPostComments(intPostId)
+	if err != nil {
+		http.Error(w, "Error retrieving comments: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	thread, replyCounts, ok := commentThread(comments, parentID, view == "collapsed")
+	if !ok {
+		http.Error(w, "Parent comment not found", http.StatusNotFound)
+		return
+	}
+	// get reactions for each comment and append to CommentResponse
+	commentsWithReactions, err := h.reactionHandler.AppendReactionsToCommentsResponse(thread, userID)
+	if err != nil {
+		http.Error(w, "Error appending reactions to comments: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i, comment := range commentsWithReactions {
+		commentsWithReactions[i].ReplyCount = replyCounts[comment.Id]
+	}
+	if err := h.mentionHandler.AppendMentionsToCommentsResponse(commentsWithReactions); err != nil {
+		http.Error(w, "Error appending mentions to comments: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// we need user information per comment aswell - username, profile picture, loaded for all comments at once
+	userIDs := make([]int, len(commentsWithReactions))
+	for i, comment := range commentsWithReactions {
+		userIDs[i] = comment.UserID
+	}
+	users, err := h.userRepo.GetUserProfilesByIDs(userIDs)
+	if err != nil {
+		http.Error(w, "Error getting user profiles: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i, comment := range commentsWithReactions {
+		commentsWithReactions[i].Username = users[comment.UserID].Username
+		commentsWithReactions[i].ImageURL = users[comment.UserID].AvatarURL
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(commentsWithVotes)
+	json.NewEncoder(w).Encode(commentsWithReactions)
+}
+
+// commentThread orders the comments of a post as a thread: every comment is followed by its replies, oldest first.
+// It returns the replies below the parent comment, 0 for the whole post, and the number of direct replies per comment.
+// Collapsed it only returns the direct replies. Replies to deleted comments show up as comments on the post.
+// It returns false when the parent isn't a comment of the post.
+func commentThread(comments []model.Comment, parentID int, collapsed bool) ([]model.Comment, map[int]int, bool) {
+	exists := make(map[int]bool, len(comments))
+	for _, comment := range comments {
+		exists[comment.Id] = true
+	}
+	if parentID != 0 && !exists[parentID] {
+		return nil, nil, false
+	}
+
+	replies := make(map[int][]model.Comment)
+	replyCounts := make(map[int]int)
+	for _, comment := range comments {
+		parent := comment.ParentID
+		if !exists[parent] {
+			parent = 0
+		}
+		replies[parent] = append(replies[parent], comment)
+		replyCounts[parent]++
+	}
+
+	thread := []model.Comment{}
+	var addReplies func(id int)
+	addReplies = func(id int) {
+		for _, reply := range replies[id] {
+			thread = append(thread, reply)
+			if !collapsed {
+				addReplies(reply.Id)
+			}
+		}
+	}
+	addReplies(parentID)
+	return thread, replyCounts, true
 }
 
 func (h *CommentHandler) DeleteCommentHandler(w http.ResponseWriter, r *http.Request) {
@@ -179,14 +323,8 @@ func (h *CommentHandler) DeleteCommentHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	cookie, err := r.Cookie("session_token")
-	if err != nil {
-		http.Error(w, "Error authenticating user: "+err.Error(), http.StatusUnauthorized)
-		return
-	}
-
 	// Confirm user auth and get userid
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(cookie.Value)
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error confirming user authentication: "+err.Error(), http.StatusUnauthorized)
 		return
@@ -221,16 +359,24 @@ func (h *CommentHandler) EditCommentHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	cookie, err := r.Cookie("session_token")
+	// Confirm user auth and get userid
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
-		http.Error(w, "Error authenticating user: "+err.Error(), http.StatusUnauthorized)
+		http.Error(w, "Error confirming user authentication: "+err.Error(), http.StatusUnauthorized)
 		return
 	}
 
-	// Confirm user auth and get userid
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(cookie.Value)
-	if err != nil {
-		http.Error(w, "Error confirming user authentication: "+err.Error(), http.StatusUnauthorized)
+	// Only the author can edit a comment, within COMMENT_EDIT_WINDOW after writing it
+	comment, err := h.commentRepo.GetCommentByID(intcommentID)
+	if err == sql.ErrNoRows || (err == nil && comment.UserID != userID) {
+		http.Error(w, "No comment found with the specified id that belongs to the user", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get the comment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if time.Since(comment.CreatedAt) > h.comments.EditWindow {
+		http.Error(w, "Comments can only be edited for "+h.comments.EditWindow.String()+" after writing them", http.StatusForbidden)
 		return
 	}
 
@@ -242,12 +388,16 @@ func (h *CommentHandler) EditCommentHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Update the comment in the database
+	// Update the comment in the database, the previous version becomes a revision
 	err = h.commentRepo.UpdateComment(intcommentID, userID, commentData)
 	if err != nil {
 		http.Error(w, "Failed to update the comment: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if _, err := h.mentionHandler.MentionInComment(intcommentID, comment.PostID, userID, commentData.Content); err != nil {
+		http.Error(w, "Failed to store the mentions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	// Successful response
 	response := map[string]string{
@@ -256,3 +406,45 @@ func (h *CommentHandler) EditCommentHandler(w http.ResponseWriter, r *http.Reque
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// GetCommentRevisionsHandler returns the previous versions of an edited comment, the newest first,
+// to everyone who can see the comment.
+func (h *CommentHandler) GetCommentRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	commentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+		return
+	}
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		http.Error(w, "User not authenticated: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	comment, err := h.commentRepo.GetCommentByID(commentID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get the comment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// The revisions are as private as the post
+	canSee, err := h.postRepo.CanUserSeePost(comment.PostID, userID)
+	if err != nil {
+		http.Error(w, "Failed to check access to the post: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !canSee {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+
+	revisions, err := h.commentRepo.GetCommentRevisions(commentID)
+	if err != nil {
+		http.Error(w, "Error retrieving comment revisions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
@@ -1,9 +1,9 @@
 package handler
 
 import (
+	"backend/pkg/auth"
 	"backend/pkg/model"
 	"backend/pkg/repository"
-	"backend/util"
 	"encoding/json"
 	"net/http"
 	"strconv"
@@ -45,7 +45,7 @@ func (h *EventHandler) CreateEventHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 	// check if event with title already exists IN FRONTEND
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error confirming authentication: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -107,7 +107,7 @@ func (h *EventHandler) EditEventHandler(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Failed to get group: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error confirming authentication: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -126,7 +126,7 @@ func (h *EventHandler) EditEventHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *EventHandler) DeleteEventHandler(w http.ResponseWriter, r *http.Request) {
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error confirming authentication: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -191,7 +191,7 @@ func (h *EventHandler) AddOrUpdateAttendanceHandler(w http.ResponseWriter, r *ht
 	eventID, _ := strconv.Atoi(vars["eventId"])
 	statusInt, _ := strconv.Atoi(vars["status"])
 
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error confirming authentication: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -221,7 +221,7 @@ func (h *EventHandler) GetAttendanceByEventIDHandler(w http.ResponseWriter, r *h
 		return
 	}
 
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error confirming authentication: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -250,7 +250,7 @@ func (h *EventHandler) GetAttendanceByEventIDHandler(w http.ResponseWriter, r *h
 }
 
 func (h *EventHandler) GetAllUserEvents(w http.ResponseWriter, r *http.Request) {
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error confirming authentication: "+err.Error(), http.StatusInternalServerError)
 		return
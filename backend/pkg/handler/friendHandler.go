@@ -1,8 +1,8 @@
 package handler
 
 import (
+	"backend/pkg/auth"
 	"backend/pkg/repository"
-	"backend/util"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -31,7 +31,7 @@ func NewFriendHandler(friendRepository *repository.FriendsRepository, sessionRep
 // It requires the user to be authenticated and returns the friend requests in JSON format.
 // If there is an error retrieving the friend requests, it returns an HTTP 500 Internal Server Error.
 func (h *FriendHandler) GetFriendRequestsHandler(w http.ResponseWriter, r *http.Request) {
-	userID, err := h.sessionRepository.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "User not authenticated", http.StatusUnauthorized)
 		return
@@ -51,8 +51,7 @@ func (h *FriendHandler) GetFriendRequestsHandler(w http.ResponseWriter, r *http.
 // If a friend request is already pending or the users are already friends or one user has blocked the other, it returns an error.
 // It returns http.StatusCreated if the friend request is sent successfully.
 func (h *FriendHandler) SendFriendRequestHandler(w http.ResponseWriter, r *http.Request) {
-	sessionToken := util.GetSessionToken(r)
-	userID, err := h.sessionRepository.GetUserIDFromSessionToken(sessionToken)
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "User not authenticated", http.StatusUnauthorized)
 		return
@@ -119,8 +118,7 @@ func (h *FriendHandler) SendFriendRequestHandler(w http.ResponseWriter, r *http.
 // If successful, it updates the friend status to "accepted" and returns a 200 OK response.
 // If any error occurs, it returns an appropriate HTTP error response.
 func (h *FriendHandler) AcceptFriendRequestHandler(w http.ResponseWriter, r *http.Request) {
-	sessionToken := util.GetSessionToken(r)
-	userID, err := h.sessionRepository.GetUserIDFromSessionToken(sessionToken)
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "User not authenticated", http.StatusUnauthorized)
 		return
@@ -165,8 +163,7 @@ func (h *FriendHandler) AcceptFriendRequestHandler(w http.ResponseWriter, r *htt
 // If successful, it updates the friend status to "declined" and returns a 200 OK response.
 // If there is an error, it returns an appropriate HTTP error response.
 func (h *FriendHandler) DeclineFriendRequestHandler(w http.ResponseWriter, r *http.Request) {
-	sessionToken := util.GetSessionToken(r)
-	userID, err := h.sessionRepository.GetUserIDFromSessionToken(sessionToken)
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "User not authenticated", http.StatusUnauthorized)
 		return
@@ -213,8 +210,7 @@ func (h *FriendHandler) GetFriendsHandler(w http.ResponseWriter, r *http.Request
 
 	userID, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		sessionToken := util.GetSessionToken(r)
-		userID, err = h.sessionRepository.GetUserIDFromSessionToken(sessionToken)
+		userID, err = auth.UserIDFromRequest(r)
 		if err != nil {
 			http.Error(w, "User not authenticated", http.StatusUnauthorized)
 			return
@@ -235,7 +231,7 @@ func (h *FriendHandler) GetFriendsHandler(w http.ResponseWriter, r *http.Request
 }
 
 func (h *FriendHandler) CheckFriendStatusHandler(w http.ResponseWriter, r *http.Request) {
-	userID, err := h.sessionRepository.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "User not authenticated", http.StatusUnauthorized)
 		return
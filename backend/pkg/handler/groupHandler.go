@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"backend/pkg/auth"
 	"backend/pkg/model"
 	"backend/pkg/repository"
 	"backend/util"
@@ -32,7 +33,7 @@ func (h *GroupHandler) GetAllGroupsHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	// should add bool field to return data if user is group member
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error confirming authentication: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -65,7 +66,7 @@ func (h *GroupHandler) CreateGroupHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	// TODO: check if group with title already exists IN FRONTEND
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error confirming authentication: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -94,7 +95,7 @@ func (h *GroupHandler) CreateGroupHandler(w http.ResponseWriter, r *http.Request
 
 func (h *GroupHandler) GetGroupByIDHandler(w http.ResponseWriter, r *http.Request) {
 	// logic for getting a group by ID
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error confirming authentication: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -160,7 +161,7 @@ func (h *GroupHandler) EditGroupHandler(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Failed to get group: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error confirming authentication: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -184,7 +185,7 @@ func (h *GroupHandler) EditGroupHandler(w http.ResponseWriter, r *http.Request)
 // If any errors occur during the process, appropriate HTTP error responses are returned.
 // implement logging of the deletion or add bool field "deleted"
 func (h *GroupHandler) DeleteGroupHandler(w http.ResponseWriter, r *http.Request) {
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error confirming authentication: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -1,9 +1,9 @@
 package handler
 
 import (
+	"backend/pkg/auth"
 	"backend/pkg/model"
 	"backend/pkg/repository"
-	"backend/util"
 	"encoding/json"
 	"net/http"
 	"strconv"
@@ -51,7 +51,7 @@ func (h *GroupMemberHandler) RemoveMemberHandler(w http.ResponseWriter, r *http.
 		http.Error(w, "Failed to convert userid string to int: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	requestingUserId, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	requestingUserId, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Failed to get user id from session token: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -88,7 +88,7 @@ func (h *GroupMemberHandler) RequestGroupMembershipHandler(w http.ResponseWriter
 		return
 	}
 
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Failed to get user id from session token: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -117,7 +117,7 @@ func (h *GroupMemberHandler) RequestGroupMembershipHandler(w http.ResponseWriter
 
 // logic for setting the player as group member
 func (h *GroupMemberHandler) ApproveGroupMembershipHandler(w http.ResponseWriter, r *http.Request) {
-	adminID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	adminID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Failed to get user id from session token: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -156,7 +156,7 @@ func (h *GroupMemberHandler) ApproveGroupMembershipHandler(w http.ResponseWriter
 
 // Allows user to decline membership.
 func (h *GroupMemberHandler) DeclineGroupMembershipHandler(w http.ResponseWriter, r *http.Request) {
-	adminID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	adminID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Failed to get user id from session token: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -195,7 +195,7 @@ func (h *GroupMemberHandler) InviteGroupMemberHandler(w http.ResponseWriter, r *
 	// Retrieve the user ID from URL
 	userID, _ := strconv.Atoi(vars["userId"])
 
-	inviteUserID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	inviteUserID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Failed to get user id from session token: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -230,7 +230,7 @@ func (h *GroupMemberHandler) AcceptGroupInvitationHandler(w http.ResponseWriter,
 	vars := mux.Vars(r)
 	groupID, _ := strconv.Atoi(vars["groupId"])
 
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Failed to get user id from session token: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -279,7 +279,7 @@ func (h *GroupMemberHandler) DeclineGroupInvitationHandler(w http.ResponseWriter
 	vars := mux.Vars(r)
 	groupID, _ := strconv.Atoi(vars["groupId"])
 
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Failed to get user id from session token: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -306,7 +306,7 @@ func (h *GroupMemberHandler) DeclineGroupInvitationHandler(w http.ResponseWriter
 // GetGroupInvitationByIDHandler gets an invitation by ID for the user.
 func (h *GroupMemberHandler) GetGroupInvitationByIDHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract the user ID from the cookie.
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error extracting user ID from session token: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -332,7 +332,7 @@ func (h *GroupMemberHandler) GetGroupInvitationByIDHandler(w http.ResponseWriter
 // GetAllGroupInvitationsHandler gets all pending invitations for the user.
 func (h *GroupMemberHandler) GetAllGroupInvitationsHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract the user ID from the cookie.
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error extracting user ID from session token: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -359,7 +359,7 @@ func (h *GroupMemberHandler) GetAllGroupRequestsHandler(w http.ResponseWriter, r
 		return
 	}
 	// Extract the user ID from the cookie.
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error extracting user ID from session token: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -399,7 +399,7 @@ func (h *GroupMemberHandler) GetAllNonMembersHandler(w http.ResponseWriter, r *h
 		return
 	}
 	// Extract the user ID from the cookie.
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error extracting user ID from session token: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -0,0 +1,295 @@
+package handler
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/config"
+	"backend/pkg/model"
+	"backend/pkg/repository"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Reasons a signup is refused
+var (
+	errRegistrationClosed = errors.New("registration is closed")
+	errInviteRequired     = errors.New("an invitation code is required")
+	errInviteInvalid      = errors.New("invitation code is invalid, used up or expired")
+)
+
+// InviteHandler manages invitation codes and decides who can sign up, depending on the registration mode.
+// Users and admins create codes, users within their quota. Every signup with a code records the inviter.
+type InviteHandler struct {
+	inviteRepo *repository.InviteRepository
+	config     config.Registration
+}
+
+func NewInviteHandler(iRepo *repository.InviteRepository, config config.Registration) *InviteHandler {
+	return &InviteHandler{inviteRepo: iRepo, config: config}
+}
+
+// RedeemInvite checks whether someone can sign up with the invitation code and counts the use of the code.
+// In open mode the code is optional, the returned invite is nil without one. Call CompleteSignup once the
+// account exists, or ReleaseInvite if creating it failed.
+func (h *InviteHandler) RedeemInvite(code string) (*model.InviteCode, error) {
+	code = strings.TrimSpace(code)
+	switch {
+	case h.config.Mode == config.RegistrationClosed:
+		return nil, errRegistrationClosed
+	case code == "" && h.config.Mode == config.RegistrationInvite:
+		return nil, errInviteRequired
+	case code == "":
+		return nil, nil
+	}
+
+	invite, err := h.inviteRepo.UseInvite(normalizeInviteCode(code))
+	if err == sql.ErrNoRows {
+		return nil, errInviteInvalid
+	} else if err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// CompleteSignup records who invited the new user.
+func (h *InviteHandler) CompleteSignup(userID int, invite *model.InviteCode) {
+	if invite == nil {
+		return
+	}
+	if err := h.inviteRepo.SetInviter(userID, *invite); err != nil {
+		log.Println("Error saving inviter: ", err)
+	}
+}
+
+// ReleaseInvite gives the use of the code back after a failed signup.
+func (h *InviteHandler) ReleaseInvite(invite *model.InviteCode) {
+	if invite == nil {
+		return
+	}
+	if err := h.inviteRepo.ReleaseInvite(invite.Id); err != nil {
+		log.Println("Error releasing invitation code: ", err)
+	}
+}
+
+// writeSignupError answers a signup refused by RedeemInvite.
+func writeSignupError(w http.ResponseWriter, err error) {
+	switch err {
+	case errRegistrationClosed, errInviteRequired:
+		auth.WriteJSONError(w, http.StatusForbidden, "Registration failed: "+err.Error())
+	case errInviteInvalid:
+		auth.WriteJSONError(w, http.StatusBadRequest, "Registration failed: "+err.Error())
+	default:
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error checking invitation code: "+err.Error())
+	}
+}
+
+// GetRegistrationHandler tells the signup page the registration mode. With ?invite_code= it also
+// checks the code, without using it.
+func (h *InviteHandler) GetRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"mode": h.config.Mode,
+	}
+	if code := strings.TrimSpace(r.URL.Query().Get("invite_code")); code != "" {
+		usable, err := h.inviteRepo.IsInviteUsable(normalizeInviteCode(code))
+		if err != nil {
+			auth.WriteJSONError(w, http.StatusInternalServerError, "Error checking invitation code: "+err.Error())
+			return
+		}
+		response["invite_code_valid"] = usable && h.config.Mode != config.RegistrationClosed
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetInvitesHandler lists the invitation codes of the authenticated user.
+func (h *InviteHandler) GetInvitesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	invites, err := h.inviteRepo.GetInvitesByCreatorID(userID)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting invitation codes: "+err.Error())
+		return
+	}
+	if invites == nil {
+		invites = []model.InviteCode{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invites)
+}
+
+// GetAllInvitesHandler lists the invitation codes of all users for admins.
+func (h *InviteHandler) GetAllInvitesHandler(w http.ResponseWriter, r *http.Request) {
+	invites, err := h.inviteRepo.GetAllInvites()
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting invitation codes: "+err.Error())
+		return
+	}
+	if invites == nil {
+		invites = []model.InviteCode{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invites)
+}
+
+// CreateInviteHandler creates an invitation code. Users can have INVITE_QUOTA usable codes at a time,
+// each for up to INVITE_MAX_USES signups and valid for up to INVITE_CODE_TTL. Admins have no limits,
+// max_uses 0 gives them a code without usage limit.
+func (h *InviteHandler) CreateInviteHandler(w http.ResponseWriter, r *http.Request) {
+	identity, ok := auth.IdentityFromContext(r.Context())
+	if !ok {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	if h.config.Mode == config.RegistrationClosed {
+		auth.WriteJSONError(w, http.StatusForbidden, "Registration is closed")
+		return
+	}
+	var data model.InviteCodeData
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			auth.WriteJSONError(w, http.StatusBadRequest, "Error parsing JSON data: "+err.Error())
+			return
+		}
+	}
+
+	isAdmin := identity.Role == auth.RoleAdmin
+	maxExpiry := time.Now().Add(h.config.InviteCodeTTL)
+	maxUses := 1
+	if data.MaxUses != nil {
+		maxUses = *data.MaxUses
+	}
+	if maxUses < 0 || (!isAdmin && (maxUses == 0 || maxUses > h.config.InviteMaxUses)) {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Maximum uses must be between 1 and "+strconv.Itoa(h.config.InviteMaxUses))
+		return
+	}
+	if data.ExpiresAt == nil {
+		data.ExpiresAt = &maxExpiry
+	} else if !data.ExpiresAt.After(time.Now()) {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Expiry time must be in the future")
+		return
+	} else if !isAdmin && data.ExpiresAt.After(maxExpiry) {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Invitation codes can be valid for at most "+h.config.InviteCodeTTL.String())
+		return
+	}
+
+	if !isAdmin {
+		if h.config.InviteQuota <= 0 {
+			auth.WriteJSONError(w, http.StatusForbidden, "Only admins can create invitation codes")
+			return
+		}
+		count, err := h.inviteRepo.CountActiveInvitesByCreatorID(identity.UserID)
+		if err != nil {
+			auth.WriteJSONError(w, http.StatusInternalServerError, "Error counting invitation codes: "+err.Error())
+			return
+		}
+		if count >= h.config.InviteQuota {
+			auth.WriteJSONError(w, http.StatusConflict, "You can have "+strconv.Itoa(h.config.InviteQuota)+" unused invitation codes, delete one first")
+			return
+		}
+	}
+
+	code, err := generateInviteCode()
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error creating invitation code")
+		return
+	}
+	invite := model.InviteCode{
+		Code:      code,
+		CreatorID: identity.UserID,
+		MaxUses:   maxUses,
+		ExpiresAt: data.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+	id, err := h.inviteRepo.CreateInvite(invite)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error saving invitation code: "+err.Error())
+		return
+	}
+	invite.Id = int(id)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invite)
+}
+
+// DeleteInviteHandler deletes an invitation code of the authenticated user, admins can delete every code.
+func (h *InviteHandler) DeleteInviteHandler(w http.ResponseWriter, r *http.Request) {
+	identity, ok := auth.IdentityFromContext(r.Context())
+	if !ok {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Invalid invitation code ID")
+		return
+	}
+
+	invite, err := h.inviteRepo.GetInviteByID(id)
+	if err == sql.ErrNoRows || (err == nil && invite.CreatorID != identity.UserID && identity.Role != auth.RoleAdmin) {
+		auth.WriteJSONError(w, http.StatusNotFound, "Invitation code not found")
+		return
+	} else if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting invitation code: "+err.Error())
+		return
+	}
+	if err := h.inviteRepo.DeleteInvite(id); err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error deleting invitation code: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Invitation code deleted",
+	})
+}
+
+// GetInviteesHandler lists the users who signed up with the codes of the authenticated user.
+func (h *InviteHandler) GetInviteesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	invitees, err := h.inviteRepo.GetInviteesByInviterID(userID)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting invited users: "+err.Error())
+		return
+	}
+	if invitees == nil {
+		invitees = []model.Invitee{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invitees)
+}
+
+// generateInviteCode returns a random code that is easy to type, like 7KQ2-MXD3-4TBN-W3PA.
+func generateInviteCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := base32.StdEncoding.EncodeToString(b)
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16], nil
+}
+
+// normalizeInviteCode accepts codes typed in lowercase.
+func normalizeInviteCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/model"
+	"backend/pkg/repository"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Limits of the mention autocomplete
+const (
+	defaultMentionSuggestions = 8
+	maxMentionSuggestions     = 20
+)
+
+// MentionHandler stores the @username mentions of posts and comments, notifies the mentioned users
+// and suggests users to mention. Chat messages get their mentions in ws.ChatHandler.SendMessage.
+type MentionHandler struct {
+	mentionRepo         *repository.MentionRepository
+	postRepo            *repository.PostRepository
+	userRepo            *repository.UserRepository
+	notificationHandler *NotificationHandler
+}
+
+func NewMentionHandler(mentionRepo *repository.MentionRepository, postRepo *repository.PostRepository, userRepo *repository.UserRepository, notificationHandler *NotificationHandler) *MentionHandler {
+	return &MentionHandler{mentionRepo: mentionRepo, postRepo: postRepo, userRepo: userRepo, notificationHandler: notificationHandler}
+}
+
+// MentionInPost stores the mentions of a created or edited post and notifies the users mentioned for the first time.
+func (h *MentionHandler) MentionInPost(postID, authorID int, texts ...string) ([]model.Mention, error) {
+	return h.mention(model.MentionItemPost, postID, postID, authorID, texts...)
+}
+
+// MentionInComment stores the mentions of a created or edited comment and notifies the users mentioned for the first time.
+func (h *MentionHandler) MentionInComment(commentID, postID, authorID int, text string) ([]model.Mention, error) {
+	return h.mention(model.MentionItemComment, commentID, postID, authorID, text)
+}
+
+// mention stores the mentions of a post or comment and returns the users mentioned for the first time.
+// Only the ones who can see the post get a notification, the author never.
+func (h *MentionHandler) mention(itemType string, itemID, postID, authorID int, texts ...string) ([]model.Mention, error) {
+	added, err := h.mentionRepo.SetMentions(itemType, itemID, texts...)
+	if err != nil || len(added) == 0 {
+		return added, err
+	}
+	post, err := h.postRepo.GetPostByID(postID)
+	if err != nil {
+		return added, err
+	}
+	username, err := h.userRepo.GetUsernameByID(authorID)
+	if err != nil {
+		return added, err
+	}
+
+	notification := model.Notification{SenderId: authorID, Type: "mention", PostId: postID,
+		Message: username + " mentioned you in a post: " + post.Title}
+	if itemType == model.MentionItemComment {
+		notification.CommentId = itemID
+		notification.Message = username + " mentioned you in a comment on: " + post.Title
+	}
+	for _, mention := range added {
+		if mention.UserID == authorID {
+			continue
+		}
+		canSee, err := h.postRepo.CanUserSeePost(postID, mention.UserID)
+		if err != nil {
+			return added, err
+		}
+		if !canSee {
+			continue
+		}
+		notification.UserId = mention.UserID
+		if err := h.notificationHandler.CreateMentionNotification(notification); err != nil {
+			return added, err
+		}
+	}
+	return added, nil
+}
+
+// AppendMentionsToPostsResponse adds the mentions of the posts, loaded for all posts with one query.
+func (h *MentionHandler) AppendMentionsToPostsResponse(posts []model.PostsResponse) error {
+	postIDs := make([]int, len(posts))
+	for i, post := range posts {
+		postIDs[i] = post.Id
+	}
+	mentions, err := h.mentionRepo.GetMentions(model.MentionItemPost, postIDs)
+	if err != nil {
+		return err
+	}
+	for i, post := range posts {
+		posts[i].Mentions = mentionsOrEmpty(mentions[post.Id])
+	}
+	return nil
+}
+
+// AppendMentionsToCommentsResponse adds the mentions of the comments, loaded for all comments with one query.
+func (h *MentionHandler) AppendMentionsToCommentsResponse(comments []model.CommentsResponse) error {
+	commentIDs := make([]int, len(comments))
+	for i, comment := range comments {
+		commentIDs[i] = comment.Id
+	}
+	mentions, err := h.mentionRepo.GetMentions(model.MentionItemComment, commentIDs)
+	if err != nil {
+		return err
+	}
+	for i, comment := range comments {
+		comments[i].Mentions = mentionsOrEmpty(mentions[comment.Id])
+	}
+	return nil
+}
+
+// mentionsOrEmpty keeps items without mentions from encoding their mentions as null.
+func mentionsOrEmpty(mentions []model.Mention) []model.Mention {
+	if mentions == nil {
+		return []model.Mention{}
+	}
+	return mentions
+}
+
+// GetMentionSuggestionsHandler answers GET /api/users/mentions?q= with the users to suggest after typing @ and q,
+// friends first, then members of the groups of the user. ?limit= asks for more or fewer users.
+func (h *MentionHandler) GetMentionSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	params := r.URL.Query()
+	prefix := strings.TrimPrefix(strings.TrimSpace(params.Get("q")), "@")
+	if prefix == "" {
+		auth.WriteJSONError(w, http.StatusBadRequest, "q must contain the start of a username or name")
+		return
+	}
+	limit := defaultMentionSuggestions
+	if value := params.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxMentionSuggestions {
+			auth.WriteJSONError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxMentionSuggestions))
+			return
+		}
+	}
+
+	users, err := h.mentionRepo.GetMentionSuggestions(userID, prefix, limit)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Failed to retrieve users: "+err.Error())
+		return
+	}
+	if users == nil {
+		users = []model.UserList{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
@@ -1,9 +1,9 @@
 package handler
 
 import (
+	"backend/pkg/auth"
 	"backend/pkg/model"
 	"backend/pkg/repository"
-	"backend/util"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -60,6 +60,39 @@ func (h *NotificationHandler) CreateGroupNotification(userID, groupID int, messa
 	return err
 }
 
+// CreateSecurityNotification informs the user about something that happened to the account, like a lockout.
+func (h *NotificationHandler) CreateSecurityNotification(userID int, message string) error {
+	notification := model.Notification{
+		UserId:  userID,
+		Type:    "security",
+		Message: message,
+	}
+
+	_, err := h.notificationRepo.CreateNotification(notification)
+	return err
+}
+
+// CreateReplyNotification tells the author of a comment about a reply, the notification links to the post and the reply.
+func (h *NotificationHandler) CreateReplyNotification(userID, senderID, postID, commentID int, message string) error {
+	notification := model.Notification{
+		UserId:    userID,
+		SenderId:  senderID,
+		Type:      "post",
+		Message:   message,
+		PostId:    postID,
+		CommentId: commentID,
+	}
+	_, err := h.notificationRepo.CreateNotification(notification)
+	return err
+}
+
+// CreateMentionNotification tells a user about a mention, the notification links to the post and comment.
+func (h *NotificationHandler) CreateMentionNotification(notification model.Notification) error {
+	notification.Type = "mention"
+	_, err := h.notificationRepo.CreateNotification(notification)
+	return err
+}
+
 func (h *NotificationHandler) CreateGroupAdminNotification(userID, groupID, senderID int, message string) error {
 	fmt.Println("CreateGroupAdminNotification", userID, groupID, senderID, message)
 	notification := model.Notification{
@@ -81,7 +114,7 @@ func (h *NotificationHandler) DeleteNotificationHandler(w http.ResponseWriter, r
 		http.Error(w, "Invalid notification ID", http.StatusBadRequest)
 	}
 
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "User not authenticated: "+err.Error(), http.StatusUnauthorized)
 	}
@@ -103,7 +136,7 @@ func (h *NotificationHandler) DeleteNotificationHandler(w http.ResponseWriter, r
 }
 
 func (h *NotificationHandler) GetAllNotificationsForUserHandler(w http.ResponseWriter, r *http.Request) {
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "User not authenticated: "+err.Error(), http.StatusUnauthorized)
 	}
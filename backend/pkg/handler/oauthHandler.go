@@ -0,0 +1,600 @@
+package handler
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/config"
+	"backend/pkg/model"
+	"backend/pkg/repository"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// oauthClientSecretPrefix marks client secrets, so leaked secrets are easy to recognize
+const oauthClientSecretPrefix = "isc_"
+
+// maxOAuthClients is the number of apps a user can register, maxRedirectURIs the number of redirect URIs per app
+const (
+	maxOAuthClients = 20
+	maxRedirectURIs = 10
+)
+
+// Errors of the authorization and token endpoints, as defined by RFC 6749
+const (
+	oauthErrorInvalidRequest       = "invalid_request"
+	oauthErrorInvalidClient        = "invalid_client"
+	oauthErrorInvalidGrant         = "invalid_grant"
+	oauthErrorInvalidScope         = "invalid_scope"
+	oauthErrorUnsupportedGrantType = "unsupported_grant_type"
+	oauthErrorUnsupportedResponse  = "unsupported_response_type"
+	oauthErrorAccessDenied         = "access_denied"
+	oauthErrorServerError          = "server_error"
+)
+
+// OAuthHandler is an OAuth 2.0 authorization server, third-party apps use it to act on behalf of users without
+// handling their passwords. Apps send the user to the frontend with an authorization request, the frontend shows
+// the consent screen from GetAuthorizeHandler and sends the decision to AuthorizeHandler, which redirects back
+// to the app with a code. The app exchanges the code for an access token and a refresh token at TokenHandler.
+// Only the authorization code grant with PKCE (S256) is supported.
+type OAuthHandler struct {
+	oauthRepo     *repository.OAuthRepository
+	notifications *NotificationHandler
+	config        config.OAuth
+}
+
+func NewOAuthHandler(oRepo *repository.OAuthRepository, notifications *NotificationHandler, config config.OAuth) *OAuthHandler {
+	return &OAuthHandler{oauthRepo: oRepo, notifications: notifications, config: config}
+}
+
+// GetClientsHandler lists the apps registered by the authenticated user.
+func (h *OAuthHandler) GetClientsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	clients, err := h.oauthRepo.GetClientsByOwnerID(userID)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting apps: "+err.Error())
+		return
+	}
+	if clients == nil {
+		clients = []model.OAuthClient{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(clients)
+}
+
+// CreateClientHandler registers an app. Confidential apps get a client secret, which is only part of this response.
+func (h *OAuthHandler) CreateClientHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	var data model.OAuthClientData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Error parsing JSON data: "+err.Error())
+		return
+	}
+
+	data.Name = strings.TrimSpace(data.Name)
+	if data.Name == "" || len(data.Name) > 100 {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Name is required and can have at most 100 characters")
+		return
+	}
+	if len(data.RedirectURIs) == 0 || len(data.RedirectURIs) > maxRedirectURIs {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Between 1 and "+strconv.Itoa(maxRedirectURIs)+" redirect URIs are required")
+		return
+	}
+	var redirectURIs []string
+	for _, redirectURI := range data.RedirectURIs {
+		if !validRedirectURI(redirectURI) {
+			auth.WriteJSONError(w, http.StatusBadRequest, "Invalid redirect URI "+redirectURI+", it must be an absolute https URL without fragment (http is only allowed for localhost)")
+			return
+		}
+		if !containsScope(redirectURIs, redirectURI) {
+			redirectURIs = append(redirectURIs, redirectURI)
+		}
+	}
+
+	clients, err := h.oauthRepo.GetClientsByOwnerID(userID)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error counting apps: "+err.Error())
+		return
+	}
+	if len(clients) >= maxOAuthClients {
+		auth.WriteJSONError(w, http.StatusConflict, "Too many apps, delete one first")
+		return
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error creating app")
+		return
+	}
+	client := model.OAuthClient{
+		ClientID:     hex.EncodeToString(b),
+		Confidential: data.Confidential,
+		Name:         data.Name,
+		RedirectURIs: redirectURIs,
+		OwnerID:      userID,
+	}
+	var clientSecret string
+	if data.Confidential {
+		secret, _, err := auth.GenerateToken()
+		if err != nil {
+			auth.WriteJSONError(w, http.StatusInternalServerError, "Error creating app")
+			return
+		}
+		clientSecret = oauthClientSecretPrefix + secret
+		client.ClientSecretHash = auth.HashToken(clientSecret)
+	}
+	id, err := h.oauthRepo.CreateClient(client)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error saving app: "+err.Error())
+		return
+	}
+
+	response := map[string]interface{}{
+		"id":            id,
+		"client_id":     client.ClientID,
+		"name":          client.Name,
+		"redirect_uris": client.RedirectURIs,
+		"confidential":  client.Confidential,
+	}
+	if clientSecret != "" {
+		response["client_secret"] = clientSecret
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteClientHandler deletes one of the authenticated user's apps, which revokes all tokens issued to it.
+func (h *OAuthHandler) DeleteClientHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Invalid app ID")
+		return
+	}
+
+	err = h.oauthRepo.DeleteClient(id, userID)
+	if err == sql.ErrNoRows {
+		auth.WriteJSONError(w, http.StatusNotFound, "App not found")
+		return
+	} else if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error deleting app: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "App deleted",
+	})
+}
+
+// GetAuthorizeHandler validates the authorization request in the query string and returns what the consent
+// screen shows: the app and the scopes it asks for.
+func (h *OAuthHandler) GetAuthorizeHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	data := model.OAuthAuthorizeData{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+	client, scopes, ok := h.validateAuthorizeRequest(w, data)
+	if !ok {
+		return
+	}
+
+	scopeList := make([]map[string]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scopeList = append(scopeList, map[string]string{
+			"scope":       scope,
+			"description": model.ScopeDescriptions[scope],
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"client": map[string]string{
+			"client_id": client.ClientID,
+			"name":      client.Name,
+		},
+		"scopes":       scopeList,
+		"redirect_uri": data.RedirectURI,
+		"state":        data.State,
+	})
+}
+
+// AuthorizeHandler takes the decision of the user on the consent screen. It returns redirect_to, the address
+// of the app the browser has to go to, with an authorization code or with error=access_denied.
+func (h *OAuthHandler) AuthorizeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	var data model.OAuthAuthorizeData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Error parsing JSON data: "+err.Error())
+		return
+	}
+	client, scopes, ok := h.validateAuthorizeRequest(w, data)
+	if !ok {
+		return
+	}
+
+	if !data.Approve {
+		writeRedirectTo(w, data.RedirectURI, url.Values{"error": {oauthErrorAccessDenied}}, data.State)
+		return
+	}
+
+	code, codeHash, err := auth.GenerateToken()
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error creating authorization code")
+		return
+	}
+	err = h.oauthRepo.CreateCode(codeHash, model.OAuthCode{
+		ClientID:      client.Id,
+		UserID:        userID,
+		RedirectURI:   data.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: data.CodeChallenge,
+		ExpiresAt:     time.Now().Add(h.config.CodeTTL),
+	})
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error saving authorization code: "+err.Error())
+		return
+	}
+	if err := h.notifications.CreateSecurityNotification(userID, "The app "+client.Name+" was given access to your account"); err != nil {
+		log.Println("Error creating security notification: ", err)
+	}
+
+	writeRedirectTo(w, data.RedirectURI, url.Values{"code": {code}}, data.State)
+}
+
+// validateAuthorizeRequest checks an authorization request and writes the error response if it is invalid.
+// As long as the client and the redirect URI aren't known to be valid, errors must not be sent to the redirect URI.
+// The other errors are returned with redirect_to, the address the frontend sends the browser back to the app with.
+func (h *OAuthHandler) validateAuthorizeRequest(w http.ResponseWriter, data model.OAuthAuthorizeData) (model.OAuthClient, []string, bool) {
+	client, err := h.oauthRepo.GetClientByClientID(data.ClientID)
+	if err == sql.ErrNoRows {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Unknown app")
+		return model.OAuthClient{}, nil, false
+	} else if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting app: "+err.Error())
+		return model.OAuthClient{}, nil, false
+	}
+	if !containsScope(client.RedirectURIs, data.RedirectURI) {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Redirect URI is not registered for the app")
+		return model.OAuthClient{}, nil, false
+	}
+
+	fail := func(errorCode, description string) (model.OAuthClient, []string, bool) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":             errorCode,
+			"error_description": description,
+			"redirect_to":       redirectURL(data.RedirectURI, url.Values{"error": {errorCode}, "error_description": {description}}, data.State),
+		})
+		return model.OAuthClient{}, nil, false
+	}
+	if data.ResponseType != "code" {
+		return fail(oauthErrorUnsupportedResponse, "Only response_type=code is supported")
+	}
+	if data.CodeChallenge == "" || data.CodeChallengeMethod != "S256" {
+		return fail(oauthErrorInvalidRequest, "PKCE with code_challenge_method=S256 is required")
+	}
+	scopes, ok := parseScopes(data.Scope, model.TokenScopes)
+	if !ok {
+		return fail(oauthErrorInvalidScope, "Valid scopes are "+strings.Join(model.TokenScopes, " "))
+	}
+	return client, scopes, true
+}
+
+// TokenHandler is the token endpoint apps call directly, with form-encoded parameters as defined by RFC 6749.
+// It exchanges an authorization code, or a refresh token, for a new access token and refresh token.
+// Confidential apps authenticate with HTTP Basic or client_secret in the form, public apps send client_id.
+func (h *OAuthHandler) TokenHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauthErrorInvalidRequest, "Error parsing form data")
+		return
+	}
+	client, ok := h.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		code, err := h.oauthRepo.ConsumeCode(auth.HashToken(r.PostForm.Get("code")))
+		if err == sql.ErrNoRows {
+			writeOAuthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, "Authorization code is invalid or expired")
+			return
+		} else if err != nil {
+			writeOAuthError(w, http.StatusInternalServerError, oauthErrorServerError, "Error getting authorization code")
+			return
+		}
+		if code.ClientID != client.Id || code.RedirectURI != r.PostForm.Get("redirect_uri") {
+			writeOAuthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, "Authorization code was issued to another app or redirect URI")
+			return
+		}
+		challenge := auth.CodeChallenge(r.PostForm.Get("code_verifier"))
+		if subtle.ConstantTimeCompare([]byte(challenge), []byte(code.CodeChallenge)) != 1 {
+			writeOAuthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, "Code verifier doesn't match the code challenge")
+			return
+		}
+		h.issueTokens(w, client, code.UserID, code.Scopes, 0)
+
+	case "refresh_token":
+		token, err := h.oauthRepo.GetTokenByRefreshHash(auth.HashToken(r.PostForm.Get("refresh_token")))
+		if err == sql.ErrNoRows || (err == nil && (token.ClientID != client.Id || time.Now().After(token.RefreshExpiresAt))) {
+			writeOAuthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, "Refresh token is invalid or expired")
+			return
+		} else if err != nil {
+			writeOAuthError(w, http.StatusInternalServerError, oauthErrorServerError, "Error getting refresh token")
+			return
+		}
+		// The new tokens can be limited to fewer scopes than the app was given
+		scopes := token.Scopes
+		if r.PostForm.Get("scope") != "" {
+			var ok bool
+			if scopes, ok = parseScopes(r.PostForm.Get("scope"), token.Scopes); !ok {
+				writeOAuthError(w, http.StatusBadRequest, oauthErrorInvalidScope, "Scopes can only be narrowed on refresh")
+				return
+			}
+		}
+		h.issueTokens(w, client, token.UserID, scopes, token.Id)
+
+	default:
+		writeOAuthError(w, http.StatusBadRequest, oauthErrorUnsupportedGrantType, "Supported grant types are authorization_code and refresh_token")
+	}
+}
+
+// issueTokens creates an access token and a refresh token, replacing the token pair with the ID replaceID.
+func (h *OAuthHandler) issueTokens(w http.ResponseWriter, client model.OAuthClient, userID int, scopes []string, replaceID int) {
+	accessSecret, _, err1 := auth.GenerateToken()
+	refreshSecret, _, err2 := auth.GenerateToken()
+	if err1 != nil || err2 != nil {
+		writeOAuthError(w, http.StatusInternalServerError, oauthErrorServerError, "Error creating tokens")
+		return
+	}
+	accessToken := auth.OAuthAccessTokenPrefix + accessSecret
+	refreshToken := auth.OAuthRefreshTokenPrefix + refreshSecret
+	now := time.Now()
+	token := model.OAuthToken{
+		ClientID:         client.Id,
+		UserID:           userID,
+		AccessHash:       auth.HashToken(accessToken),
+		RefreshHash:      auth.HashToken(refreshToken),
+		Scopes:           scopes,
+		AccessExpiresAt:  now.Add(h.config.AccessTokenTTL),
+		RefreshExpiresAt: now.Add(h.config.RefreshTokenTTL),
+	}
+
+	var err error
+	if replaceID == 0 {
+		_, err = h.oauthRepo.CreateToken(token)
+	} else {
+		_, err = h.oauthRepo.RotateToken(replaceID, token)
+	}
+	if err == sql.ErrNoRows {
+		writeOAuthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, "Refresh token was already used")
+		return
+	} else if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, oauthErrorServerError, "Error saving tokens")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token":  accessToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(h.config.AccessTokenTTL.Seconds()),
+		"refresh_token": refreshToken,
+		"scope":         strings.Join(scopes, " "),
+	})
+}
+
+// RevokeHandler revokes an access token or a refresh token of the calling app as defined by RFC 7009.
+// Both tokens of the pair stop working. Unknown tokens are not an error.
+func (h *OAuthHandler) RevokeHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauthErrorInvalidRequest, "Error parsing form data")
+		return
+	}
+	client, ok := h.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+
+	hash := auth.HashToken(r.PostForm.Get("token"))
+	token, err := h.oauthRepo.GetTokenByAccessHash(hash)
+	if err == sql.ErrNoRows {
+		token, err = h.oauthRepo.GetTokenByRefreshHash(hash)
+	}
+	if err == nil && token.ClientID == client.Id {
+		err = h.oauthRepo.DeleteToken(token.Id)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		writeOAuthError(w, http.StatusInternalServerError, oauthErrorServerError, "Error revoking token")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// authenticateClient identifies the app calling the token or revocation endpoint and writes the error response
+// if that fails. Confidential apps have to send their secret.
+func (h *OAuthHandler) authenticateClient(w http.ResponseWriter, r *http.Request) (model.OAuthClient, bool) {
+	clientID, clientSecret, basic := r.BasicAuth()
+	if basic {
+		// RFC 6749 has the client credentials form-encoded before they go into the Basic header
+		id, err1 := url.QueryUnescape(clientID)
+		secret, err2 := url.QueryUnescape(clientSecret)
+		if err1 != nil || err2 != nil {
+			writeClientError(w, basic)
+			return model.OAuthClient{}, false
+		}
+		clientID, clientSecret = id, secret
+	} else {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	client, err := h.oauthRepo.GetClientByClientID(clientID)
+	if err == sql.ErrNoRows {
+		writeClientError(w, basic)
+		return model.OAuthClient{}, false
+	} else if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, oauthErrorServerError, "Error getting app")
+		return model.OAuthClient{}, false
+	}
+	if client.Confidential {
+		hash := auth.HashToken(clientSecret)
+		if clientSecret == "" || subtle.ConstantTimeCompare([]byte(hash), []byte(client.ClientSecretHash)) != 1 {
+			writeClientError(w, basic)
+			return model.OAuthClient{}, false
+		}
+	}
+	return client, true
+}
+
+// GetAuthorizationsHandler lists the apps the authenticated user gave access to.
+func (h *OAuthHandler) GetAuthorizationsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	authorizations, err := h.oauthRepo.GetAuthorizationsByUserID(userID)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting authorized apps: "+err.Error())
+		return
+	}
+	if authorizations == nil {
+		authorizations = []model.OAuthAuthorization{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(authorizations)
+}
+
+// DeleteAuthorizationHandler takes the access of an app away, revoking all tokens it holds for the user.
+func (h *OAuthHandler) DeleteAuthorizationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	err = h.oauthRepo.DeleteAuthorization(userID, mux.Vars(r)["clientId"])
+	if err == sql.ErrNoRows {
+		auth.WriteJSONError(w, http.StatusNotFound, "App has no access")
+		return
+	} else if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error revoking access: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Access revoked",
+	})
+}
+
+// validRedirectURI accepts absolute URLs without fragment. Plain http is only allowed for local development
+// and native apps listening on the loopback interface, custom schemes are allowed for native apps.
+func validRedirectURI(redirectURI string) bool {
+	u, err := url.Parse(redirectURI)
+	if err != nil || !u.IsAbs() || strings.Contains(redirectURI, "#") {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return u.Host != ""
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	case "javascript", "data", "file", "vbscript":
+		return false
+	}
+	return true
+}
+
+// parseScopes splits a space-separated scope parameter, all scopes have to be in allowed.
+func parseScopes(scope string, allowed []string) ([]string, bool) {
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if !containsScope(allowed, s) {
+			return nil, false
+		}
+		if !containsScope(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes, len(scopes) > 0
+}
+
+// redirectURL adds the parameters and the state of the app to the redirect URI.
+func redirectURL(redirectURI string, params url.Values, state string) string {
+	u, _ := url.Parse(redirectURI)
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func writeRedirectTo(w http.ResponseWriter, redirectURI string, params url.Values, state string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"redirect_to": redirectURL(redirectURI, params, state),
+	})
+}
+
+// writeOAuthError writes an error response of the token endpoint as defined by RFC 6749.
+func writeOAuthError(w http.ResponseWriter, status int, errorCode, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":             errorCode,
+		"error_description": description,
+	})
+}
+
+// writeClientError rejects the client authentication, with a Basic challenge if the app used Basic.
+func writeClientError(w http.ResponseWriter, basic bool) {
+	status := http.StatusBadRequest
+	if basic {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		status = http.StatusUnauthorized
+	}
+	writeOAuthError(w, status, oauthErrorInvalidClient, "App authentication failed")
+}
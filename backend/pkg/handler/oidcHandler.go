@@ -0,0 +1,451 @@
+package handler
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/config"
+	"backend/pkg/model"
+	"backend/pkg/repository"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// OIDCHandler handles logging in with external OpenID Connect identity providers. A provider identity is linked
+// to one user, users logging in with an identity that isn't linked yet get a new account created from the claims.
+// The login runs through browser redirects, so the results are sent to the frontend in the query string.
+type OIDCHandler struct {
+	identityRepo  *repository.IdentityRepository
+	userRepo      *repository.UserRepository
+	sessions      *auth.SessionManager
+	accounts      *AccountHandler
+	twoFactor     *TwoFactorHandler
+	notifications *NotificationHandler
+	invites       *InviteHandler
+	providers     map[string]*auth.OIDCProvider
+	names         []string
+	app           config.App
+	config        config.OIDC
+}
+
+func NewOIDCHandler(iRepo *repository.IdentityRepository, uRepo *repository.UserRepository, sessions *auth.SessionManager, accounts *AccountHandler, twoFactor *TwoFactorHandler, notifications *NotificationHandler, invites *InviteHandler, app config.App, config config.OIDC) *OIDCHandler {
+	h := &OIDCHandler{identityRepo: iRepo, userRepo: uRepo, sessions: sessions, accounts: accounts, twoFactor: twoFactor, notifications: notifications, invites: invites,
+		providers: make(map[string]*auth.OIDCProvider), app: app, config: config}
+	for _, provider := range config.Providers {
+		h.providers[provider.Name] = auth.NewOIDCProvider(provider)
+		h.names = append(h.names, provider.Name)
+	}
+	return h
+}
+
+// Errors the callback sends to the frontend in oidc_error
+const (
+	oidcErrorProvider           = "provider_error"
+	oidcErrorInvalidState       = "invalid_state"
+	oidcErrorLoginFailed        = "login_failed"
+	oidcErrorEmailRequired      = "email_required"
+	oidcErrorAccountExists      = "account_exists"
+	oidcErrorIdentityInUse      = "identity_in_use"
+	oidcErrorRegistrationClosed = "registration_closed"
+	oidcErrorInviteInvalid      = "invite_invalid"
+)
+
+// oidcMaxUsernameAttempts is how many numbered usernames are tried before a random suffix is used
+const oidcMaxUsernameAttempts = 20
+
+// oidcStateCookie ties a login to the browser that started it: the callback only accepts the state stored in it,
+// so nobody can send someone else the callback URL of their own login and log them into the wrong account.
+const (
+	oidcStateCookie     = "oidc_state"
+	oidcStateCookiePath = "/api/users/oidc/"
+)
+
+// GetProvidersHandler lists the configured identity providers for the login buttons.
+func (h *OIDCHandler) GetProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	providers := make([]map[string]string, 0, len(h.names))
+	for _, name := range h.names {
+		providers = append(providers, map[string]string{
+			"name":         name,
+			"display_name": h.providers[name].DisplayName(),
+			"login_url":    h.app.BackendURL + "/api/users/oidc/" + name + "/login",
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(providers)
+}
+
+// LoginHandler redirects the browser to the login page of the provider. ?remember_me=true gives the session
+// the longer lifetime once the login is finished, ?invite_code= is used if the login creates the account.
+func (h *OIDCHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	rememberMe, _ := strconv.ParseBool(r.URL.Query().Get("remember_me"))
+	h.redirectToProvider(w, r, 0, rememberMe, r.URL.Query().Get("invite_code"))
+}
+
+// LinkHandler redirects the authenticated user to the provider to link the identity there to the account.
+func (h *OIDCHandler) LinkHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	h.redirectToProvider(w, r, userID, false, "")
+}
+
+func (h *OIDCHandler) redirectToProvider(w http.ResponseWriter, r *http.Request, userID int, rememberMe bool, inviteCode string) {
+	provider, ok := h.providers[mux.Vars(r)["provider"]]
+	if !ok {
+		auth.WriteJSONError(w, http.StatusNotFound, "Unknown identity provider")
+		return
+	}
+
+	state, stateHash, err1 := auth.GenerateToken()
+	nonce, _, err2 := auth.GenerateToken()
+	codeVerifier, err3 := auth.NewCodeVerifier()
+	if err1 != nil || err2 != nil || err3 != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error creating login state")
+		return
+	}
+	err := h.identityRepo.CreateState(stateHash, model.OIDCState{
+		Provider:     provider.Name(),
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		UserID:       userID,
+		RememberMe:   rememberMe,
+		InviteCode:   inviteCode,
+		ExpiresAt:    time.Now().Add(h.config.StateTTL),
+	})
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error saving login state: "+err.Error())
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, codeVerifier)
+	if err != nil {
+		log.Printf("Error reaching identity provider %s: %v", provider.Name(), err)
+		auth.WriteJSONError(w, http.StatusBadGateway, "Identity provider not reachable")
+		return
+	}
+	h.setStateCookie(w, state, int(h.config.StateTTL.Seconds()))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// setStateCookie stores the state of the login in the browser, an empty state with maxAge -1 removes it.
+// SameSite=Lax cookies are sent along when the provider redirects back to the callback.
+func (h *OIDCHandler) setStateCookie(w http.ResponseWriter, state string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		MaxAge:   maxAge,
+		Path:     oidcStateCookiePath,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.app.BackendURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// CallbackHandler is where the provider sends the browser back to after the login. It verifies the ID token,
+// then links the identity or logs the user in, creating the account on the first login.
+// Users with two-factor authentication still have to enter their code, the frontend gets mfa_token for it.
+func (h *OIDCHandler) CallbackHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.providers[mux.Vars(r)["provider"]]
+	if !ok {
+		auth.WriteJSONError(w, http.StatusNotFound, "Unknown identity provider")
+		return
+	}
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		log.Printf("Identity provider %s returned %s: %s", provider.Name(), query.Get("error"), query.Get("error_description"))
+		h.redirectWithError(w, r, oidcErrorProvider)
+		return
+	}
+
+	// The state has to come from the browser that started the login
+	cookie, err := r.Cookie(oidcStateCookie)
+	h.setStateCookie(w, "", -1)
+	if err != nil || cookie.Value == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(query.Get("state"))) != 1 {
+		h.redirectWithError(w, r, oidcErrorInvalidState)
+		return
+	}
+
+	state, err := h.identityRepo.ConsumeState(auth.HashToken(query.Get("state")))
+	if err != nil || state.Provider != provider.Name() {
+		if err != nil && err != sql.ErrNoRows {
+			log.Println("Error getting login state: ", err)
+		}
+		h.redirectWithError(w, r, oidcErrorInvalidState)
+		return
+	}
+
+	claims, err := provider.Exchange(r.Context(), query.Get("code"), state.CodeVerifier, state.Nonce)
+	if err != nil {
+		log.Printf("Login with identity provider %s failed: %v", provider.Name(), err)
+		h.redirectWithError(w, r, oidcErrorLoginFailed)
+		return
+	}
+
+	if state.UserID != 0 {
+		h.linkIdentity(w, r, provider, state.UserID, claims)
+		return
+	}
+
+	var userID int
+	identity, err := h.identityRepo.GetIdentity(claims.Issuer, claims.Subject)
+	switch {
+	case err == nil:
+		userID = identity.UserID
+		if err := h.identityRepo.UpdateIdentityLogin(identity.Id, claims.Email); err != nil {
+			log.Println("Error updating identity: ", err)
+		}
+	case err == sql.ErrNoRows:
+		var errorCode string
+		userID, errorCode = h.createUser(provider, claims, state.InviteCode)
+		if errorCode != "" {
+			h.redirectWithError(w, r, errorCode)
+			return
+		}
+	default:
+		log.Println("Error getting identity: ", err)
+		h.redirectWithError(w, r, oidcErrorLoginFailed)
+		return
+	}
+
+	enabled, err := h.twoFactor.IsEnabled(userID)
+	if err != nil {
+		log.Println("Error checking two-factor authentication: ", err)
+		h.redirectWithError(w, r, oidcErrorLoginFailed)
+		return
+	}
+	if enabled {
+		mfaToken, err := h.twoFactor.StartChallenge(userID, state.RememberMe)
+		if err != nil {
+			log.Println("Error starting two-factor challenge: ", err)
+			h.redirectWithError(w, r, oidcErrorLoginFailed)
+			return
+		}
+		http.Redirect(w, r, h.app.FrontendURL+"/auth?mfa_token="+url.QueryEscape(mfaToken), http.StatusFound)
+		return
+	}
+
+	if err := h.sessions.Start(w, r, userID, state.RememberMe); err != nil {
+		log.Println("Error creating session: ", err)
+		h.redirectWithError(w, r, oidcErrorLoginFailed)
+		return
+	}
+	http.Redirect(w, r, h.app.FrontendURL+"/", http.StatusFound)
+}
+
+func (h *OIDCHandler) linkIdentity(w http.ResponseWriter, r *http.Request, provider *auth.OIDCProvider, userID int, claims auth.OIDCClaims) {
+	identity, err := h.identityRepo.GetIdentity(claims.Issuer, claims.Subject)
+	if err == nil && identity.UserID != userID {
+		h.redirectWithError(w, r, oidcErrorIdentityInUse)
+		return
+	} else if err == sql.ErrNoRows {
+		_, err = h.identityRepo.CreateIdentity(model.UserIdentity{
+			UserID:   userID,
+			Provider: provider.Name(),
+			Issuer:   claims.Issuer,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		})
+		if err != nil {
+			log.Println("Error linking identity: ", err)
+			h.redirectWithError(w, r, oidcErrorLoginFailed)
+			return
+		}
+		if err := h.notifications.CreateSecurityNotification(userID, "Your "+provider.DisplayName()+" account was linked"); err != nil {
+			log.Println("Error creating security notification: ", err)
+		}
+	} else if err != nil {
+		log.Println("Error getting identity: ", err)
+		h.redirectWithError(w, r, oidcErrorLoginFailed)
+		return
+	}
+	http.Redirect(w, r, h.app.FrontendURL+"/?oidc_linked="+url.QueryEscape(provider.Name()), http.StatusFound)
+}
+
+// createUser creates the account for the first login with an identity, the profile is filled from the claims.
+// An existing account with the same email address isn't taken over, its owner has to log in and link the identity.
+// The password is random, users who want to log in with a password as well set one with the password reset.
+func (h *OIDCHandler) createUser(provider *auth.OIDCProvider, claims auth.OIDCClaims, inviteCode string) (int, string) {
+	email := strings.TrimSpace(claims.Email)
+	if email == "" {
+		return 0, oidcErrorEmailRequired
+	}
+	taken, err := h.userRepo.IsUsernameOrEmailTaken(email, 0)
+	if err != nil {
+		log.Println("Error checking email address: ", err)
+		return 0, oidcErrorLoginFailed
+	}
+	if taken {
+		return 0, oidcErrorAccountExists
+	}
+
+	username, err := h.uniqueUsername(claims)
+	if err != nil {
+		log.Println("Error choosing username: ", err)
+		return 0, oidcErrorLoginFailed
+	}
+	password, _, err := auth.GenerateToken()
+	if err != nil {
+		return 0, oidcErrorLoginFailed
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, oidcErrorLoginFailed
+	}
+
+	user := model.RegistrationData{
+		Username:  username,
+		Email:     email,
+		Password:  string(hashedPassword),
+		FirstName: claims.GivenName,
+		LastName:  claims.FamilyName,
+	}
+	if user.FirstName == "" && user.LastName == "" {
+		user.FirstName, user.LastName, _ = strings.Cut(strings.TrimSpace(claims.Name), " ")
+	}
+	if user.FirstName == "" {
+		user.FirstName = username
+	}
+	if _, err := time.Parse("2006-01-02", claims.Birthdate); err == nil {
+		user.DOB = claims.Birthdate
+	}
+	if strings.HasPrefix(claims.Picture, "https://") || strings.HasPrefix(claims.Picture, "http://") {
+		user.AvatarURL = claims.Picture
+	}
+
+	invite, err := h.invites.RedeemInvite(inviteCode)
+	switch err {
+	case nil:
+	case errRegistrationClosed, errInviteRequired:
+		return 0, oidcErrorRegistrationClosed
+	case errInviteInvalid:
+		return 0, oidcErrorInviteInvalid
+	default:
+		log.Println("Error checking invitation code: ", err)
+		return 0, oidcErrorLoginFailed
+	}
+	userID, err := h.identityRepo.CreateUserWithIdentity(user, bool(claims.EmailVerified), model.UserIdentity{
+		Provider: provider.Name(),
+		Issuer:   claims.Issuer,
+		Subject:  claims.Subject,
+		Email:    email,
+	})
+	if err != nil {
+		h.invites.ReleaseInvite(invite)
+		log.Println("Error creating user from identity: ", err)
+		return 0, oidcErrorLoginFailed
+	}
+	h.invites.CompleteSignup(userID, invite)
+	if !claims.EmailVerified {
+		if err := h.accounts.SendVerificationEmail(userID); err != nil {
+			log.Println("Error sending verification email: ", err)
+		}
+	}
+	return userID, ""
+}
+
+// uniqueUsername derives a free username from preferred_username or the email address, adding a number if needed.
+func (h *OIDCHandler) uniqueUsername(claims auth.OIDCClaims) (string, error) {
+	base := sanitizeUsername(claims.PreferredUsername)
+	if base == "" {
+		local, _, _ := strings.Cut(claims.Email, "@")
+		base = sanitizeUsername(local)
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	candidate := base
+	for i := 2; i <= oidcMaxUsernameAttempts; i++ {
+		taken, err := h.userRepo.IsUsernameOrEmailTaken(candidate, 0)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+		candidate = base + strconv.Itoa(i)
+	}
+	// Very common names, a random suffix ends the search
+	token, _, err := auth.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	return base + "_" + strings.ToLower(token[:6]), nil
+}
+
+// sanitizeUsername keeps letters, digits and _ . - of the name, at most 30 characters.
+func sanitizeUsername(name string) string {
+	var b strings.Builder
+	for _, c := range name {
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '.' || c == '-' {
+			b.WriteRune(c)
+		}
+		if b.Len() == 30 {
+			break
+		}
+	}
+	return b.String()
+}
+
+func (h *OIDCHandler) redirectWithError(w http.ResponseWriter, r *http.Request, code string) {
+	http.Redirect(w, r, h.app.FrontendURL+"/auth?oidc_error="+code, http.StatusFound)
+}
+
+// GetIdentitiesHandler lists the provider identities linked to the authenticated user.
+func (h *OIDCHandler) GetIdentitiesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	identities, err := h.identityRepo.GetIdentitiesByUserID(userID)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting identities: "+err.Error())
+		return
+	}
+	if identities == nil {
+		identities = []model.UserIdentity{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(identities)
+}
+
+// DeleteIdentityHandler unlinks one of the authenticated user's identities, it can't be used to log in anymore.
+func (h *OIDCHandler) DeleteIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Invalid identity ID")
+		return
+	}
+
+	err = h.identityRepo.DeleteIdentity(id, userID)
+	if err == sql.ErrNoRows {
+		auth.WriteJSONError(w, http.StatusNotFound, "Identity not found")
+		return
+	} else if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error deleting identity: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Identity unlinked",
+	})
+}
@@ -0,0 +1,309 @@
+package handler
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/config"
+	"backend/pkg/model"
+	"backend/pkg/repository"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// PasskeyHandler handles registering passkeys and logging in with them instead of a password.
+// Every ceremony has two steps: begin returns the options for the browser WebAuthn API,
+// finish verifies what the authenticator answered.
+type PasskeyHandler struct {
+	passkeyRepo *repository.PasskeyRepository
+	userRepo    *repository.UserRepository
+	sessions    *auth.SessionManager
+	webAuthn    *auth.WebAuthn
+	config      config.WebAuthn
+}
+
+func NewPasskeyHandler(pRepo *repository.PasskeyRepository, uRepo *repository.UserRepository, sessions *auth.SessionManager, config config.WebAuthn) *PasskeyHandler {
+	return &PasskeyHandler{passkeyRepo: pRepo, userRepo: uRepo, sessions: sessions, webAuthn: auth.NewWebAuthn(config), config: config}
+}
+
+// BeginRegistrationHandler returns the options for navigator.credentials.create to add a passkey to the authenticated user.
+func (h *PasskeyHandler) BeginRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	username, err := h.userRepo.GetUsernameByID(userID)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting user: "+err.Error())
+		return
+	}
+	passkeys, err := h.passkeyRepo.GetPasskeysByUserID(userID)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting passkeys: "+err.Error())
+		return
+	}
+
+	challenge, err := h.newChallenge(userID, model.WebAuthnPurposeRegistration)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error creating challenge: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"publicKey": h.webAuthn.CreationOptions(challenge, []byte(strconv.Itoa(userID)), username, descriptors(passkeys)),
+	})
+}
+
+// FinishRegistrationHandler verifies the new credential and stores it as a passkey of the authenticated user.
+func (h *PasskeyHandler) FinishRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	var data model.PasskeyCredentialData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Error parsing JSON data: "+err.Error())
+		return
+	}
+	clientDataJSON, err1 := auth.DecodeBase64URL(data.Response.ClientDataJSON)
+	attestationObject, err2 := auth.DecodeBase64URL(data.Response.AttestationObject)
+	if err1 != nil || err2 != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Invalid credential encoding")
+		return
+	}
+
+	challenge, ok := h.consumeChallenge(w, clientDataJSON, model.WebAuthnPurposeRegistration)
+	if !ok {
+		return
+	}
+	if challenge.UserID != userID {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Passkey registration expired, please try again")
+		return
+	}
+
+	credential, err := h.webAuthn.VerifyRegistration(challenge.Challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Passkey registration failed: "+err.Error())
+		return
+	}
+
+	name := data.Name
+	if name == "" {
+		name = "Passkey " + time.Now().Format("2006-01-02")
+	}
+	id, err := h.passkeyRepo.CreatePasskey(model.Passkey{
+		UserID:       userID,
+		CredentialID: base64.RawURLEncoding.EncodeToString(credential.ID),
+		PublicKey:    credential.PublicKey,
+		SignCount:    credential.SignCount,
+		Transports:   data.Response.Transports,
+		Name:         name,
+	})
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusConflict, "Error saving passkey, it may already be registered: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Passkey registered",
+		"id":      id,
+	})
+}
+
+// BeginLoginHandler returns the options for navigator.credentials.get. With a username only the passkeys
+// of this user are allowed, without one the browser offers every passkey it has for the site.
+func (h *PasskeyHandler) BeginLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var data model.PasskeyLoginData
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			auth.WriteJSONError(w, http.StatusBadRequest, "Error parsing JSON data: "+err.Error())
+			return
+		}
+	}
+
+	var userID int
+	var allow []auth.PublicKeyCredentialDescriptor
+	if data.Username != "" {
+		// Unknown users get a challenge without allowed credentials, so the answer doesn't tell whether the account exists
+		user, err := h.userRepo.GetUserByEmailOrNickname(data.Username)
+		if err == nil {
+			passkeys, err := h.passkeyRepo.GetPasskeysByUserID(user.Id)
+			if err != nil {
+				auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting passkeys: "+err.Error())
+				return
+			}
+			userID = user.Id
+			allow = descriptors(passkeys)
+		} else if err != sql.ErrNoRows {
+			auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting user: "+err.Error())
+			return
+		}
+	}
+
+	challenge, err := h.newChallenge(userID, model.WebAuthnPurposeLogin)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error creating challenge: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"publicKey": h.webAuthn.RequestOptions(challenge, allow),
+	})
+}
+
+// FinishLoginHandler verifies the signed challenge and starts a session for the owner of the passkey.
+// Passkeys require user verification (PIN or biometrics) on the authenticator, so no TOTP code is asked for.
+func (h *PasskeyHandler) FinishLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var data model.PasskeyCredentialData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Error parsing JSON data: "+err.Error())
+		return
+	}
+	clientDataJSON, err1 := auth.DecodeBase64URL(data.Response.ClientDataJSON)
+	authenticatorData, err2 := auth.DecodeBase64URL(data.Response.AuthenticatorData)
+	signature, err3 := auth.DecodeBase64URL(data.Response.Signature)
+	rawID, err4 := auth.DecodeBase64URL(data.RawID)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Invalid credential encoding")
+		return
+	}
+
+	challenge, ok := h.consumeChallenge(w, clientDataJSON, model.WebAuthnPurposeLogin)
+	if !ok {
+		return
+	}
+
+	passkey, err := h.passkeyRepo.GetPasskeyByCredentialID(base64.RawURLEncoding.EncodeToString(rawID))
+	if err == sql.ErrNoRows || (err == nil && challenge.UserID != 0 && passkey.UserID != challenge.UserID) {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "Unknown passkey")
+		return
+	} else if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting passkey: "+err.Error())
+		return
+	}
+
+	signCount, err := h.webAuthn.VerifyAssertion(challenge.Challenge, passkey.PublicKey, passkey.SignCount, clientDataJSON, authenticatorData, signature)
+	if err != nil {
+		log.Printf("Passkey login for user %d failed: %v", passkey.UserID, err)
+		auth.WriteJSONError(w, http.StatusUnauthorized, "Passkey verification failed")
+		return
+	}
+	if err := h.passkeyRepo.UpdatePasskeyUsage(passkey.Id, signCount); err != nil {
+		log.Println("Error updating passkey usage: ", err)
+	}
+
+	if err := h.sessions.Start(w, r, passkey.UserID, data.RememberMe); err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error creating session: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Login successful",
+	})
+}
+
+// GetPasskeysHandler lists the passkeys of the authenticated user.
+func (h *PasskeyHandler) GetPasskeysHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	passkeys, err := h.passkeyRepo.GetPasskeysByUserID(userID)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting passkeys: "+err.Error())
+		return
+	}
+	if passkeys == nil {
+		passkeys = []model.Passkey{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(passkeys)
+}
+
+// DeletePasskeyHandler removes one of the authenticated user's passkeys.
+func (h *PasskeyHandler) DeletePasskeyHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Invalid passkey ID")
+		return
+	}
+
+	err = h.passkeyRepo.DeletePasskey(id, userID)
+	if err == sql.ErrNoRows {
+		auth.WriteJSONError(w, http.StatusNotFound, "Passkey not found")
+		return
+	} else if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error deleting passkey: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Passkey deleted",
+	})
+}
+
+func (h *PasskeyHandler) newChallenge(userID int, purpose string) (string, error) {
+	challenge, err := auth.NewChallenge()
+	if err != nil {
+		return "", err
+	}
+	err = h.passkeyRepo.CreateChallenge(model.WebAuthnChallenge{
+		Challenge: challenge,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(h.config.ChallengeTTL),
+	}, purpose)
+	if err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// consumeChallenge looks up the challenge the browser signed and removes it, so it can't be answered twice.
+// It writes the error response itself and returns false if the challenge is unknown or expired.
+func (h *PasskeyHandler) consumeChallenge(w http.ResponseWriter, clientDataJSON []byte, purpose string) (model.WebAuthnChallenge, bool) {
+	value, err := auth.ChallengeFromClientData(clientDataJSON)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return model.WebAuthnChallenge{}, false
+	}
+	challenge, err := h.passkeyRepo.ConsumeChallenge(value, purpose)
+	if err == sql.ErrNoRows {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Passkey request expired, please try again")
+		return model.WebAuthnChallenge{}, false
+	} else if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting challenge: "+err.Error())
+		return model.WebAuthnChallenge{}, false
+	}
+	return challenge, true
+}
+
+func descriptors(passkeys []model.Passkey) []auth.PublicKeyCredentialDescriptor {
+	result := make([]auth.PublicKeyCredentialDescriptor, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		result = append(result, auth.PublicKeyCredentialDescriptor{
+			Type:       "public-key",
+			ID:         passkey.CredentialID,
+			Transports: passkey.Transports,
+		})
+	}
+	return result
+}
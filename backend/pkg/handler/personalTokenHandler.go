@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/model"
+	"backend/pkg/repository"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// personalTokenPrefix marks personal access tokens, so leaked tokens are easy to recognize
+const personalTokenPrefix = "isp_"
+
+// maxPersonalTokens is the number of tokens a user can have at the same time
+const maxPersonalTokens = 50
+
+// PersonalTokenHandler manages personal access tokens, which let scripts and bots call the API with
+// Authorization: Bearer instead of a session cookie. The tokens themselves can only be managed with a session.
+type PersonalTokenHandler struct {
+	tokenRepo     *repository.PersonalTokenRepository
+	notifications *NotificationHandler
+}
+
+func NewPersonalTokenHandler(tRepo *repository.PersonalTokenRepository, notifications *NotificationHandler) *PersonalTokenHandler {
+	return &PersonalTokenHandler{tokenRepo: tRepo, notifications: notifications}
+}
+
+// GetTokensHandler lists the tokens of the authenticated user, without the token values.
+func (h *PersonalTokenHandler) GetTokensHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	tokens, err := h.tokenRepo.GetTokensByUserID(userID)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting tokens: "+err.Error())
+		return
+	}
+	if tokens == nil {
+		tokens = []model.PersonalToken{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// CreateTokenHandler creates a token with a name, scopes and an optional expiry time.
+// The token is only part of this response, it can't be shown again later.
+func (h *PersonalTokenHandler) CreateTokenHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	var data model.PersonalTokenData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Error parsing JSON data: "+err.Error())
+		return
+	}
+
+	data.Name = strings.TrimSpace(data.Name)
+	if data.Name == "" || len(data.Name) > 100 {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Name is required and can have at most 100 characters")
+		return
+	}
+	var scopes []string
+	for _, scope := range data.Scopes {
+		if !containsScope(model.TokenScopes, scope) {
+			auth.WriteJSONError(w, http.StatusBadRequest, "Unknown scope "+scope+", valid scopes are "+strings.Join(model.TokenScopes, ", "))
+			return
+		}
+		if !containsScope(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		auth.WriteJSONError(w, http.StatusBadRequest, "At least one scope is required")
+		return
+	}
+	if data.ExpiresAt != nil && !data.ExpiresAt.After(time.Now()) {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Expiry time must be in the future")
+		return
+	}
+
+	count, err := h.tokenRepo.CountTokensByUserID(userID)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error counting tokens: "+err.Error())
+		return
+	}
+	if count >= maxPersonalTokens {
+		auth.WriteJSONError(w, http.StatusConflict, "Too many tokens, revoke one first")
+		return
+	}
+
+	secret, _, err := auth.GenerateToken()
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error creating token")
+		return
+	}
+	token := personalTokenPrefix + secret
+	id, err := h.tokenRepo.CreateToken(model.PersonalToken{
+		UserID:    userID,
+		Name:      data.Name,
+		TokenHash: auth.HashToken(token),
+		Scopes:    scopes,
+		ExpiresAt: data.ExpiresAt,
+	})
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error saving token: "+err.Error())
+		return
+	}
+	if err := h.notifications.CreateSecurityNotification(userID, "A personal access token named "+data.Name+" was created"); err != nil {
+		log.Println("Error creating security notification: ", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":         id,
+		"name":       data.Name,
+		"scopes":     scopes,
+		"expires_at": data.ExpiresAt,
+		"token":      token,
+	})
+}
+
+// DeleteTokenHandler revokes one of the authenticated user's tokens.
+func (h *PersonalTokenHandler) DeleteTokenHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Invalid token ID")
+		return
+	}
+
+	err = h.tokenRepo.DeleteToken(id, userID)
+	if err == sql.ErrNoRows {
+		auth.WriteJSONError(w, http.StatusNotFound, "Token not found")
+		return
+	} else if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error deleting token: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Token revoked",
+	})
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
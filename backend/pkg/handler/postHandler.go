@@ -1,13 +1,22 @@
 package handler
 
 import (
+	"backend/pkg/auth"
+	"backend/pkg/config"
 	"backend/pkg/model"
+	"backend/pkg/ranking"
 	"backend/pkg/repository"
 	"backend/util"
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -18,11 +27,16 @@ type PostHandler struct {
 	friendsRepo     *repository.FriendsRepository
 	groupMemberRepo *repository.GroupMemberRepository
 	userRepo 	  	*repository.UserRepository
-	voteHandler     *VoteHandler
+	reactionHandler *ReactionHandler
+	mentionHandler  *MentionHandler
+	listRepo        *repository.AudienceListRepository
+	feedRepo        *repository.FeedRepository
+	ranker          ranking.Ranker
+	feed            config.Feed
 }
 
-func NewPostHandler(postRepo *repository.PostRepository, sessionRepo *repository.SessionRepository, friendsRepo *repository.FriendsRepository, groupMemberRepo *repository.GroupMemberRepository, userRepo *repository.UserRepository, voteHandler *VoteHandler) *PostHandler {
-	return &PostHandler{postRepo: postRepo, sessionRepo: sessionRepo, friendsRepo: friendsRepo, groupMemberRepo: groupMemberRepo, userRepo: userRepo, voteHandler: voteHandler}
+func NewPostHandler(postRepo *repository.PostRepository, sessionRepo *repository.SessionRepository, friendsRepo *repository.FriendsRepository, groupMemberRepo *repository.GroupMemberRepository, userRepo *repository.UserRepository, reactionHandler *ReactionHandler, mentionHandler *MentionHandler, listRepo *repository.AudienceListRepository, feedRepo *repository.FeedRepository, ranker ranking.Ranker, feed config.Feed) *PostHandler {
+	return &PostHandler{postRepo: postRepo, sessionRepo: sessionRepo, friendsRepo: friendsRepo, groupMemberRepo: groupMemberRepo, userRepo: userRepo, reactionHandler: reactionHandler, mentionHandler: mentionHandler, listRepo: listRepo, feedRepo: feedRepo, ranker: ranker, feed: feed}
 }
 
 func (h *PostHandler) CreatePostHandler(w http.ResponseWriter, r *http.Request) {
@@ -37,18 +51,41 @@ func (h *PostHandler) CreatePostHandler(w http.ResponseWriter, r *http.Request)
 	request.GroupID, _ = strconv.Atoi(r.FormValue("group"))
 	request.PrivacySetting = r.FormValue("privacy-setting")
 
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error confirming authentication: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// The audience of a custom post is sent as repeated audience and audience_list fields
+	// or as comma separated IDs
+	audience, err := parseAudience(r.MultipartForm.Value["audience"])
+	if err != nil {
+		http.Error(w, "Invalid audience: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	lists, err := parseAudience(r.MultipartForm.Value["audience_list"])
+	if err != nil {
+		http.Error(w, "Invalid audience list: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var status int
+	request.Audience, request.AudienceLists, status, err = h.postAudience(userID, request.PrivacySetting, request.GroupID, audience, lists)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
 	// Creates the post in database
 	post, err := h.postRepo.CreatePost(&request, userID)
 	if err != nil {
 		http.Error(w, "Failed to create the post: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if _, err := h.mentionHandler.MentionInPost(post.PostID, userID, post.Title, post.Content); err != nil {
+		http.Error(w, "Failed to store the mentions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 	util.ImageSave(w, r, strconv.Itoa(post.PostID), "post")
 	// Successful response
 	response := map[string]interface{}{
@@ -70,18 +107,37 @@ func (h *PostHandler) EditPostHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Confirm user auth and get userid
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error confirming user authentication: "+err.Error(), http.StatusUnauthorized)
 		return
 	}
 
+	post, err := h.postRepo.GetPostByID(request.Id)
+	if err == sql.ErrNoRows || (err == nil && post.UserID != userID) {
+		http.Error(w, "Failed to update the post: no post found with the specified id that belongs to the user", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to retrieve the post: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var status int
+	request.Audience, request.AudienceLists, status, err = h.postAudience(userID, request.PrivacySetting, post.GroupID, request.Audience, request.AudienceLists)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
 	// Update the post in the database
 	err = h.postRepo.UpdatePost(request.Id, userID, request)
 	if err != nil {
 		http.Error(w, "Failed to update the post: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if _, err := h.mentionHandler.MentionInPost(request.Id, userID, request.Title, request.Content); err != nil {
+		http.Error(w, "Failed to store the mentions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	// Successful response
 	response := map[string]string{
@@ -91,6 +147,107 @@ func (h *PostHandler) EditPostHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// GetPostAudienceHandler returns the user IDs who can see a custom post, only to its author.
+func (h *PostHandler) GetPostAudienceHandler(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Failed to parse post ID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		http.Error(w, "Error confirming user authentication: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	post, err := h.postRepo.GetPostByID(postID)
+	if err == sql.ErrNoRows || (err == nil && post.UserID != userID) {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to retrieve the post: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	audience, err := h.postRepo.GetPostAudience(postID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve the audience: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	lists, err := h.postRepo.GetPostAudienceLists(postID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve the audience lists: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"privacy_setting": post.PrivacySetting,
+		"audience":        audience,
+		"audience_lists":  lists,
+	})
+}
+
+// postAudience checks the audience of a post with the 'custom' privacy setting, it can contain accepted
+// friends of the author and audience lists of the author. Posts with other privacy settings get no audience.
+// On error it also returns the status code to answer with.
+func (h *PostHandler) postAudience(userID int, privacySetting string, groupID int, audience []int, lists []int) ([]int, []int, int, error) {
+	if privacySetting != "custom" {
+		return nil, nil, 0, nil
+	}
+	if groupID != 0 {
+		return nil, nil, http.StatusBadRequest, errors.New("Group posts can't have a custom audience")
+	}
+
+	isFriend, err := friendSet(h.friendsRepo, userID)
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, errors.New("Failed to retrieve friends: " + err.Error())
+	}
+	checked, err := onlyFriends(isFriend, audience, "audience")
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, err
+	}
+
+	checkedLists := []int{}
+	seen := make(map[int]bool, len(lists))
+	for _, id := range lists {
+		if !seen[id] {
+			seen[id] = true
+			checkedLists = append(checkedLists, id)
+		}
+	}
+	owned, err := h.listRepo.CountListsOwnedBy(userID, checkedLists)
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, errors.New("Failed to check audience lists: " + err.Error())
+	}
+	if owned != len(checkedLists) {
+		return nil, nil, http.StatusBadRequest, errors.New("Audience lists must be your own lists")
+	}
+
+	if len(checked) == 0 && len(checkedLists) == 0 {
+		return nil, nil, http.StatusBadRequest, errors.New("A custom post needs an audience of at least one friend or audience list")
+	}
+	return checked, checkedLists, 0, nil
+}
+
+// parseAudience reads user IDs from form values, each value can hold several comma separated IDs.
+func parseAudience(values []string) ([]int, error) {
+	var audience []int
+	for _, value := range values {
+		for _, field := range strings.Split(value, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			id, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a user ID", field)
+			}
+			audience = append(audience, id)
+		}
+	}
+	return audience, nil
+}
+
 func (h *PostHandler) DeletePostHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse the post ID from the URL
 	vars := mux.Vars(r)
@@ -105,7 +262,7 @@ func (h *PostHandler) DeletePostHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	userId, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userId, err := auth.UserIDFromRequest(r)
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
@@ -128,59 +285,53 @@ func (h *PostHandler) DeletePostHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *PostHandler) GetAllPostsHandler(w http.ResponseWriter, r *http.Request) {
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error confirming user authentication: "+err.Error(), http.StatusUnauthorized)
 		return
 	}
+	// ?sort=ranked orders the feed by the ranker instead of by creation time
+	switch r.URL.Query().Get("sort") {
+	case "", "recent":
+	case "ranked":
+		h.writeRankedPage(w, r, userID)
+		return
+	default:
+		http.Error(w, "sort must be recent or ranked", http.StatusBadRequest)
+		return
+	}
+	page, err := h.postPage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// userGroupsPosts, err := h.postRepo.GetPostsByUserGroups(userID)
 	// if err != nil {
 	// 	http.Error(w, "Failed to retrieve posts by user groups: "+err.Error(), http.StatusInternalServerError)
 	// 	return
 	// }
-	posts, err := h.postRepo.GetAllPostsWithUserIDAccess(userID)
+	posts, err := h.postRepo.GetAllPostsWithUserIDAccess(userID, page)
 	if err != nil {
 		http.Error(w, "Failed to retrieve posts: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	// posts = append(posts, userGroupsPosts...)
 
-	// Append the votes to the posts
-	postsResponse, err := h.voteHandler.AppendVotesToPostsResponse(posts)
-	if err != nil {
-		http.Error(w, "Failed to append votes to posts: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-	for i, post := range postsResponse {
-		creatorId, err := h.postRepo.GetPostOwnerIDByPostID(post.Id); if err != nil {
-			http.Error(w, "Failed to retrieve post owner: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		creatorProfile, err := h.userRepo.GetUserProfileByID(int(creatorId))
-		if err != nil {
-			http.Error(w, "Failed to retrieve post owner profile: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		postsResponse[i].Creator = creatorProfile.Username
-		postsResponse[i].CreatorAvatar = creatorProfile.AvatarURL
-	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(postsResponse)
+	h.writePostsPage(w, posts, page, userID)
 }
 
 // GetAllUserPosts retrieves all posts for a specific user.
 // It takes the user ID from the request parameters and checks the user's authentication.
 // If the requesting user is the same as the user ID in the parameters, it retrieves all posts for that user.
-// If the requesting user is not the same, it checks the friend status between the requesting user and the user ID in the parameters.
-// If they are friends, it retrieves all posts for that user.
-// If they are not friends, it retrieves only the public posts for that user.
+// Otherwise it retrieves the posts the requesting user can see: public posts, private posts if they are friends
+// and custom posts if the requesting user is in their audience.
 // The retrieved posts are encoded as JSON and sent in the response.
 func (h *PostHandler) GetAllUserPostsHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID, ok := vars["id"]
 
-	requestingUserID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	requestingUserID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error confirming user authentication: "+err.Error(), http.StatusUnauthorized)
 		return
@@ -199,60 +350,28 @@ func (h *PostHandler) GetAllUserPostsHandler(w http.ResponseWriter, r *http.Requ
 		http.Error(w, "User ID is missing in parameters", http.StatusBadRequest)
 		return
 	}
+	page, err := h.postPage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	var posts []model.Post
 	if requestingUserID == intUserID {
-		posts, err = h.postRepo.GetAllUserPosts(requestingUserID)
+		posts, err = h.postRepo.GetAllUserPosts(requestingUserID, page)
 		if err != nil {
 			http.Error(w, "Failed to retrieve all user posts: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 	} else {
-		// check if the users are friends
-		status, err := h.friendsRepo.GetFriendStatus(requestingUserID, intUserID)
-		if err != nil {
-			http.Error(w, "Failed to retrieve friend status: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		// retrieve users public posts, and private posts if they are friends
-		if status == "accepted" {
-			posts, err = h.postRepo.GetAllUserPosts(intUserID)
-			if err != nil {
-				http.Error(w, "Failed to retrieve friend's posts: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-		} else {
-			posts, err = h.postRepo.GetAllUserPublicPosts(intUserID)
-			if err != nil {
-				http.Error(w, "Failed to retrieve user public posts: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-		}
-	}
-
-	// Append the votes to the posts
-	postsResponse, err := h.voteHandler.AppendVotesToPostsResponse(posts)
-	if err != nil {
-		http.Error(w, "Failed to append votes to posts: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	for i, post := range postsResponse {
-		creatorId, err := h.postRepo.GetPostOwnerIDByPostID(post.Id); if err != nil {
-			http.Error(w, "Failed to retrieve post owner: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		creatorProfile, err := h.userRepo.GetUserProfileByID(int(creatorId))
+		posts, err = h.postRepo.GetUserPostsWithUserIDAccess(intUserID, requestingUserID, page)
 		if err != nil {
-			http.Error(w, "Failed to retrieve post owner profile: "+err.Error(), http.StatusInternalServerError)
+			http.Error(w, "Failed to retrieve user posts: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
-		postsResponse[i].Creator = creatorProfile.Username
-		postsResponse[i].CreatorAvatar = creatorProfile.AvatarURL
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(postsResponse)
+	h.writePostsPage(w, posts, page, requestingUserID)
 }
 
 // ---------------------------------------------- //
@@ -266,11 +385,16 @@ func (h *PostHandler) GetPostsByGroupIDHandler(w http.ResponseWriter, r *http.Re
 		http.Error(w, "Group ID is missing in parameters", http.StatusBadRequest)
 		return
 	}
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error confirming user authentication: "+err.Error(), http.StatusUnauthorized)
 		return
 	}
+	page, err := h.postPage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	// check if user is in group
 	isMember, err := h.groupMemberRepo.IsUserGroupMember(userID, groupID)
 	if err != nil {
@@ -286,7 +410,7 @@ func (h *PostHandler) GetPostsByGroupIDHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	posts, err := h.postRepo.GetPostsByGroupID(groupID)
+	posts, err := h.postRepo.GetPostsByGroupID(groupID, page)
 	if err != nil && err != sql.ErrNoRows {
 		http.Error(w, "Failed to retrieve posts: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -298,28 +422,232 @@ func (h *PostHandler) GetPostsByGroupIDHandler(w http.ResponseWriter, r *http.Re
 		json.NewEncoder(w).Encode(response)
 	}
 
-	// Append the votes to the posts
-	postsResponse, err := h.voteHandler.AppendVotesToPostsResponse(posts)
+	h.writePostsPage(w, posts, page, userID)
+}
+
+// ---------------------------------------------- //
+// ------------- Tag Posts Handlers ------------- //
+// ---------------------------------------------- //
+
+// GetPostsByTagHandler returns a page of the posts with a hashtag in their text or in one of their comments,
+// newest first. Only posts the user can see show up, group posts only for members of the group.
+func (h *PostHandler) GetPostsByTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag, ok := repository.NormalizeTag(mux.Vars(r)["tag"])
+	if !ok {
+		http.Error(w, "Invalid tag", http.StatusBadRequest)
+		return
+	}
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		http.Error(w, "Error confirming user authentication: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+	page, err := h.postPage(r)
 	if err != nil {
-		http.Error(w, "Failed to append votes to posts: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	for i, post := range postsResponse {
-		creatorId, err := h.postRepo.GetPostOwnerIDByPostID(post.Id); if err != nil {
-			http.Error(w, "Failed to retrieve post owner: "+err.Error(), http.StatusInternalServerError)
-			return
+	posts, err := h.postRepo.GetPostsByTag(tag, userID, page)
+	if err != nil {
+		http.Error(w, "Failed to retrieve posts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.writePostsPage(w, posts, page, userID)
+}
+
+// ---------------------------------------------- //
+// ---------------- Feed Pages ------------------ //
+// ---------------------------------------------- //
+
+// postPage reads the page of a feed request from ?cursor=, the next_cursor of the previous page,
+// and ?limit=, the number of posts up to FEED_MAX_PAGE_SIZE.
+func (h *PostHandler) postPage(r *http.Request) (model.PostPage, error) {
+	limit, err := h.pageLimit(r)
+	page := model.PostPage{Limit: limit}
+	if err != nil {
+		return page, err
+	}
+	if value := r.URL.Query().Get("cursor"); value != "" {
+		cursor, err := decodePostCursor(value)
+		if err != nil {
+			return page, errors.New("Invalid cursor")
 		}
-		creatorProfile, err := h.userRepo.GetUserProfileByID(int(creatorId))
+		page.After = &cursor
+	}
+	return page, nil
+}
+
+// pageLimit reads ?limit=, the number of posts of a page up to FEED_MAX_PAGE_SIZE.
+func (h *PostHandler) pageLimit(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return h.feed.PageSize, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > h.feed.MaxPageSize {
+		return h.feed.PageSize, fmt.Errorf("limit must be between 1 and %d", h.feed.MaxPageSize)
+	}
+	return limit, nil
+}
+
+// writeRankedPage writes a page of the ranked main feed. The first page ranks the newest FEED_RANK_CANDIDATES
+// posts the user can see, its cursor keeps the time of the ranking and the position of the newest post,
+// so the next pages rank the same posts and skip the ones already shown. Posts created after the first page
+// show up when the feed is loaded again.
+func (h *PostHandler) writeRankedPage(w http.ResponseWriter, r *http.Request, userID int) {
+	limit, err := h.pageLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rankedAt := time.Now().UTC().Truncate(time.Second)
+	cursor := model.RankedCursor{RankedAt: rankedAt, Before: model.PostCursor{CreatedAt: rankedAt, Id: math.MaxInt32}}
+	if value := r.URL.Query().Get("cursor"); value != "" {
+		cursor, err = decodeRankedCursor(value)
 		if err != nil {
-			http.Error(w, "Failed to retrieve post owner profile: "+err.Error(), http.StatusInternalServerError)
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
 			return
 		}
-		postsResponse[i].Creator = creatorProfile.Username
-		postsResponse[i].CreatorAvatar = creatorProfile.AvatarURL
+	}
+
+	// The candidates are a page of the chronological feed, pinned just above its newest post for the next pages
+	posts, err := h.postRepo.GetAllPostsWithUserIDAccess(userID, model.PostPage{After: &cursor.Before, Limit: h.feed.RankCandidates - 1})
+	if err != nil {
+		http.Error(w, "Failed to retrieve posts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(posts) > 0 {
+		cursor.Before = model.PostCursor{CreatedAt: posts[0].CreatedAt, Id: posts[0].Id + 1}
+	}
+	candidates, err := h.feedRepo.GetRankCandidates(userID, posts)
+	if err != nil {
+		http.Error(w, "Failed to retrieve ranking signals: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ranked := h.ranker.Rank(candidates, cursor.RankedAt)
+
+	var nextCursor string
+	start := min(cursor.Offset, len(ranked))
+	end := min(start+limit, len(ranked))
+	if end < len(ranked) {
+		nextCursor = encodeRankedCursor(model.RankedCursor{RankedAt: cursor.RankedAt, Before: cursor.Before, Offset: end})
+	}
+	pagePosts := make([]model.Post, 0, end-start)
+	for _, candidate := range ranked[start:end] {
+		pagePosts = append(pagePosts, candidate.Post)
+	}
+	h.writePosts(w, pagePosts, nextCursor, userID)
+}
+
+// writePostsPage writes a page of a chronological feed with the cursor of the next page.
+// The repository returns one post more than the limit when there is a next page.
+func (h *PostHandler) writePostsPage(w http.ResponseWriter, posts []model.Post, page model.PostPage, userID int) {
+	var nextCursor string
+	if len(posts) > page.Limit {
+		posts = posts[:page.Limit]
+		last := posts[len(posts)-1]
+		nextCursor = encodePostCursor(model.PostCursor{CreatedAt: last.CreatedAt, Id: last.Id})
+	}
+	h.writePosts(w, posts, nextCursor, userID)
+}
+
+// writePosts appends the reactions, the reactions of the user and the creators to the posts of a page and writes them.
+// Reactions and creators are loaded for the whole page, so a page costs the same number of queries at any size.
+func (h *PostHandler) writePosts(w http.ResponseWriter, posts []model.Post, nextCursor string, userID int) {
+	// Append the reactions to the posts
+	postsResponse, err := h.reactionHandler.AppendReactionsToPostsResponse(posts, userID)
+	if err != nil {
+		http.Error(w, "Failed to append reactions to posts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.mentionHandler.AppendMentionsToPostsResponse(postsResponse); err != nil {
+		http.Error(w, "Failed to append mentions to posts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Load the profiles of all creators at once
+	creatorIDs := make([]int, len(postsResponse))
+	for i, post := range postsResponse {
+		creatorIDs[i] = post.UserID
+	}
+	creators, err := h.userRepo.GetUserProfilesByIDs(creatorIDs)
+	if err != nil {
+		http.Error(w, "Failed to retrieve post owner profiles: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i, post := range postsResponse {
+		postsResponse[i].Creator = creators[post.UserID].Username
+		postsResponse[i].CreatorAvatar = creators[post.UserID].AvatarURL
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(postsResponse)
+	json.NewEncoder(w).Encode(model.PostsPage{Posts: postsResponse, NextCursor: nextCursor})
+}
+
+// encodePostCursor turns the position of a post into an opaque cursor, the creation time in seconds and the ID.
+func encodePostCursor(cursor model.PostCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(cursor.CreatedAt.Unix(), 10) + ":" + strconv.Itoa(cursor.Id)))
+}
+
+func decodePostCursor(value string) (model.PostCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return model.PostCursor{}, err
+	}
+	createdAt, id, found := strings.Cut(string(raw), ":")
+	if !found {
+		return model.PostCursor{}, errors.New("malformed cursor")
+	}
+	seconds, err := strconv.ParseInt(createdAt, 10, 64)
+	if err != nil {
+		return model.PostCursor{}, err
+	}
+	postID, err := strconv.Atoi(id)
+	if err != nil {
+		return model.PostCursor{}, err
+	}
+	return model.PostCursor{CreatedAt: time.Unix(seconds, 0), Id: postID}, nil
+}
+
+// rankedCursorPrefix tells ranked cursors from the cursors of the chronological feeds.
+const rankedCursorPrefix = "ranked:"
+
+// encodeRankedCursor turns a position in the ranked feed into an opaque cursor: the time of the ranking in seconds,
+// the creation time in seconds and ID the ranked posts are before and the offset.
+func encodeRankedCursor(cursor model.RankedCursor) string {
+	fields := []string{
+		strconv.FormatInt(cursor.RankedAt.Unix(), 10),
+		strconv.FormatInt(cursor.Before.CreatedAt.Unix(), 10),
+		strconv.Itoa(cursor.Before.Id),
+		strconv.Itoa(cursor.Offset),
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(rankedCursorPrefix + strings.Join(fields, ":")))
 }
 
+func decodeRankedCursor(value string) (model.RankedCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return model.RankedCursor{}, err
+	}
+	rest, found := strings.CutPrefix(string(raw), rankedCursorPrefix)
+	if !found {
+		return model.RankedCursor{}, errors.New("not a ranked cursor")
+	}
+	fields := strings.Split(rest, ":")
+	if len(fields) != 4 {
+		return model.RankedCursor{}, errors.New("malformed cursor")
+	}
+	numbers := make([]int64, len(fields))
+	for i, field := range fields {
+		numbers[i], err = strconv.ParseInt(field, 10, 64)
+		if err != nil || numbers[i] < 0 {
+			return model.RankedCursor{}, errors.New("malformed cursor")
+		}
+	}
+	return model.RankedCursor{
+		RankedAt: time.Unix(numbers[0], 0).UTC(),
+		Before:   model.PostCursor{CreatedAt: time.Unix(numbers[1], 0), Id: int(numbers[2])},
+		Offset:   int(numbers[3]),
+	}, nil
+}
@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/config"
+	"backend/pkg/model"
+	"backend/pkg/ranking"
+	"backend/pkg/repository"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Post and comment lists load reactions, mentions and authors for the whole list at once,
+// so the number of queries of a page doesn't depend on how many posts or comments it has.
+// The benchmarks report the queries per page, run them with
+//
+//	go test -tags sqlite_fts5 -run '^$' -bench Queries ./pkg/handler/
+
+// queryCount counts the statements run through the "sqlite3_counting" driver.
+var queryCount atomic.Int64
+
+func init() {
+	sql.Register("sqlite3_counting", countingDriver{})
+}
+
+// countingDriver is go-sqlite3 counting every query and exec.
+type countingDriver struct{}
+
+func (countingDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := (&sqlite3.SQLiteDriver{}).Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &countingConn{SQLiteConn: conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+type countingConn struct {
+	*sqlite3.SQLiteConn
+}
+
+func (c *countingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryCount.Add(1)
+	return c.SQLiteConn.QueryContext(ctx, query, args)
+}
+
+func (c *countingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	queryCount.Add(1)
+	return c.SQLiteConn.ExecContext(ctx, query, args)
+}
+
+// openQueryDB returns an in-memory database with all migrations applied, counting its queries.
+func openQueryDB(tb testing.TB) *sql.DB {
+	tb.Helper()
+	db, err := sql.Open("sqlite3_counting", "file::memory:?_foreign_keys=off")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	// Every connection to :memory: is a database of its own
+	db.SetMaxOpenConns(1)
+	tb.Cleanup(func() { db.Close() })
+
+	var fts5 bool
+	if err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err != nil || !fts5 {
+		tb.Skip("SQLite is built without FTS5, run with -tags sqlite_fts5")
+	}
+	migrations, err := filepath.Glob("../db/migrations/sqlite/*.up.sql")
+	if err != nil || len(migrations) == 0 {
+		tb.Fatal("no migrations found: ", err)
+	}
+	for _, migration := range migrations {
+		statements, err := os.ReadFile(migration)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		if _, err := db.Exec(string(statements)); err != nil {
+			tb.Fatalf("%s: %v", filepath.Base(migration), err)
+		}
+	}
+	return db
+}
+
+// seedQueryDB adds a public post of its own author for each of the count items, every one with reactions,
+// a mention and a comment. With a post ID, the comments go to this post instead, with reactions, mentions and replies.
+// The reader is user 1.
+func seedQueryDB(tb testing.TB, db *sql.DB, count, postID int) {
+	tb.Helper()
+	exec := func(query string, args ...interface{}) int {
+		result, err := db.Exec(query, args...)
+		if err != nil {
+			tb.Fatalf("%s: %v", query, err)
+		}
+		id, _ := result.LastInsertId()
+		return int(id)
+	}
+	react := func(itemType string, itemID, userID int, reaction string) {
+		exec(`INSERT INTO reactions (user_id, item_type, item_id, reaction) VALUES (?, ?, ?, ?)`, userID, itemType, itemID, reaction)
+		exec(`INSERT INTO reaction_counts (item_type, item_id, reaction, count) VALUES (?, ?, ?, 1)
+			ON CONFLICT (item_type, item_id, reaction) DO UPDATE SET count = count + 1`, itemType, itemID, reaction)
+	}
+
+	reader := exec(`INSERT INTO users (username, email, password, first_name, last_name, date_of_birth, avatar_url, about_me) VALUES ('reader', 'reader@example.test', 'x', 'R', 'R', '2000-01-01', '', '')`)
+	if postID != 0 {
+		exec(`INSERT INTO posts (id, user_id, title, group_id, content, image_url, privacy_setting) VALUES (?, ?, 'Thread', 0, 'x', '', 'public')`, postID, reader)
+	}
+	var parentID interface{}
+	for i := 0; i < count; i++ {
+		author := exec(`INSERT INTO users (username, email, password, first_name, last_name, date_of_birth, avatar_url, about_me) VALUES (?, ?, 'x', 'F', 'L', '2000-01-01', '', '')`,
+			fmt.Sprintf("user%d", i), fmt.Sprintf("user%d@example.test", i))
+		post := postID
+		if postID == 0 {
+			post = exec(`INSERT INTO posts (user_id, title, group_id, content, image_url, privacy_setting) VALUES (?, ?, 0, 'hello @reader', '', 'public')`, author, fmt.Sprintf("Post %d", i))
+			exec(`INSERT INTO mentions (item_type, item_id, user_id, username) VALUES ('post', ?, ?, 'reader')`, post, reader)
+			react(model.ReactionItemPost, post, reader, "like")
+			react(model.ReactionItemPost, post, author, "love")
+			parentID = nil
+		}
+		depth := 0
+		if parentID != nil {
+			depth = 1
+		}
+		comment := exec(`INSERT INTO comments (post_id, user_id, content, parent_id, depth) VALUES (?, ?, 'hi @reader', ?, ?)`, post, author, parentID, depth)
+		exec(`INSERT INTO mentions (item_type, item_id, user_id, username) VALUES ('comment', ?, ?, 'reader')`, comment, reader)
+		react(model.ReactionItemComment, comment, reader, "like")
+		// Every other comment on the thread answers the one before
+		if postID != 0 && parentID == nil {
+			parentID = comment
+		} else {
+			parentID = nil
+		}
+	}
+}
+
+// newQueryHandlers builds the post and comment handlers like the router does.
+func newQueryHandlers(db *sql.DB) (*PostHandler, *CommentHandler) {
+	cfg := config.Load()
+	postRepo := repository.NewPostRepository(db)
+	commentRepo := repository.NewCommentRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	notificationHandler := NewNotificationHandler(repository.NewNotificationRepository(db), sessionRepo, repository.NewGroupMemberRepository(db),
+		repository.NewGroupRepository(db), userRepo, repository.NewInvitationRepository(db), repository.NewEventRepository(db))
+	reactionHandler := NewReactionHandler(repository.NewReactionRepository(db), postRepo, commentRepo, cfg.Reactions)
+	mentionHandler := NewMentionHandler(repository.NewMentionRepository(db), postRepo, userRepo, notificationHandler)
+	postHandler := NewPostHandler(postRepo, sessionRepo, repository.NewFriendsRepository(db), repository.NewGroupMemberRepository(db), userRepo,
+		reactionHandler, mentionHandler, repository.NewAudienceListRepository(db), repository.NewFeedRepository(db), ranking.NewWeightedRanker(cfg.Feed.RankWeights), cfg.Feed)
+	commentHandler := NewCommentHandler(commentRepo, sessionRepo, notificationHandler, postRepo, userRepo, reactionHandler, mentionHandler, cfg.Comments)
+	return postHandler, commentHandler
+}
+
+// pageQueries returns the queries of one request and checks that it succeeded with the expected number of items.
+func pageQueries(tb testing.TB, handler http.HandlerFunc, r *http.Request, items int) int64 {
+	tb.Helper()
+	w := httptest.NewRecorder()
+	before := queryCount.Load()
+	handler(w, r)
+	queries := queryCount.Load() - before
+	if w.Code != http.StatusOK {
+		tb.Fatalf("%s answered %d: %s", r.URL, w.Code, w.Body.String())
+	}
+
+	// Posts come wrapped in a page, comments as a plain list
+	var page struct {
+		Posts []json.RawMessage `json:"posts"`
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err == nil {
+		list = page.Posts
+	} else if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		tb.Fatalf("%s answered %s", r.URL, w.Body.String())
+	}
+	if len(list) != items {
+		tb.Fatalf("%s returned %d items, want %d", r.URL, len(list), items)
+	}
+	return queries
+}
+
+// postPageRequest asks the main feed for a page of the size, as user 1.
+func postPageRequest(size int) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/posts?limit=%d", size), nil)
+	return r.WithContext(auth.WithIdentity(r.Context(), model.Identity{UserID: 1}))
+}
+
+// commentsRequest asks for all comments of the post, as user 1.
+func commentsRequest(postID int) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/post/%d/comments", postID), nil)
+	r = mux.SetURLVars(r, map[string]string{"id": fmt.Sprint(postID)})
+	return r.WithContext(auth.WithIdentity(r.Context(), model.Identity{UserID: 1}))
+}
+
+var querySizes = []int{10, 50, 100}
+
+func BenchmarkPostPageQueries(b *testing.B) {
+	for _, size := range querySizes {
+		b.Run(fmt.Sprintf("posts=%d", size), func(b *testing.B) {
+			db := openQueryDB(b)
+			seedQueryDB(b, db, size, 0)
+			postHandler, _ := newQueryHandlers(db)
+			r := postPageRequest(size)
+
+			var queries int64
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				queries += pageQueries(b, postHandler.GetAllPostsHandler, r, size)
+			}
+			b.ReportMetric(float64(queries)/float64(b.N), "queries/op")
+		})
+	}
+}
+
+func BenchmarkCommentPageQueries(b *testing.B) {
+	for _, size := range querySizes {
+		b.Run(fmt.Sprintf("comments=%d", size), func(b *testing.B) {
+			db := openQueryDB(b)
+			seedQueryDB(b, db, size, 1)
+			_, commentHandler := newQueryHandlers(db)
+			r := commentsRequest(1)
+
+			var queries int64
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				queries += pageQueries(b, commentHandler.GetCommentsByPostID, r, size)
+			}
+			b.ReportMetric(float64(queries)/float64(b.N), "queries/op")
+		})
+	}
+}
+
+// TestListQueriesDontGrow checks what the benchmarks show: larger pages run the same queries.
+func TestListQueriesDontGrow(t *testing.T) {
+	postQueries := make(map[int]int64)
+	commentQueries := make(map[int]int64)
+	for _, size := range querySizes {
+		db := openQueryDB(t)
+		seedQueryDB(t, db, size, 0)
+		postHandler, _ := newQueryHandlers(db)
+		postQueries[size] = pageQueries(t, postHandler.GetAllPostsHandler, postPageRequest(size), size)
+
+		db = openQueryDB(t)
+		seedQueryDB(t, db, size, 1)
+		_, commentHandler := newQueryHandlers(db)
+		commentQueries[size] = pageQueries(t, commentHandler.GetCommentsByPostID, commentsRequest(1), size)
+	}
+	for _, size := range querySizes[1:] {
+		if postQueries[size] != postQueries[querySizes[0]] {
+			t.Errorf("a page of %d posts runs %d queries, a page of %d posts %d", size, postQueries[size], querySizes[0], postQueries[querySizes[0]])
+		}
+		if commentQueries[size] != commentQueries[querySizes[0]] {
+			t.Errorf("%d comments run %d queries, %d comments %d", size, commentQueries[size], querySizes[0], commentQueries[querySizes[0]])
+		}
+	}
+	t.Logf("queries per page: posts %v, comments %v", postQueries, commentQueries)
+}
@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/config"
+	"backend/pkg/model"
+	"backend/pkg/repository"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ReactionHandler lets users react to posts and comments with one of the configured reactions.
+// Chat messages get their reactions over the chat websocket, see ws.ChatHandler.ReactToMessage.
+type ReactionHandler struct {
+	reactionRepo *repository.ReactionRepository
+	postRepo     *repository.PostRepository
+	commentRepo  *repository.CommentRepository
+	reactions    config.Reactions
+}
+
+func NewReactionHandler(reactionRepo *repository.ReactionRepository, postRepo *repository.PostRepository, commentRepo *repository.CommentRepository, reactions config.Reactions) *ReactionHandler {
+	return &ReactionHandler{reactionRepo: reactionRepo, postRepo: postRepo, commentRepo: commentRepo, reactions: reactions}
+}
+
+// GetReactionTypesHandler lists the reactions users can pick from, in the order to show them.
+func (h *ReactionHandler) GetReactionTypesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.reactions.Types)
+}
+
+// ReactHandler gives a post or comment the reaction of the user. Sending the reaction the user already gave removes it.
+// /vote is the same endpoint for older clients, which send the reaction as action.
+func (h *ReactionHandler) ReactHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var data model.ReactionData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Failed to parse request data")
+		return
+	}
+	if data.Reaction == "" {
+		data.Reaction = data.Action
+	}
+	switch {
+	case data.Item == model.ReactionItemMessage:
+		auth.WriteJSONError(w, http.StatusBadRequest, "React to chat messages over the chat websocket")
+		return
+	case data.Item != model.ReactionItemPost && data.Item != model.ReactionItemComment:
+		auth.WriteJSONError(w, http.StatusBadRequest, "Invalid item type")
+		return
+	case !h.reactions.Allowed(data.Reaction):
+		auth.WriteJSONError(w, http.StatusBadRequest, "Invalid reaction")
+		return
+	}
+
+	notFound := fmt.Sprintf("No %s found with the specified id", data.Item)
+	canSee, err := h.canSeeItem(data.Item, data.ItemID, userID)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Failed to check access to the "+data.Item+": "+err.Error())
+		return
+	}
+	if !canSee {
+		auth.WriteJSONError(w, http.StatusNotFound, notFound)
+		return
+	}
+
+	myReaction, err := h.reactionRepo.React(data.Item, data.ItemID, userID, data.Reaction)
+	if err == sql.ErrNoRows {
+		auth.WriteJSONError(w, http.StatusNotFound, notFound)
+		return
+	} else if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to react to %s: %s", data.Item, err.Error()))
+		return
+	}
+	counts, err := h.reactionRepo.GetReactionCounts(data.Item, []int{data.ItemID})
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Failed to fetch updated reactions: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model.ReactionsResponse{
+		Item:       data.Item,
+		ItemID:     data.ItemID,
+		Reactions:  h.reactions.Filter(counts[data.ItemID]),
+		MyReaction: myReaction,
+	})
+}
+
+// canSeeItem checks that the user can see the post, or the post of the comment.
+func (h *ReactionHandler) canSeeItem(item string, itemID, userID int) (bool, error) {
+	postID := itemID
+	if item == model.ReactionItemComment {
+		comment, err := h.commentRepo.GetCommentByID(itemID)
+		if err == sql.ErrNoRows {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		postID = comment.PostID
+	}
+	return h.postRepo.CanUserSeePost(postID, userID)
+}
+
+// AppendReactionsToPostsResponse adds the reaction counters of the posts and the reactions of the user,
+// loading both for all posts with one query each.
+func (h *ReactionHandler) AppendReactionsToPostsResponse(posts []model.Post, userID int) ([]model.PostsResponse, error) {
+	postIDs := make([]int, len(posts))
+	for i, post := range posts {
+		postIDs[i] = post.Id
+	}
+	counts, myReactions, err := h.itemReactions(model.ReactionItemPost, postIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	postsResponse := make([]model.PostsResponse, len(posts))
+	for i, post := range posts {
+		postsResponse[i] = model.PostsResponse{
+			Id:             post.Id,
+			UserID:         post.UserID,
+			GroupID:        post.GroupID,
+			Title:          post.Title,
+			Content:        post.Content,
+			ImageURL:       post.ImageURL,
+			PrivacySetting: post.PrivacySetting,
+			CreatedAt:      post.CreatedAt,
+			Reactions:      h.reactions.Filter(counts[post.Id]),
+			MyReaction:     myReactions[post.Id],
+		}
+	}
+	return postsResponse, nil
+}
+
+// AppendReactionsToCommentsResponse adds the reaction counters of the comments and the reactions of the user,
+// loading both for all comments with one query each.
+func (h *ReactionHandler) AppendReactionsToCommentsResponse(comments []model.Comment, userID int) ([]model.CommentsResponse, error) {
+	commentIDs := make([]int, len(comments))
+	for i, comment := range comments {
+		commentIDs[i] = comment.Id
+	}
+	counts, myReactions, err := h.itemReactions(model.ReactionItemComment, commentIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	commentsResponse := make([]model.CommentsResponse, len(comments))
+	for i, comment := range comments {
+		commentsResponse[i] = model.CommentsResponse{
+			Id:         comment.Id,
+			PostID:     comment.PostID,
+			UserID:     comment.UserID,
+			Content:    comment.Content,
+			Image:      comment.Image.String,
+			CreatedAt:  comment.CreatedAt,
+			Reactions:  h.reactions.Filter(counts[comment.Id]),
+			MyReaction: myReactions[comment.Id],
+			ParentID:   comment.ParentID,
+			Depth:      comment.Depth,
+			Edited:     comment.Edited,
+		}
+		if comment.Edited {
+			editedAt := comment.UpdatedAt
+			commentsResponse[i].EditedAt = &editedAt
+		}
+	}
+	return commentsResponse, nil
+}
+
+// itemReactions loads the reaction counters of the items and the reactions of the user to them.
+func (h *ReactionHandler) itemReactions(item string, itemIDs []int, userID int) (map[int]map[string]int, map[int]string, error) {
+	counts, err := h.reactionRepo.GetReactionCounts(item, itemIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	myReactions, err := h.reactionRepo.GetUserReactions(item, itemIDs, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return counts, myReactions, nil
+}
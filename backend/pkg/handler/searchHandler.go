@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/model"
+	"backend/pkg/repository"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Limits of search requests
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+	maxSearchTerms     = 10
+)
+
+var searchTypes = []string{model.SearchPost, model.SearchComment, model.SearchUser, model.SearchGroup, model.SearchEvent}
+
+// SearchHandler searches posts, comments, users, groups and events with the full-text indexes of SQLite.
+type SearchHandler struct {
+	searchRepo *repository.SearchRepository
+}
+
+func NewSearchHandler(searchRepo *repository.SearchRepository) *SearchHandler {
+	return &SearchHandler{searchRepo: searchRepo}
+}
+
+// SearchHandler answers GET /search?q=. ?type= limits the results to some types (post, comment, user, group, event,
+// comma separated), ?limit= and ?offset= select the page. Results the user can't see are left out.
+func (h *SearchHandler) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	params := r.URL.Query()
+	query := model.SearchQuery{Match: searchMatch(params.Get("q")), Limit: defaultSearchLimit}
+	if query.Match == "" {
+		auth.WriteJSONError(w, http.StatusBadRequest, "q must contain a word to search for")
+		return
+	}
+	if value := params.Get("type"); value != "" {
+		for _, resultType := range strings.Split(value, ",") {
+			resultType = strings.TrimSpace(resultType)
+			if !containsScope(searchTypes, resultType) {
+				auth.WriteJSONError(w, http.StatusBadRequest, "type must be one of "+strings.Join(searchTypes, ", "))
+				return
+			}
+			query.Types = append(query.Types, resultType)
+		}
+	}
+	if value := params.Get("limit"); value != "" {
+		query.Limit, err = strconv.Atoi(value)
+		if err != nil || query.Limit < 1 || query.Limit > maxSearchLimit {
+			auth.WriteJSONError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxSearchLimit))
+			return
+		}
+	}
+	if value := params.Get("offset"); value != "" {
+		query.Offset, err = strconv.Atoi(value)
+		if err != nil || query.Offset < 0 {
+			auth.WriteJSONError(w, http.StatusBadRequest, "offset must be a positive number")
+			return
+		}
+	}
+
+	results, err := h.searchRepo.Search(userID, query)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Failed to search: "+err.Error())
+		return
+	}
+	response := model.SearchResults{Results: results}
+	if len(results) > query.Limit {
+		response.Results = results[:query.Limit]
+		response.NextOffset = query.Offset + query.Limit
+	}
+	if response.Results == nil {
+		response.Results = []model.SearchResult{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// searchMatch turns the words of a search into an FTS5 query matching all of them, the last one as a prefix
+// so results show up while typing. Every word is quoted, users can't use the FTS5 query syntax.
+func searchMatch(q string) string {
+	words := strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) > maxSearchTerms {
+		words = words[:maxSearchTerms]
+	}
+	for i, word := range words {
+		words[i] = `"` + word + `"`
+	}
+	if len(words) > 0 {
+		words[len(words)-1] += "*"
+	}
+	return strings.Join(words, " ")
+}
@@ -1,20 +1,28 @@
 package handler
 
 import (
+	"backend/pkg/auth"
 	"backend/pkg/model"
+	"backend/pkg/repository"
 	"backend/util"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/gorilla/mux"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // LoginHandler handles the login request.
 // It decodes the login data from the request body and validates the user's credentials.
-// If the credentials are valid, it generates a session token, stores it in the database, and sets a cookie with the session token.
+// If the credentials are valid, it starts a new session and sets a cookie with the session token.
+// With remember_me set the session gets the long lived timeouts from the session configuration.
+// Users with two-factor authentication get a pending login token instead of a session.
 // Finally, it sends a success response indicating that the login was successful.
 func (h *UserHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var logData model.LoginData
@@ -25,34 +33,81 @@ func (h *UserHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Error parsing login JSON data: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	// Addresses with too many failed logins have to wait before trying again
+	ip := util.GetClientIP(r)
+	wait, err := h.limiter.Check(0, ip)
+	if err != nil {
+		http.Error(w, "Error checking login attempts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		writeLockedOut(w, wait)
+		return
+	}
+
 	user, err := h.userRepo.GetUserByEmailOrNickname(logData.Username)
 	if err != nil {
+		if _, err := h.limiter.Failure(0, ip); err != nil {
+			log.Println("Error recording failed login: ", err)
+		}
 		w.WriteHeader(http.StatusUnauthorized)
 		response := map[string]string{"error": "Incorrect login credentials."}
 		json.NewEncoder(w).Encode(response)
 		return
 	}
 
+	wait, err = h.limiter.Check(user.Id, "")
+	if err != nil {
+		http.Error(w, "Error checking login attempts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		writeLockedOut(w, wait)
+		return
+	}
+
 	// Compare hashed password with provided password
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(logData.Password))
 	if err != nil { // Wrong password
+		recordFailedLogin(h.limiter, h.notifications, user.Id, ip)
 		w.WriteHeader(http.StatusUnauthorized)
 		response := map[string]string{"error": "Incorrect login credentials."}
 		json.NewEncoder(w).Encode(response)
 		return
 	}
 
-	// Generate a session token and store it in database
-	sessionToken := util.GenerateSessionToken()
-	h.sessionRepo.StoreSessionInDB(sessionToken, user.Id)
+	// With two-factor authentication the login waits for the code, see TwoFactorHandler.LoginTwoFactorHandler.
+	// The failed logins of the account are only forgotten once the code is right too.
+	twoFactorEnabled, err := h.twoFactor.IsEnabled(user.Id)
+	if err != nil {
+		http.Error(w, "Error checking two-factor authentication: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if twoFactorEnabled {
+		mfaToken, err := h.twoFactor.StartChallenge(user.Id, logData.RememberMe)
+		if err != nil {
+			http.Error(w, "Error starting two-factor login: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":             "Two-factor authentication required",
+			"two_factor_required": true,
+			"mfa_token":           mfaToken,
+		})
+		return
+	}
+
+	if err := h.limiter.Success(user.Id); err != nil {
+		log.Println("Error resetting failed logins: ", err)
+	}
 
-	// Set a cookie with the session
-	http.SetCookie(w, &http.Cookie{
-		Name:   "session_token",
-		Value:  sessionToken,
-		MaxAge: 60 * 30, // 30 minutes
-		Path:   "/",     // Make cookie available for all paths
-	})
+	// Create the session and set the session cookie, remember me sessions live longer
+	err = h.sessions.Start(w, r, user.Id, logData.RememberMe)
+	if err != nil {
+		http.Error(w, "Error creating session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	// Send a success response
 	w.Header().Set("Content-Type", "application/json")
@@ -61,22 +116,67 @@ func (h *UserHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// LogoutHandler handles the logout functionality by deleting the session-token cookie and sending a success response.
-// If the session-token cookie is not found or there is an error, it returns a bad request error.
-func LogoutHandler(w http.ResponseWriter, r *http.Request) {
-	_, err := r.Cookie("session_token")
+// recordFailedLogin counts a wrong password or two-factor code. When the account gets locked because of it,
+// the owner gets a security notification.
+func recordFailedLogin(limiter *auth.LoginLimiter, notifications *NotificationHandler, userID int, ip string) {
+	lockedUntil, err := limiter.Failure(userID, ip)
+	if err != nil {
+		log.Println("Error recording failed login: ", err)
+		return
+	}
+	if lockedUntil.IsZero() {
+		return
+	}
+	message := fmt.Sprintf("Your account was locked until %s after too many failed login attempts, the last one from %s. If this wasn't you, consider changing your password.",
+		lockedUntil.Format("2006-01-02 15:04"), ip)
+	if err := notifications.CreateSecurityNotification(userID, message); err != nil {
+		log.Println("Error creating lockout notification: ", err)
+	}
+}
+
+// writeLockedOut answers a login attempt of a locked account or IP address with 429 and a Retry-After header.
+func writeLockedOut(w http.ResponseWriter, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":       "Too many failed login attempts, please try again later.",
+		"retry_after": seconds,
+	})
+}
+
+// LogoutHandler ends the session on the server side and deletes the session-token cookie.
+// With the query parameter all=true every session of the user is ended, logging them out on every device.
+// The cookie is cleared even if the session was already gone, so the client always ends up logged out.
+func (h *UserHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie("session_token")
 	if err != nil && err != http.ErrNoCookie {
 		http.Error(w, "Bad request: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	if cookie != nil && cookie.Value != "" {
+		if r.URL.Query().Get("all") == "true" {
+			userID, err := h.sessionRepo.GetUserIDFromSessionToken(cookie.Value)
+			if err != nil && err != sql.ErrNoRows {
+				http.Error(w, "Error getting session: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if err == nil {
+				if _, err := h.sessionRepo.DeleteAllSessionsByUserID(userID); err != nil {
+					http.Error(w, "Error ending sessions: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+		} else if err := h.sessionRepo.DeleteSessionByToken(cookie.Value); err != nil {
+			http.Error(w, "Error ending session: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// Delete the session-token cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:    "session_token",
-		Value:   "",
-		MaxAge:  -1, // Setting MaxAge to -1 immediately expires the cookie
-		Expires: time.Unix(0, 0),
-	})
+	auth.ClearCookie(w)
 
 	// Send a success reponse
 	w.Header().Set("Content-Type", "application/json")
@@ -132,17 +232,16 @@ func (h *UserHandler) CheckAuth(w http.ResponseWriter, r *http.Request) {
 	w.Write(jsonResponse)
 }
 
+// UpdateAuth renews the session right away. Authenticated requests already renew the session on their own,
+// this endpoint stays for clients that want to keep an idle session alive.
 func (h *UserHandler) UpdateAuth(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("session_token")
+	session, err := h.sessionRepo.GetSessionBySessionToken(util.GetSessionToken(r))
 	if err != nil {
-		if err == http.ErrNoCookie {
-			return
-		}
+		http.Error(w, "Error getting session: "+err.Error(), http.StatusUnauthorized)
+		return
 	}
 
-	sessionToken := cookie.Value
-
-	err = h.sessionRepo.UpdateSessionExpiration(sessionToken)
+	err = h.sessions.Renew(w, session, true)
 	if err != nil {
 		http.Error(w, "Error updating session expiration: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -150,3 +249,160 @@ func (h *UserHandler) UpdateAuth(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// GetActiveSessionsHandler lists all active sessions of the authenticated user.
+// The session used for the request is flagged as current so the client can tell it apart from the other devices.
+func (h *UserHandler) GetActiveSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	identity, ok := auth.IdentityFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := h.sessionRepo.GetActiveSessionsByUserID(identity.UserID)
+	if err != nil {
+		http.Error(w, "Error getting sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	activeSessions := make([]model.ActiveSession, 0, len(sessions))
+	for _, session := range sessions {
+		activeSessions = append(activeSessions, model.ActiveSession{
+			Id:         session.Id,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.Id == identity.SessionID,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(activeSessions)
+}
+
+// RevokeSessionHandler revokes one of the authenticated user's sessions by its ID.
+func (h *UserHandler) RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		http.Error(w, "User not authenticated: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	sessionID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	err = h.sessionRepo.DeleteSessionByID(sessionID, userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Error revoking session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Session revoked",
+	})
+}
+
+// RevokeOtherSessionsHandler revokes every session of the authenticated user except the one making the request.
+func (h *UserHandler) RevokeOtherSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	identity, ok := auth.IdentityFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	revoked, err := h.sessionRepo.DeleteOtherSessions(identity.UserID, identity.SessionID)
+	if err != nil {
+		http.Error(w, "Error revoking sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Other sessions revoked",
+		"revoked": revoked,
+	})
+}
+
+// RevokeUserSessionsHandler lets an administrator end every session of a user, logging them out on all devices.
+func (h *UserHandler) RevokeUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := h.sessionRepo.DeleteAllSessionsByUserID(userID)
+	if err != nil {
+		http.Error(w, "Error revoking sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "User sessions revoked",
+		"revoked": revoked,
+	})
+}
+
+// GetLockoutsHandler lets an administrator see which accounts and IP addresses are locked because of failed logins.
+func (h *UserHandler) GetLockoutsHandler(w http.ResponseWriter, r *http.Request) {
+	lockouts, err := h.limiter.ActiveLockouts()
+	if err != nil {
+		http.Error(w, "Error getting lockouts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lockouts)
+}
+
+// UnlockUserHandler lets an administrator lift the lockout of an account and reset its failed login counter.
+func (h *UserHandler) UnlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	unlocked, err := h.limiter.Unlock(userID)
+	if err != nil {
+		http.Error(w, "Error unlocking user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !unlocked {
+		http.Error(w, "User is not locked", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "User unlocked",
+	})
+}
+
+// RunSessionJanitor periodically removes expired sessions from the database.
+// It is meant to be run in its own goroutine and never returns.
+func RunSessionJanitor(sessionRepo *repository.SessionRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		removed, err := sessionRepo.DeleteExpiredSessions()
+		if err != nil {
+			log.Println("Error removing expired sessions: ", err)
+			continue
+		}
+		if removed > 0 {
+			log.Printf("Removed %d expired sessions", removed)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/config"
+	"backend/pkg/model"
+	"backend/pkg/repository"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxTrendingTags is the largest ?limit= of the trending tags.
+const maxTrendingTags = 50
+
+// TagHandler lists the trending hashtags. The posts of a tag are a feed of the PostHandler.
+type TagHandler struct {
+	tagRepo *repository.TagRepository
+	tags    config.Tags
+}
+
+func NewTagHandler(tagRepo *repository.TagRepository, tags config.Tags) *TagHandler {
+	return &TagHandler{tagRepo: tagRepo, tags: tags}
+}
+
+// GetTrendingTagsHandler returns the hashtags used most in public posts and their comments within a sliding window,
+// TRENDING_TAGS_WINDOW or ?window= (like 6h) up to TRENDING_TAGS_MAX_WINDOW. ?limit= lists fewer or more tags.
+func (h *TagHandler) GetTrendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	window := h.tags.TrendingWindow
+	if value := params.Get("window"); value != "" {
+		var err error
+		window, err = time.ParseDuration(value)
+		if err != nil || window <= 0 || window > h.tags.MaxTrendingWindow {
+			auth.WriteJSONError(w, http.StatusBadRequest, "window must be a duration up to "+h.tags.MaxTrendingWindow.String())
+			return
+		}
+	}
+	limit := h.tags.TrendingLimit
+	if value := params.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxTrendingTags {
+			auth.WriteJSONError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxTrendingTags))
+			return
+		}
+	}
+
+	since := time.Now().Add(-window).UTC().Truncate(time.Second)
+	tags, err := h.tagRepo.GetTrendingTags(since, limit)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Failed to retrieve trending tags: "+err.Error())
+		return
+	}
+	if tags == nil {
+		tags = []model.TrendingTag{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model.TrendingTags{Since: since, Tags: tags})
+}
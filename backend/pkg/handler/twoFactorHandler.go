@@ -0,0 +1,380 @@
+package handler
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/config"
+	"backend/pkg/model"
+	"backend/pkg/repository"
+	"backend/util"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// TwoFactorHandler handles TOTP enrollment and the second step of the login for users with two-factor authentication.
+// Wrong codes count as failed logins of the account, like wrong passwords.
+type TwoFactorHandler struct {
+	twoFactorRepo *repository.TwoFactorRepository
+	userRepo      *repository.UserRepository
+	sessions      *auth.SessionManager
+	limiter       *auth.LoginLimiter
+	notifications *NotificationHandler
+	config        config.TwoFactor
+}
+
+func NewTwoFactorHandler(tfRepo *repository.TwoFactorRepository, uRepo *repository.UserRepository, sessions *auth.SessionManager, limiter *auth.LoginLimiter, notifications *NotificationHandler, config config.TwoFactor) *TwoFactorHandler {
+	return &TwoFactorHandler{twoFactorRepo: tfRepo, userRepo: uRepo, sessions: sessions, limiter: limiter, notifications: notifications, config: config}
+}
+
+// IsEnabled reports whether the user needs a second factor to log in.
+func (h *TwoFactorHandler) IsEnabled(userID int) (bool, error) {
+	return h.twoFactorRepo.IsTOTPEnabled(userID)
+}
+
+// StartChallenge puts a login that passed the password check on hold until the second factor is given.
+// The returned token identifies the pending login and is sent back with the code to /api/users/login/2fa.
+func (h *TwoFactorHandler) StartChallenge(userID int, rememberMe bool) (string, error) {
+	token, hash, err := auth.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	err = h.twoFactorRepo.CreateChallenge(userID, hash, rememberMe, time.Now().Add(h.config.ChallengeTTL))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// LoginTwoFactorHandler finishes a pending login with a TOTP code or a recovery code and starts the session.
+// After too many wrong codes the pending login is dropped and the user has to enter the password again.
+func (h *TwoFactorHandler) LoginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var data model.TwoFactorLoginData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Error parsing JSON data: "+err.Error())
+		return
+	}
+
+	challenge, err := h.twoFactorRepo.GetChallenge(auth.HashToken(data.MFAToken))
+	if err == sql.ErrNoRows || (err == nil && time.Now().After(challenge.ExpiresAt)) {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "Login expired, please log in again")
+		return
+	} else if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting login: "+err.Error())
+		return
+	}
+
+	// Locked accounts and addresses can't enter codes either, every new login only brings a few attempts
+	ip := util.GetClientIP(r)
+	wait, err := h.limiter.Check(challenge.UserID, ip)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error checking login attempts: "+err.Error())
+		return
+	}
+	if wait > 0 {
+		writeLockedOut(w, wait)
+		return
+	}
+
+	// Count the attempt before checking the code, so parallel requests can't try more codes than allowed
+	attempts, err := h.twoFactorRepo.IncrementChallengeAttempts(challenge.Id, h.config.MaxAttempts)
+	if err == sql.ErrNoRows {
+		h.dropChallenge(w, challenge.Id)
+		return
+	} else if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error counting attempts: "+err.Error())
+		return
+	}
+
+	ok, err := h.checkSecondFactor(challenge.UserID, data.Code, data.RecoveryCode)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error checking code: "+err.Error())
+		return
+	}
+	if !ok {
+		recordFailedLogin(h.limiter, h.notifications, challenge.UserID, ip)
+		if attempts >= h.config.MaxAttempts {
+			h.dropChallenge(w, challenge.Id)
+			return
+		}
+		auth.WriteJSONError(w, http.StatusUnauthorized, "Incorrect code.")
+		return
+	}
+
+	if err := h.twoFactorRepo.DeleteChallenge(challenge.Id); err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error finishing login: "+err.Error())
+		return
+	}
+	if err := h.limiter.Success(challenge.UserID); err != nil {
+		log.Println("Error resetting failed logins: ", err)
+	}
+	if err := h.sessions.Start(w, r, challenge.UserID, challenge.RememberMe); err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error creating session: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Login successful",
+	})
+}
+
+// dropChallenge ends a pending login after too many wrong codes.
+func (h *TwoFactorHandler) dropChallenge(w http.ResponseWriter, challengeID int) {
+	if err := h.twoFactorRepo.DeleteChallenge(challengeID); err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error ending login: "+err.Error())
+		return
+	}
+	auth.WriteJSONError(w, http.StatusUnauthorized, "Too many wrong codes, please log in again")
+}
+
+// checkSecondFactor accepts either a TOTP code of the enabled secret or an unused recovery code.
+// Both can only be used once.
+func (h *TwoFactorHandler) checkSecondFactor(userID int, code, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		return h.twoFactorRepo.UseRecoveryCode(userID, auth.HashRecoveryCode(recoveryCode))
+	}
+
+	totp, err := h.twoFactorRepo.GetTOTP(userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if !totp.Enabled {
+		return false, nil
+	}
+	step, ok := auth.ValidateTOTP(totp.Secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return h.twoFactorRepo.UseTOTPStep(userID, step)
+}
+
+// confirmSecondFactor checks the code of a logged in user before a change of the two-factor settings.
+// Wrong codes count as failed logins, so a stolen session can't guess codes either. It answers the request
+// and returns false unless the code is correct.
+func (h *TwoFactorHandler) confirmSecondFactor(w http.ResponseWriter, r *http.Request, userID int, code, recoveryCode string) bool {
+	ip := util.GetClientIP(r)
+	wait, err := h.limiter.Check(userID, ip)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error checking login attempts: "+err.Error())
+		return false
+	}
+	if wait > 0 {
+		writeLockedOut(w, wait)
+		return false
+	}
+
+	ok, err := h.checkSecondFactor(userID, code, recoveryCode)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error checking code: "+err.Error())
+		return false
+	}
+	if !ok {
+		recordFailedLogin(h.limiter, h.notifications, userID, ip)
+		auth.WriteJSONError(w, http.StatusBadRequest, "Incorrect code.")
+		return false
+	}
+	if err := h.limiter.Success(userID); err != nil {
+		log.Println("Error resetting failed logins: ", err)
+	}
+	return true
+}
+
+// GetTwoFactorStatusHandler tells whether two-factor authentication is on and how many recovery codes are left.
+func (h *TwoFactorHandler) GetTwoFactorStatusHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var status model.TwoFactorStatus
+	status.Enabled, err = h.twoFactorRepo.IsTOTPEnabled(userID)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting two-factor status: "+err.Error())
+		return
+	}
+	if status.Enabled {
+		status.RecoveryCodesLeft, err = h.twoFactorRepo.CountUnusedRecoveryCodes(userID)
+		if err != nil {
+			auth.WriteJSONError(w, http.StatusInternalServerError, "Error counting recovery codes: "+err.Error())
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// SetupTOTPHandler starts the TOTP enrollment. It creates a new secret and returns it together with the
+// otpauth:// provisioning URI for authenticator apps. Two-factor authentication is only turned on by VerifyTOTPHandler.
+func (h *TwoFactorHandler) SetupTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	enabled, err := h.twoFactorRepo.IsTOTPEnabled(userID)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting two-factor status: "+err.Error())
+		return
+	}
+	if enabled {
+		auth.WriteJSONError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	username, err := h.userRepo.GetUsernameByID(userID)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting user: "+err.Error())
+		return
+	}
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error generating secret: "+err.Error())
+		return
+	}
+	if err := h.twoFactorRepo.SavePendingTOTP(userID, secret); err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error saving secret: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model.TOTPSetup{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(h.config.Issuer, username, secret),
+	})
+}
+
+// VerifyTOTPHandler finishes the enrollment with a code from the authenticator app, turns on two-factor
+// authentication and returns the recovery codes. This is the only time the recovery codes are shown.
+func (h *TwoFactorHandler) VerifyTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	var data model.TwoFactorCodeData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Error parsing JSON data: "+err.Error())
+		return
+	}
+
+	totp, err := h.twoFactorRepo.GetTOTP(userID)
+	if err == sql.ErrNoRows {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Two-factor setup not started")
+		return
+	} else if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error getting two-factor setup: "+err.Error())
+		return
+	}
+	if totp.Enabled {
+		auth.WriteJSONError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	step, ok := auth.ValidateTOTP(totp.Secret, data.Code, time.Now())
+	if !ok {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Incorrect code.")
+		return
+	}
+
+	codes, hashes, err := h.generateRecoveryCodes()
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error generating recovery codes: "+err.Error())
+		return
+	}
+	if err := h.twoFactorRepo.EnableTOTP(userID, step, hashes); err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error enabling two-factor authentication: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTOTPHandler turns two-factor authentication off. It needs a current TOTP code or a recovery code,
+// wrong codes count towards the lockout of the account like at the login.
+func (h *TwoFactorHandler) DisableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	var data model.TwoFactorLoginData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Error parsing JSON data: "+err.Error())
+		return
+	}
+
+	if !h.confirmSecondFactor(w, r, userID, data.Code, data.RecoveryCode) {
+		return
+	}
+	if err := h.twoFactorRepo.DisableTOTP(userID); err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error disabling two-factor authentication: "+err.Error())
+		return
+	}
+	if err := h.notifications.CreateSecurityNotification(userID, "Two-factor authentication was turned off"); err != nil {
+		log.Println("Error creating security notification: ", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodesHandler replaces all recovery codes with new ones, after checking a current TOTP code
+// like DisableTOTPHandler does.
+func (h *TwoFactorHandler) RegenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	var data model.TwoFactorCodeData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		auth.WriteJSONError(w, http.StatusBadRequest, "Error parsing JSON data: "+err.Error())
+		return
+	}
+
+	if !h.confirmSecondFactor(w, r, userID, data.Code, "") {
+		return
+	}
+
+	codes, hashes, err := h.generateRecoveryCodes()
+	if err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error generating recovery codes: "+err.Error())
+		return
+	}
+	if err := h.twoFactorRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		auth.WriteJSONError(w, http.StatusInternalServerError, "Error saving recovery codes: "+err.Error())
+		return
+	}
+	if err := h.notifications.CreateSecurityNotification(userID, "New two-factor recovery codes were created, the old ones don't work anymore"); err != nil {
+		log.Println("Error creating security notification: ", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"recovery_codes": codes,
+	})
+}
+
+func (h *TwoFactorHandler) generateRecoveryCodes() ([]string, []string, error) {
+	codes, err := auth.GenerateRecoveryCodes(h.config.RecoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = auth.HashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
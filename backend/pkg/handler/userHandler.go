@@ -1,26 +1,36 @@
 package handler
 
 import (
+	"backend/pkg/auth"
 	"backend/pkg/model"
 	"backend/pkg/repository"
 	"backend/util"
 	"encoding/json"
+	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserHandler struct {
-	userRepo    *repository.UserRepository
-	sessionRepo *repository.SessionRepository
-	friendsRepo *repository.FriendsRepository
+	userRepo      *repository.UserRepository
+	sessionRepo   *repository.SessionRepository
+	friendsRepo   *repository.FriendsRepository
+	sessions      *auth.SessionManager
+	accounts      *AccountHandler
+	twoFactor     *TwoFactorHandler
+	limiter       *auth.LoginLimiter
+	notifications *NotificationHandler
+	invites       *InviteHandler
 }
 
-func NewUserHandler(uRepo *repository.UserRepository, sRepo *repository.SessionRepository, fRepo *repository.FriendsRepository) *UserHandler {
-	return &UserHandler{userRepo: uRepo, sessionRepo: sRepo, friendsRepo: fRepo}
+func NewUserHandler(uRepo *repository.UserRepository, sRepo *repository.SessionRepository, fRepo *repository.FriendsRepository, sessions *auth.SessionManager, accounts *AccountHandler, twoFactor *TwoFactorHandler, limiter *auth.LoginLimiter, notifications *NotificationHandler, invites *InviteHandler) *UserHandler {
+	return &UserHandler{userRepo: uRepo, sessionRepo: sRepo, friendsRepo: fRepo, sessions: sessions, accounts: accounts, twoFactor: twoFactor, limiter: limiter, notifications: notifications, invites: invites}
 }
 
 func (h *UserHandler) UserRegisterHandler(w http.ResponseWriter, r *http.Request) {
@@ -40,9 +50,17 @@ func (h *UserHandler) UserRegisterHandler(w http.ResponseWriter, r *http.Request
 	regData.DOB = r.FormValue("dob")
 	regData.About = r.FormValue("about")
 
+	// Depending on REGISTRATION_MODE signing up is open, needs an invitation code or isn't possible at all
+	invite, err := h.invites.RedeemInvite(r.FormValue("invite_code"))
+	if err != nil {
+		writeSignupError(w, err)
+		return
+	}
+
 	// Hash the password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(regData.Password), bcrypt.DefaultCost)
 	if err != nil {
+		h.invites.ReleaseInvite(invite)
 		http.Error(w, "Error hashing password", http.StatusInternalServerError)
 		return
 	}
@@ -54,21 +72,23 @@ func (h *UserHandler) UserRegisterHandler(w http.ResponseWriter, r *http.Request
 	// Store user in database
 	userID, err := h.userRepo.RegisterUser(regData)
 	if err != nil {
+		h.invites.ReleaseInvite(invite)
 		http.Error(w, "Error registering user: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.invites.CompleteSignup(int(userID), invite)
 
-	// Generate a session token and store it in database with expiration time
-	sessionToken := util.GenerateSessionToken()
-	h.sessionRepo.StoreSessionInDB(sessionToken, int(userID))
+	// Start a session for the new user and set the session cookie
+	err = h.sessions.Start(w, r, int(userID), false)
+	if err != nil {
+		http.Error(w, "Error creating session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	// Set a cookie with the session token
-	http.SetCookie(w, &http.Cookie{
-		Name:   "session_token",
-		Value:  sessionToken,
-		MaxAge: 60 * 30, // 30 minutes
-		Path:   "/",     // Make cookie available for all paths
-	})
+	// The account works right away, the verification email only unlocks creating content
+	if err := h.accounts.SendVerificationEmail(int(userID)); err != nil {
+		log.Println("Error sending verification email: ", err)
+	}
 
 	// Send a success response
 	response := map[string]interface{}{
@@ -80,7 +100,7 @@ func (h *UserHandler) UserRegisterHandler(w http.ResponseWriter, r *http.Request
 
 func (h *UserHandler) GetUserProfileByIDHandler(w http.ResponseWriter, r *http.Request) {
 	// Get the user ID from the URL
-	requestUserID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	requestUserID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Invalid user ID: "+err.Error(), http.StatusBadRequest)
 		return
@@ -136,43 +156,68 @@ func (h *UserHandler) GetUserProfileByIDHandler(w http.ResponseWriter, r *http.R
 	json.NewEncoder(w).Encode(profile)
 }
 
+// EditUserProfileHandler changes the profile of the authenticated user with PATCH semantics: only the fields
+// present in the request are updated. It accepts JSON or multipart form data, the latter with an optional new
+// avatar image. The email address and the password have their own endpoints and are never changed here.
 func (h *UserHandler) EditUserProfileHandler(w http.ResponseWriter, r *http.Request) {
-	// 1. Parse the multipart form data from the request
-	err1 := r.ParseMultipartForm(10 << 20) // Maximum memory 10MB, change this based on your requirements
-	if err1 != nil {
-		http.Error(w, "Error parsing form data: "+err1.Error(), http.StatusBadRequest)
+	userID, err := auth.UserIDFromRequest(r)
+	if err != nil {
+		http.Error(w, "Error getting user id: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if id := mux.Vars(r)["id"]; id != "me" && id != strconv.Itoa(userID) {
+		http.Error(w, "You can only edit your own profile", http.StatusForbidden)
 		return
 	}
-	var regData model.RegistrationData // variable based on struct fields for storing registration data
-
-	regData.Username = r.FormValue("username")
-	regData.Email = r.FormValue("email")
-	regData.Password = r.FormValue("password")
-	regData.FirstName = r.FormValue("first_name")
-	regData.LastName = r.FormValue("last_name")
-	regData.DOB = r.FormValue("dob")
-	regData.About = r.FormValue("about")
-	regData.ProfileSetting = r.FormValue("profile_setting")
 
-	// get userid from cookie
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
-	if err != nil {
-		http.Error(w, "Error getting user id: "+err.Error(), http.StatusInternalServerError)
+	var data model.ProfileUpdateData
+	hasImage := false
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(10 << 20); err != nil { // Maximum memory 10MB
+			http.Error(w, "Error parsing form data: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		data.Username = formValue(r, "username")
+		data.FirstName = formValue(r, "first_name")
+		data.LastName = formValue(r, "last_name")
+		data.DOB = formValue(r, "dob")
+		data.About = formValue(r, "about")
+		data.ProfileSetting = formValue(r, "profile_setting")
+		_, hasImage = r.MultipartForm.File["image"]
+	} else if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, "Error parsing JSON data: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Hash the password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(regData.Password), bcrypt.DefaultCost)
-	if err != nil {
-		http.Error(w, "Error hashing password", http.StatusInternalServerError)
+	if data.Username != nil {
+		taken, err := h.userRepo.IsUsernameOrEmailTaken(*data.Username, userID)
+		if err != nil {
+			http.Error(w, "Error checking username: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if *data.Username == "" || taken {
+			http.Error(w, "Username is empty or already taken", http.StatusConflict)
+			return
+		}
+	}
+	if (data.FirstName != nil && *data.FirstName == "") || (data.LastName != nil && *data.LastName == "") {
+		http.Error(w, "First and last name can't be empty", http.StatusBadRequest)
+		return
+	}
+	if data.ProfileSetting != nil && *data.ProfileSetting != "public" && *data.ProfileSetting != "private" {
+		http.Error(w, "Profile setting must be public or private", http.StatusBadRequest)
 		return
 	}
-	// Change input password data to hashed variant
-	regData.Password = string(hashedPassword)
 
-	util.ImageSave(w, r, regData.Username, "register") // parses image data from request to the variable
-	// Store user in database
-	err = h.userRepo.UpdateUserProfile(userID, regData)
+	if hasImage {
+		// Avatars are stored under a unique name, so a later username change doesn't clash with them
+		imageKey := "avatar-" + strconv.Itoa(userID) + "-" + strconv.FormatInt(time.Now().Unix(), 10)
+		util.ImageSave(w, r, imageKey, "register")
+		avatarURL := os.Getenv("NEXT_PUBLIC_URL") + ":" + os.Getenv("NEXT_PUBLIC_BACKEND_PORT") + "/images/" + imageKey + ".jpg"
+		data.AvatarURL = &avatarURL
+	}
+
+	err = h.userRepo.UpdateUserProfile(userID, data)
 	if err != nil {
 		http.Error(w, "Error updating user profile: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -186,9 +231,18 @@ func (h *UserHandler) EditUserProfileHandler(w http.ResponseWriter, r *http.Requ
 	json.NewEncoder(w).Encode(response)
 }
 
+// formValue returns a pointer to the form value, or nil if the field wasn't sent at all.
+func formValue(r *http.Request, key string) *string {
+	values, ok := r.MultipartForm.Value[key]
+	if !ok || len(values) == 0 {
+		return nil
+	}
+	return &values[0]
+}
+
 func (h *UserHandler) ListUsersHandler(w http.ResponseWriter, r *http.Request) {
 	// get userid from cookie
-	userID, err := h.sessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		http.Error(w, "Error getting user id: "+err.Error(), http.StatusInternalServerError)
 		return
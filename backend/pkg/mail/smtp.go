@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"backend/pkg/config"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+)
+
+// SMTPMailer sends emails through an SMTP server. Without a username no authentication is used,
+// which is what local catchers like MailHog expect.
+type SMTPMailer struct {
+	from     string
+	addr     string
+	host     string
+	username string
+	password string
+}
+
+func NewSMTPMailer(cfg config.Mail) *SMTPMailer {
+	return &SMTPMailer{
+		from:     cfg.From,
+		addr:     net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		host:     cfg.Host,
+		username: cfg.Username,
+		password: cfg.Password,
+	}
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	sender, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return err
+	}
+	recipient, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+	return smtp.SendMail(m.addr, auth, sender.Address, []string{recipient.Address}, compose(m.from, msg))
+}
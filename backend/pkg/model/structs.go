@@ -20,6 +20,9 @@ type User struct {
 	Profile   string
 	CreatedAt string
 	UpdatedAt string
+	Role      string
+	// EmailVerified is false until the user opened the link of the verification email
+	EmailVerified bool
 }
 
 type UserList struct {
@@ -50,8 +53,9 @@ type Profile struct {
 }
 
 type LoginData struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	RememberMe bool   `json:"remember_me"`
 }
 
 type RegistrationData struct {
@@ -66,6 +70,316 @@ type RegistrationData struct {
 	ProfileSetting string `json:"profile_setting,omitempty"`
 }
 
+// Identity is the authenticated user making a request. The auth middleware puts it into the request context.
+type Identity struct {
+	UserID        int    `json:"user_id"`
+	SessionID     int    `json:"session_id"`
+	Role          string `json:"role"`
+	EmailVerified bool   `json:"email_verified"`
+	// TokenID is set instead of SessionID for requests with a personal access token or an OAuth access token,
+	// which only grant their Scopes. ClientID is the OAuth client an access token was issued to.
+	TokenID  int      `json:"token_id,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+	ClientID string   `json:"client_id,omitempty"`
+}
+
+// Scopes of personal access tokens and OAuth access tokens, each grants access to the routes registered with it
+const (
+	ScopePostsRead  = "posts:read"
+	ScopePostsWrite = "posts:write"
+	ScopeChat       = "chat"
+	ScopeEvents     = "events"
+)
+
+// TokenScopes are all scopes a token can be given.
+var TokenScopes = []string{ScopePostsRead, ScopePostsWrite, ScopeChat, ScopeEvents}
+
+// ScopeDescriptions explain the scopes on the consent screen of third-party apps.
+var ScopeDescriptions = map[string]string{
+	ScopePostsRead:  "Read your feed, group posts, profile posts and comments",
+	ScopePostsWrite: "Create, edit and delete posts and comments and react to them in your name",
+	ScopeChat:       "Send and receive chat messages in your name",
+	ScopeEvents:     "See group events, create and edit them and answer invitations",
+}
+
+// PersonalToken is a named access token for scripts and bots, sent as Authorization: Bearer.
+// Only the hash of the token is stored.
+type PersonalToken struct {
+	Id         int        `json:"id"`
+	UserID     int        `json:"-"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type PersonalTokenData struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// InviteCode lets people sign up when registration is invite-only. MaxUses 0 means unlimited,
+// a nil ExpiresAt never expires.
+type InviteCode struct {
+	Id        int        `json:"id"`
+	Code      string     `json:"code"`
+	CreatorID int        `json:"creator_id"`
+	MaxUses   int        `json:"max_uses"`
+	Uses      int        `json:"uses"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// InviteCodeData creates an invitation code, MaxUses defaults to 1.
+type InviteCodeData struct {
+	MaxUses   *int       `json:"max_uses,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Invitee is a user who signed up with an invitation code of the inviter.
+type Invitee struct {
+	Id           int    `json:"id"`
+	Username     string `json:"username"`
+	AvatarURL    string `json:"avatar_url"`
+	InviteCodeID int    `json:"invite_code_id"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// Purposes of the single use tokens sent by email
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+)
+
+type ChangePasswordData struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ChangeEmailData struct {
+	Email           string `json:"email"`
+	CurrentPassword string `json:"current_password"`
+}
+
+// ProfileUpdateData holds the profile fields to change, nil fields are left as they are.
+// Credentials are changed through their own endpoints.
+type ProfileUpdateData struct {
+	Username       *string `json:"username,omitempty"`
+	FirstName      *string `json:"first_name,omitempty"`
+	LastName       *string `json:"last_name,omitempty"`
+	DOB            *string `json:"dob,omitempty"`
+	AvatarURL      *string `json:"-"` // only set by uploading an image
+	About          *string `json:"about,omitempty"`
+	ProfileSetting *string `json:"profile_setting,omitempty"`
+}
+
+type ForgotPasswordData struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordData struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type VerifyEmailData struct {
+	Token string `json:"token"`
+}
+
+// TOTP is the time-based one-time password setup of a user. Until the first code is verified it isn't enabled.
+type TOTP struct {
+	UserID       int
+	Secret       string
+	Enabled      bool
+	LastUsedStep int64
+}
+
+// MFAChallenge is a login that passed the password check and waits for the second factor.
+type MFAChallenge struct {
+	Id         int
+	UserID     int
+	RememberMe bool
+	Attempts   int
+	ExpiresAt  time.Time
+}
+
+type TwoFactorLoginData struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type TwoFactorCodeData struct {
+	Code string `json:"code"`
+}
+
+type TOTPSetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// Passkey is a WebAuthn credential registered by a user.
+type Passkey struct {
+	Id           int        `json:"id"`
+	UserID       int        `json:"-"`
+	CredentialID string     `json:"credential_id"`
+	PublicKey    []byte     `json:"-"`
+	SignCount    uint32     `json:"-"`
+	Transports   []string   `json:"transports"`
+	Name         string     `json:"name"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+}
+
+// Purposes of WebAuthn challenges
+const (
+	WebAuthnPurposeRegistration = "registration"
+	WebAuthnPurposeLogin        = "login"
+)
+
+// WebAuthnChallenge is a passkey registration or login in progress. UserID is 0 for logins without a username.
+type WebAuthnChallenge struct {
+	Id        int
+	Challenge string
+	UserID    int
+	ExpiresAt time.Time
+}
+
+// PasskeyCredentialData is a PublicKeyCredential serialized to JSON by the browser (credential.toJSON()),
+// with the passkey name on registration and remember_me on login.
+type PasskeyCredentialData struct {
+	ID         string              `json:"id"`
+	RawID      string              `json:"rawId"`
+	Type       string              `json:"type"`
+	Response   PasskeyResponseData `json:"response"`
+	Name       string              `json:"name,omitempty"`
+	RememberMe bool                `json:"remember_me,omitempty"`
+}
+
+type PasskeyResponseData struct {
+	ClientDataJSON    string   `json:"clientDataJSON"`
+	AttestationObject string   `json:"attestationObject,omitempty"`
+	Transports        []string `json:"transports,omitempty"`
+	AuthenticatorData string   `json:"authenticatorData,omitempty"`
+	Signature         string   `json:"signature,omitempty"`
+	UserHandle        string   `json:"userHandle,omitempty"`
+}
+
+type PasskeyLoginData struct {
+	Username string `json:"username,omitempty"`
+}
+
+// OAuthClient is a third-party app registered for the OAuth authorization server. Confidential clients
+// authenticate with a secret, public clients (native and browser apps) only with PKCE.
+type OAuthClient struct {
+	Id               int       `json:"id"`
+	ClientID         string    `json:"client_id"`
+	ClientSecretHash string    `json:"-"`
+	Confidential     bool      `json:"confidential"`
+	Name             string    `json:"name"`
+	RedirectURIs     []string  `json:"redirect_uris"`
+	OwnerID          int       `json:"-"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+type OAuthClientData struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Confidential bool     `json:"confidential"`
+}
+
+// OAuthAuthorizeData is an authorization request of a client. The frontend gets it from the query string of
+// the authorization URL and sends it back with the decision of the user.
+type OAuthAuthorizeData struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+	Approve             bool   `json:"approve"`
+}
+
+// OAuthCode is an authorization code waiting to be exchanged for tokens. ClientID is the ID of the client row.
+type OAuthCode struct {
+	Id            int
+	ClientID      int
+	UserID        int
+	RedirectURI   string
+	Scopes        []string
+	CodeChallenge string
+	ExpiresAt     time.Time
+}
+
+// OAuthToken is an access token with its refresh token. Only the hashes of both are stored.
+type OAuthToken struct {
+	Id               int
+	ClientID         int
+	UserID           int
+	AccessHash       string
+	RefreshHash      string
+	Scopes           []string
+	AccessExpiresAt  time.Time
+	RefreshExpiresAt time.Time
+}
+
+// OAuthAuthorization is a client the user granted access to, with the scopes of its tokens.
+type OAuthAuthorization struct {
+	ClientID  string    `json:"client_id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserIdentity is an account at an external OpenID Connect provider linked to a user.
+type UserIdentity struct {
+	Id          int        `json:"id"`
+	UserID      int        `json:"-"`
+	Provider    string     `json:"provider"`
+	Issuer      string     `json:"issuer"`
+	Subject     string     `json:"-"`
+	Email       string     `json:"email"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
+
+// OIDCState is a login at an OpenID Connect provider in progress. UserID is set when a logged in user
+// links the identity to the account instead of logging in with it.
+type OIDCState struct {
+	Id           int
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	UserID       int
+	RememberMe   bool
+	InviteCode   string // for signing up when registration is invite-only
+	ExpiresAt    time.Time
+}
+
+// Scopes of login throttles
+const (
+	ThrottleScopeAccount = "account"
+	ThrottleScopeIP      = "ip"
+)
+
+// LoginThrottle counts the failed logins of an account (Key is the user ID) or an IP address.
+type LoginThrottle struct {
+	Scope         string     `json:"scope"`
+	Key           string     `json:"key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
+
 type AuthResponse struct {
 	IsAuthenticated bool `json:"is_authenticated"`
 }
@@ -75,6 +389,24 @@ type Session struct {
 	SessionToken string    `json:"session_token"`
 	UserID       int       `json:"user_id"`
 	ExpiresAt    time.Time `json:"expires_at"`
+	UserAgent    string    `json:"user_agent"`
+	IPAddress    string    `json:"ip_address"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastSeenAt   time.Time `json:"last_seen_at"`
+	RememberMe   bool      `json:"remember_me"`
+	// Sliding renewal never extends ExpiresAt past this point
+	AbsoluteExpiresAt time.Time `json:"absolute_expires_at"`
+}
+
+// ActiveSession is the public view of a session, the token itself is never exposed
+type ActiveSession struct {
+	Id         int       `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
 }
 
 type Post struct {
@@ -90,31 +422,75 @@ type Post struct {
 }
 
 type PostsResponse struct {
-	Id             int       `json:"id"`
-	UserID         int       `json:"user_id"`
-	GroupID        int       `json:"group_id,omitempty"`
-	Title          string    `json:"title"`
-	Content        string    `json:"content,omitempty"`
-	ImageURL       string    `json:"image_url,omitempty"`
-	PrivacySetting string    `json:"privacy_setting"`
-	CreatedAt      time.Time `json:"created_at"`
-	Likes          int       `json:"likes"`
-	Dislikes       int       `json:"dislikes"`
-	Creator        string    `json:"creator"`
-	CreatorAvatar  string    `json:"creator_avatar"`
+	Id             int            `json:"id"`
+	UserID         int            `json:"user_id"`
+	GroupID        int            `json:"group_id,omitempty"`
+	Title          string         `json:"title"`
+	Content        string         `json:"content,omitempty"`
+	ImageURL       string         `json:"image_url,omitempty"`
+	PrivacySetting string         `json:"privacy_setting"`
+	CreatedAt      time.Time      `json:"created_at"`
+	Reactions      map[string]int `json:"reactions"`   // number of users per reaction, reactions nobody gave are left out
+	MyReaction     string         `json:"my_reaction"` // reaction of the requesting user, empty without a reaction
+	Creator        string         `json:"creator"`
+	CreatorAvatar  string         `json:"creator_avatar"`
+	Mentions       []Mention      `json:"mentions"`
+}
+
+// PostCursor points at the last post of a feed page, the next page starts after it.
+type PostCursor struct {
+	CreatedAt time.Time
+	Id        int
+}
+
+// PostPage selects a page of a feed, newest posts first: up to Limit posts after the cursor,
+// After is nil for the first page.
+type PostPage struct {
+	After *PostCursor
+	Limit int
+}
+
+// PostsPage is a page of a post feed, NextCursor is left out on the last page.
+type PostsPage struct {
+	Posts      []PostsResponse `json:"posts"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// RankedCursor points into the ranked feed: the time the first page was ranked at, the posts ranked then
+// are the posts after Before, so later pages rank the same posts, and the number of posts on the earlier pages.
+type RankedCursor struct {
+	RankedAt time.Time
+	Before   PostCursor
+	Offset   int
+}
+
+// RankCandidate is a post of the ranked feed with the signals rankers score it by.
+type RankCandidate struct {
+	Post       Post
+	Reactions  int  // reactions of all users to the post
+	Comments   int  // comments on the post
+	FromFriend bool // the author is an accepted friend of the viewer
+	Affinity   int  // reactions and comments of the viewer on posts of the author
+	InMyGroup  bool // the post is in a group the viewer is a member of
 }
 
 type CommentsResponse struct {
-	Id        int       `json:"id"`
-	PostID    int       `json:"post_id"`
-	UserID    int       `json:"user_id,omitempty"`
-	Content   string    `json:"content"`
-	Image     string    `json:"image,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	Likes     int       `json:"likes"`
-	Dislikes  int       `json:"dislikes"`
-	Username  string    `json:"username"`
-	ImageURL  string    `json:"profile_image"`
+	Id         int            `json:"id"`
+	PostID     int            `json:"post_id"`
+	UserID     int            `json:"user_id,omitempty"`
+	Content    string         `json:"content"`
+	Image      string         `json:"image,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	Reactions  map[string]int `json:"reactions"`   // number of users per reaction, reactions nobody gave are left out
+	MyReaction string         `json:"my_reaction"` // reaction of the requesting user, empty without a reaction
+	Username   string         `json:"username"`
+	ImageURL   string         `json:"profile_image"`
+	Mentions   []Mention      `json:"mentions"`
+	ParentID   int            `json:"parent_id,omitempty"` // the comment replied to, left out for comments on the post
+	Depth      int            `json:"depth"`
+	ReplyCount int            `json:"reply_count"` // direct replies to the comment
+	Edited     bool           `json:"edited"`
+	EditedAt   *time.Time     `json:"edited_at,omitempty"` // the last edit, the previous versions are at /post/comment/{id}/revisions
 }
 
 type CreateCommentRequest struct {
@@ -131,6 +507,8 @@ type CreatePostRequest struct {
 	GroupID        int    `json:"group_id,omitempty"`
 	ImageURL       string `json:"image_url,omitempty"`
 	PrivacySetting string `json:"privacy_setting"`
+	Audience       []int  `json:"audience,omitempty"`       // user IDs who can see a custom post
+	AudienceLists  []int  `json:"audience_lists,omitempty"` // audience list IDs whose members can see a custom post
 	CreatedAt      string `json:"created_at"`
 }
 
@@ -140,6 +518,23 @@ type UpdatePostRequest struct {
 	Content        string `json:"content,omitempty"`
 	ImageURL       string `json:"image_url,omitempty"`
 	PrivacySetting string `json:"privacy_setting"`
+	Audience       []int  `json:"audience,omitempty"`       // user IDs who can see a custom post
+	AudienceLists  []int  `json:"audience_lists,omitempty"` // audience list IDs whose members can see a custom post
+}
+
+// AudienceList is a named list of friends, like close friends or family, to pick as audience of custom posts.
+type AudienceList struct {
+	Id        int       `json:"id"`
+	OwnerID   int       `json:"owner_id"`
+	Name      string    `json:"name"`
+	Members   []int     `json:"members"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AudienceListData creates an audience list or changes it, omitted fields are left unchanged.
+type AudienceListData struct {
+	Name    *string `json:"name,omitempty"`
+	Members *[]int  `json:"members,omitempty"`
 }
 
 type Comment struct {
@@ -150,14 +545,21 @@ type Comment struct {
 	Image     sql.NullString `json:"image,omitempty"`
 	CreatedAt time.Time      `json:"created_at,omitempty"`
 	UpdatedAt time.Time      `json:"updated_at,omitempty"`
+	ParentID  int            `json:"parent_id,omitempty"` // the comment replied to, 0 for comments on the post
+	Depth     int            `json:"depth"`               // 0 for comments on the post, 1 for replies to them and so on
+	Edited    bool           `json:"edited"`              // the comment has revisions, UpdatedAt is the last edit
 }
 
+// UpdateCommentRequest is the new content of a comment. The comment and its author come from the URL and the session.
 type UpdateCommentRequest struct {
-	Id        int       `json:"id"`
-	PostID    int       `json:"post_id"`
-	UserID    int       `json:"user_id"`
-	Content   string    `json:"content"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Content string `json:"content"`
+}
+
+// CommentRevision is a previous version of an edited comment.
+type CommentRevision struct {
+	Content    string    `json:"content"`
+	WrittenAt  time.Time `json:"written_at"`  // when this version was written
+	ReplacedAt time.Time `json:"replaced_at"` // when an edit replaced it
 }
 
 type Group struct {
@@ -219,8 +621,25 @@ type Notification struct {
 	Message   string    `json:"message"`
 	IsRead    bool      `json:"is_read"`
 	CreatedAt time.Time `json:"created_at"`
+	// Mention notifications link to the post, and the comment for mentions in comments
+	PostId    int `json:"post_id,omitempty"`
+	CommentId int `json:"comment_id,omitempty"`
+}
+
+// Mention is an @username in a post, comment or chat message. Username is written as in the text,
+// clients turn it into a link to the profile of the user.
+type Mention struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
 }
 
+// Items users can be mentioned in
+const (
+	MentionItemPost    = "post"
+	MentionItemComment = "comment"
+	MentionItemMessage = "message"
+)
+
 type GroupInvitation struct {
 	Id           int       `json:"id"`
 	GroupId      int       `json:"group_id"`
@@ -257,8 +676,72 @@ type EventAttendance struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
-type VoteData struct {
-	Item   string `json:"item"`    // 'comment' or 'post'
-	ItemID int    `json:"item_id"` // comment or post id
-	Action string `json:"action"`  // 'like' or 'dislike'
+// Types of search results
+const (
+	SearchPost    = "post"
+	SearchComment = "comment"
+	SearchUser    = "user"
+	SearchGroup   = "group"
+	SearchEvent   = "event"
+)
+
+// SearchQuery is a full-text search, Types empty searches everything.
+type SearchQuery struct {
+	Match  string // FTS5 query, see handler.searchMatch
+	Types  []string
+	Limit  int
+	Offset int
+}
+
+// SearchResult is one match of a search. Snippet is the matching text with the matches between <mark> and </mark>,
+// the text itself is not escaped.
+type SearchResult struct {
+	Type    string  `json:"type"`
+	Id      int     `json:"id"`
+	Title   string  `json:"title"`              // title of the post, group or event, post title for comments, username for users
+	Snippet string  `json:"snippet"`            // matching text with the matches marked
+	PostID  int     `json:"post_id,omitempty"`  // post of a comment
+	GroupID int     `json:"group_id,omitempty"` // group of a group post, group comment or event
+	Score   float64 `json:"score"`              // relevance, higher is better
+}
+
+// SearchResults is a page of search results, NextOffset is left out on the last page.
+type SearchResults struct {
+	Results    []SearchResult `json:"results"`
+	NextOffset int            `json:"next_offset,omitempty"`
+}
+
+// TrendingTag is a hashtag with how often it was used in the window of the trending tags and by how many users.
+type TrendingTag struct {
+	Name  string `json:"name"`
+	Uses  int    `json:"uses"`
+	Users int    `json:"users"`
+}
+
+// TrendingTags are the most used hashtags since Since, the ones used by the most users first.
+type TrendingTags struct {
+	Since time.Time     `json:"since"`
+	Tags  []TrendingTag `json:"tags"`
+}
+
+// Items users can react to
+const (
+	ReactionItemPost    = "post"
+	ReactionItemComment = "comment"
+	ReactionItemMessage = "message" // chat message
+)
+
+type ReactionData struct {
+	Item     string `json:"item"`             // 'post', 'comment' or 'message'
+	ItemID   int    `json:"item_id"`          // post, comment or chat message id
+	Reaction string `json:"reaction"`         // one of the configured reactions, like 'love'
+	Action   string `json:"action,omitempty"` // 'like' or 'dislike', used by /vote instead of reaction
+}
+
+// ReactionsResponse has the reactions of an item after a user reacted to it.
+type ReactionsResponse struct {
+	Item       string         `json:"item"`
+	ItemID     int            `json:"item_id"`
+	Reactions  map[string]int `json:"reactions"`
+	MyReaction string         `json:"my_reaction"`
 }
@@ -0,0 +1,68 @@
+// Package ranking orders the posts of the ranked main feed. Rankers only see the candidates and their signals,
+// loaded by repository.FeedRepository, so a new ranker can be tried by implementing Ranker and passing it to
+// handler.NewPostHandler.
+package ranking
+
+import (
+	"backend/pkg/config"
+	"backend/pkg/model"
+	"math"
+	"sort"
+	"time"
+)
+
+// Ranker orders the candidates of the ranked feed.
+type Ranker interface {
+	// Rank returns the candidates best first. now is the time the feed is ranked at, to judge recency by,
+	// the same for all pages of a feed.
+	Rank(candidates []model.RankCandidate, now time.Time) []model.RankCandidate
+}
+
+// WeightedRanker scores every post with a weighted sum of its signals, see config.RankWeights.
+type WeightedRanker struct {
+	weights config.RankWeights
+}
+
+func NewWeightedRanker(weights config.RankWeights) *WeightedRanker {
+	return &WeightedRanker{weights: weights}
+}
+
+// Score returns how high the post is ranked, posts with equal scores are ordered newest first.
+func (r *WeightedRanker) Score(candidate model.RankCandidate, now time.Time) float64 {
+	w := r.weights
+	score := w.Affinity*math.Log1p(float64(candidate.Affinity)) +
+		w.Reactions*math.Log1p(float64(candidate.Reactions)) +
+		w.Comments*math.Log1p(float64(candidate.Comments))
+	// Recency halves every half-life, posts from the future count as new. Weights built without
+	// a half-life leave recency out, dividing by it would give NaN scores that break the sort.
+	if w.RecencyHalfLife > 0 {
+		age := math.Max(now.Sub(candidate.Post.CreatedAt).Hours(), 0)
+		score += w.Recency * math.Exp2(-age/w.RecencyHalfLife.Hours())
+	}
+	if candidate.FromFriend {
+		score += w.Friend
+	}
+	if candidate.InMyGroup {
+		score += w.Group
+	}
+	return score
+}
+
+func (r *WeightedRanker) Rank(candidates []model.RankCandidate, now time.Time) []model.RankCandidate {
+	scores := make(map[int]float64, len(candidates))
+	for _, candidate := range candidates {
+		scores[candidate.Post.Id] = r.Score(candidate, now)
+	}
+	ranked := append([]model.RankCandidate(nil), candidates...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i].Post, ranked[j].Post
+		if scores[a.Id] != scores[b.Id] {
+			return scores[a.Id] > scores[b.Id]
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.Id > b.Id
+	})
+	return ranked
+}
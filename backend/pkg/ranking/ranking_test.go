@@ -0,0 +1,79 @@
+package ranking
+
+import (
+	"backend/pkg/config"
+	"backend/pkg/model"
+	"math"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func candidate(id int, age time.Duration) model.RankCandidate {
+	return model.RankCandidate{Post: model.Post{Id: id, CreatedAt: testNow.Add(-age)}}
+}
+
+func TestWeightedRankerScore(t *testing.T) {
+	recency := config.RankWeights{Recency: 4, RecencyHalfLife: 24 * time.Hour}
+	tests := []struct {
+		name      string
+		weights   config.RankWeights
+		candidate model.RankCandidate
+		want      float64
+	}{
+		{name: "brand new", weights: recency, candidate: candidate(1, 0), want: 4},
+		{name: "one half-life old", weights: recency, candidate: candidate(1, 24*time.Hour), want: 2},
+		{name: "two half-lives old", weights: recency, candidate: candidate(1, 48*time.Hour), want: 1},
+		{name: "from the future counts as new", weights: recency, candidate: candidate(1, -time.Hour), want: 4},
+		{name: "no half-life leaves recency out", weights: config.RankWeights{Recency: 4}, candidate: candidate(1, 0), want: 0},
+		{name: "friend bonus", weights: config.RankWeights{Friend: 1.5}, candidate: func() model.RankCandidate {
+			c := candidate(1, 0)
+			c.FromFriend = true
+			return c
+		}(), want: 1.5},
+		{name: "no friend bonus for others", weights: config.RankWeights{Friend: 1.5}, candidate: candidate(1, 0), want: 0},
+		{name: "group bonus", weights: config.RankWeights{Group: 0.8}, candidate: func() model.RankCandidate {
+			c := candidate(1, 0)
+			c.InMyGroup = true
+			return c
+		}(), want: 0.8},
+		{name: "counts by their logarithm", weights: config.RankWeights{Reactions: 1, Comments: 2, Affinity: 3}, candidate: func() model.RankCandidate {
+			c := candidate(1, 0)
+			c.Reactions, c.Comments, c.Affinity = 1, 3, 7
+			return c
+		}(), want: math.Log(2) + 2*math.Log(4) + 3*math.Log(8)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewWeightedRanker(tt.weights).Score(tt.candidate, testNow)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Fatalf("score = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeightedRankerRank(t *testing.T) {
+	ranker := NewWeightedRanker(config.RankWeights{Recency: 4, RecencyHalfLife: 24 * time.Hour, Friend: 3})
+	friend := candidate(1, 48*time.Hour) // 1 for recency + 3 for the friend
+	friend.FromFriend = true
+	candidates := []model.RankCandidate{
+		candidate(2, 72*time.Hour), // 0.5
+		friend,
+		candidate(3, 24*time.Hour), // 2
+		candidate(4, -time.Hour),   // 4, from the future
+		candidate(5, 24*time.Hour), // 2 as well, newer ID first
+	}
+
+	ranked := ranker.Rank(candidates, testNow)
+	want := []int{4, 1, 5, 3, 2}
+	for i, id := range want {
+		if ranked[i].Post.Id != id {
+			t.Fatalf("rank %d is post %d, want order %v", i, ranked[i].Post.Id, want)
+		}
+	}
+	if candidates[0].Post.Id != 2 {
+		t.Fatal("Rank reordered the candidates passed in")
+	}
+}
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"backend/pkg/model"
+	"database/sql"
+)
+
+type AudienceListRepository struct {
+	db *sql.DB
+}
+
+func NewAudienceListRepository(db *sql.DB) *AudienceListRepository {
+	return &AudienceListRepository{db: db}
+}
+
+func (r *AudienceListRepository) CreateList(ownerID int, name string, members []int) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO audience_lists (owner_id, name) VALUES (?, ?)`, ownerID, name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := setListMembers(tx, int(id), members); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// GetListsByOwnerID returns the lists of a user with their members, ordered by name.
+func (r *AudienceListRepository) GetListsByOwnerID(ownerID int) ([]model.AudienceList, error) {
+	rows, err := r.db.Query(`SELECT id, owner_id, name, created_at FROM audience_lists WHERE owner_id = ? ORDER BY name, id`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lists []model.AudienceList
+	for rows.Next() {
+		var list model.AudienceList
+		if err := rows.Scan(&list.Id, &list.OwnerID, &list.Name, &list.CreatedAt); err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range lists {
+		if lists[i].Members, err = r.GetListMembers(lists[i].Id); err != nil {
+			return nil, err
+		}
+	}
+	return lists, nil
+}
+
+func (r *AudienceListRepository) GetListByID(id int) (model.AudienceList, error) {
+	var list model.AudienceList
+	err := r.db.QueryRow(`SELECT id, owner_id, name, created_at FROM audience_lists WHERE id = ?`, id).
+		Scan(&list.Id, &list.OwnerID, &list.Name, &list.CreatedAt)
+	if err != nil {
+		return model.AudienceList{}, err
+	}
+	list.Members, err = r.GetListMembers(id)
+	return list, err
+}
+
+func (r *AudienceListRepository) GetListMembers(listID int) ([]int, error) {
+	rows, err := r.db.Query(`SELECT user_id FROM audience_list_members WHERE list_id = ? ORDER BY user_id`, listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []int{}
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		members = append(members, userID)
+	}
+	return members, rows.Err()
+}
+
+// CountListsOwnedBy counts how many of the lists belong to the user.
+func (r *AudienceListRepository) CountListsOwnedBy(ownerID int, listIDs []int) (int, error) {
+	count := 0
+	for _, id := range listIDs {
+		var owned int
+		err := r.db.QueryRow(`SELECT COUNT(*) FROM audience_lists WHERE id = ? AND owner_id = ?`, id, ownerID).Scan(&owned)
+		if err != nil {
+			return 0, err
+		}
+		count += owned
+	}
+	return count, nil
+}
+
+// UpdateList renames the list and replaces its members, nil arguments are left unchanged. Posts shared
+// with the list are visible to its members at the time they are viewed.
+func (r *AudienceListRepository) UpdateList(id int, name *string, members *[]int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if name != nil {
+		if _, err := tx.Exec(`UPDATE audience_lists SET name = ? WHERE id = ?`, *name, id); err != nil {
+			return err
+		}
+	}
+	if members != nil {
+		if err := setListMembers(tx, id, *members); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteList removes the list, its members lose access to the posts shared with it.
+func (r *AudienceListRepository) DeleteList(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM post_audience_lists WHERE list_id = ?`,
+		`DELETE FROM audience_list_members WHERE list_id = ?`,
+		`DELETE FROM audience_lists WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(query, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func setListMembers(tx *sql.Tx, listID int, members []int) error {
+	if _, err := tx.Exec(`DELETE FROM audience_list_members WHERE list_id = ?`, listID); err != nil {
+		return err
+	}
+	for _, userID := range members {
+		if _, err := tx.Exec(`INSERT INTO audience_list_members (list_id, user_id) VALUES (?, ?)`, listID, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListNameExists checks whether the user has another list with the name.
+func (r *AudienceListRepository) ListNameExists(ownerID int, name string, exceptID int) (bool, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM audience_lists WHERE owner_id = ? AND name = ? AND id != ?`, ownerID, name, exceptID).Scan(&count)
+	return count > 0, err
+}
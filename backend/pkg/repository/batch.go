@@ -0,0 +1,16 @@
+package repository
+
+import "strings"
+
+// inClause returns the placeholders and arguments of an IN (...) list, for loading the rows of a whole
+// page with one query. Empty lists give (NULL), which matches nothing.
+func inClause(ids []int) (string, []interface{}) {
+	if len(ids) == 0 {
+		return "(NULL)", nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return "(" + strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ") + ")", args
+}
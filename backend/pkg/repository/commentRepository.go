@@ -15,8 +15,18 @@ func NewCommentRepository(db *sql.DB) *CommentRepository {
 	return &CommentRepository{db: db}
 }
 
+// commentColumns are the columns of a comment in the order of commentFields.
+const commentColumns = `comments.id, comments.post_id, comments.user_id, comments.content, comments.image_url, comments.created_at, comments.updated_at,
+	COALESCE(comments.parent_id, 0), comments.depth, EXISTS (SELECT 1 FROM comment_revisions WHERE comment_revisions.comment_id = comments.id)`
+
+// commentFields returns the scan destinations of commentColumns.
+func commentFields(comment *model.Comment) []interface{} {
+	return []interface{}{&comment.Id, &comment.PostID, &comment.UserID, &comment.Content, &comment.Image, &comment.CreatedAt, &comment.UpdatedAt,
+		&comment.ParentID, &comment.Depth, &comment.Edited}
+}
+
 func (r *CommentRepository) GetCommentsByUserID(id int) ([]model.Comment, error) {
-	query := `SELECT * FROM comments WHERE user_id = ?`
+	query := `SELECT ` + commentColumns + ` FROM comments WHERE user_id = ?`
 	rows, err := r.db.Query(query, id)
 	if err != nil {
 		return nil, err
@@ -26,7 +36,7 @@ func (r *CommentRepository) GetCommentsByUserID(id int) ([]model.Comment, error)
 	var comments []model.Comment
 	for rows.Next() {
 		var comment model.Comment
-		if err := rows.Scan(&comment.Id, &comment.PostID, &comment.UserID, &comment.Content, &comment.Image, &comment.CreatedAt, &comment.UpdatedAt); err != nil {
+		if err := rows.Scan(commentFields(&comment)...); err != nil {
 			return nil, err
 		}
 		comments = append(comments, comment)
@@ -39,22 +49,46 @@ func (r *CommentRepository) GetCommentsByUserID(id int) ([]model.Comment, error)
 	return comments, nil
 }
 
+// GetCommentByID returns sql.ErrNoRows for unknown comments.
+func (r *CommentRepository) GetCommentByID(id int) (model.Comment, error) {
+	var comment model.Comment
+	err := r.db.QueryRow(`SELECT `+commentColumns+` FROM comments WHERE id = ?`, id).Scan(commentFields(&comment)...)
+	return comment, err
+}
+
 func (r *CommentRepository) CreateComment(comment *model.Comment) (int64, error) {
-	query := `INSERT INTO comments (post_id, user_id, content) 
-	VALUES (?, ?, ?)`
-	result, err := r.db.Exec(query, comment.PostID, comment.UserID, comment.Content)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Replies keep the ID of their parent comment and their depth, comments on the post have no parent
+	var parentID interface{}
+	if comment.ParentID != 0 {
+		parentID = comment.ParentID
+	}
+	query := `INSERT INTO comments (post_id, user_id, content, parent_id, depth) 
+	VALUES (?, ?, ?, ?, ?)`
+	result, err := tx.Exec(query, comment.PostID, comment.UserID, comment.Content, parentID, comment.Depth)
 	if err != nil {
 		return 0, err
 	}
 	lastInsertID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := setCommentTags(tx, int(lastInsertID), comment.Content); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
 	if comment.Image.Valid && comment.Image.String == "" {
 		return lastInsertID, nil
 	}
 	comment.Image.String = os.Getenv("NEXT_PUBLIC_URL") + ":" + os.Getenv("NEXT_PUBLIC_BACKEND_PORT") + "/images/comments/" + fmt.Sprint(lastInsertID) + ".jpg"
 	r.AddImageUrlToComment(int(lastInsertID), comment.Image.String)
-	if err != nil {
-		fmt.Println("Error getting last inserted comment id")
-	}
 	return lastInsertID, nil
 }
 
@@ -80,26 +114,118 @@ func (r *CommentRepository) GetCommentImageURL(id int) (string, error) {
 	return "", nil
 }
 
+// DeleteComment deletes a comment of the user with its revisions, hashtags and mentions.
 func (r *CommentRepository) DeleteComment(id int, userid int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `DELETE FROM comments WHERE id = ? AND user_id = ?`
-	_, err := r.db.Exec(query, id)
+	result, err := tx.Exec(query, id, userid)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no comment found with the specified id that belongs to the user")
+	}
+	if _, err := tx.Exec(`DELETE FROM comment_revisions WHERE comment_id = ?`, id); err != nil {
+		return err
+	}
+	if err := setCommentTags(tx, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM mentions WHERE item_type = 'comment' AND item_id = ?`, id); err != nil {
+		return err
+	}
+	if err := deleteReactions(tx, model.ReactionItemComment, "= ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deletePostComments removes the comments of a deleted post with their revisions, hashtags, mentions and reactions.
+func deletePostComments(tx *sql.Tx, postID int) error {
+	const comments = `IN (SELECT id FROM comments WHERE post_id = ?)`
+	if err := deleteReactions(tx, model.ReactionItemComment, comments, postID); err != nil {
+		return err
+	}
+	for _, query := range []string{
+		`DELETE FROM comment_revisions WHERE comment_id ` + comments,
+		`DELETE FROM comment_tags WHERE comment_id ` + comments,
+		`DELETE FROM mentions WHERE item_type = 'comment' AND item_id ` + comments,
+		`DELETE FROM comments WHERE post_id = ?`,
+	} {
+		if _, err := tx.Exec(query, postID); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// UpdateComment replaces the content of a comment of the user and keeps the previous version as a revision.
+// Saving the same content again changes nothing.
 func (r *CommentRepository) UpdateComment(commentId int, userId int, comment model.UpdateCommentRequest) error {
-	query := `UPDATE comments SET content = ? WHERE id = ? AND user_id = ?`
-	_, err := r.db.Exec(query, comment.Content, comment.Id, comment.UserID)
+	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+
+	var content string
+	err = tx.QueryRow(`SELECT content FROM comments WHERE id = ? AND user_id = ?`, commentId, userId).Scan(&content)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no comment found with the specified id that belongs to the user")
+	} else if err != nil {
+		return err
+	}
+	if content == comment.Content {
+		return nil
+	}
+
+	// The previous version was written when the comment was created or last edited
+	if _, err := tx.Exec(`INSERT INTO comment_revisions (comment_id, content, written_at)
+		SELECT id, content, COALESCE(updated_at, created_at) FROM comments WHERE id = ?`, commentId); err != nil {
+		return err
+	}
+	query := `UPDATE comments SET content = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?`
+	if _, err := tx.Exec(query, comment.Content, commentId, userId); err != nil {
+		return err
+	}
+	if err := setCommentTags(tx, commentId, comment.Content); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetCommentRevisions returns the previous versions of a comment, the newest first.
+func (r *CommentRepository) GetCommentRevisions(commentID int) ([]model.CommentRevision, error) {
+	rows, err := r.db.Query(`SELECT content, written_at, replaced_at FROM comment_revisions
+		WHERE comment_id = ? ORDER BY replaced_at DESC, id DESC`, commentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []model.CommentRevision{}
+	for rows.Next() {
+		var revision model.CommentRevision
+		if err := rows.Scan(&revision.Content, &revision.WrittenAt, &revision.ReplacedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
 }
 
+// GetAllPostComments returns all comments of a post and the replies to them, oldest first.
 func (r *CommentRepository) GetAllPostComments(id int) ([]model.Comment, error) {
-	query := `SELECT * FROM comments WHERE post_id = ?`
+	query := `SELECT ` + commentColumns + ` FROM comments WHERE post_id = ? ORDER BY comments.created_at, comments.id`
 	rows, err := r.db.Query(query, id)
 	if err != nil {
 		return nil, err
@@ -109,7 +235,7 @@ func (r *CommentRepository) GetAllPostComments(id int) ([]model.Comment, error)
 	var comments []model.Comment
 	for rows.Next() {
 		var comment model.Comment
-		if err := rows.Scan(&comment.Id, &comment.PostID, &comment.UserID, &comment.Content, &comment.Image, &comment.CreatedAt, &comment.UpdatedAt); err != nil {
+		if err := rows.Scan(commentFields(&comment)...); err != nil {
 			return nil, err
 		}
 		comments = append(comments, comment)
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"backend/pkg/model"
+	"database/sql"
+)
+
+// FeedRepository loads the signals the ranked feed scores posts by.
+type FeedRepository struct {
+	db *sql.DB
+}
+
+func NewFeedRepository(db *sql.DB) *FeedRepository {
+	return &FeedRepository{db: db}
+}
+
+// GetRankCandidates adds the ranking signals to the posts, with one query per signal for all posts.
+func (r *FeedRepository) GetRankCandidates(userID int, posts []model.Post) ([]model.RankCandidate, error) {
+	postIDs := make([]int, len(posts))
+	authorIDs := make([]int, len(posts))
+	for i, post := range posts {
+		postIDs[i] = post.Id
+		authorIDs[i] = post.UserID
+	}
+
+	postsIn, postArgs := inClause(postIDs)
+	reactions, err := r.countsByID(`SELECT item_id, SUM(count) FROM reaction_counts
+		WHERE item_type = 'post' AND item_id IN `+postsIn+` GROUP BY item_id`, postArgs...)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := r.countsByID(`SELECT post_id, COUNT(*) FROM comments WHERE post_id IN `+postsIn+` GROUP BY post_id`, postArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Affinity counts what the user did on posts of the authors, reactions and comments alike
+	authorsIn, authorArgs := inClause(authorIDs)
+	args := append([]interface{}{userID, userID}, authorArgs...)
+	args = append(append(args, userID, userID), authorArgs...)
+	affinity, err := r.countsByID(`SELECT author_id, COUNT(*) FROM (
+		SELECT posts.user_id AS author_id FROM reactions
+		JOIN posts ON reactions.item_type = 'post' AND reactions.item_id = posts.id
+		WHERE reactions.user_id = ? AND posts.user_id != ? AND posts.user_id IN `+authorsIn+`
+		UNION ALL
+		SELECT posts.user_id AS author_id FROM comments
+		JOIN posts ON comments.post_id = posts.id
+		WHERE comments.user_id = ? AND posts.user_id != ? AND posts.user_id IN `+authorsIn+`
+	) GROUP BY author_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	friends, err := r.idSet(`SELECT user_id1 FROM friends WHERE user_id2 = ? AND status = 'accepted'
+		UNION
+		SELECT user_id2 FROM friends WHERE user_id1 = ? AND status = 'accepted'`, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := r.idSet(`SELECT group_id FROM group_members WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]model.RankCandidate, len(posts))
+	for i, post := range posts {
+		candidates[i] = model.RankCandidate{
+			Post:       post,
+			Reactions:  reactions[post.Id],
+			Comments:   comments[post.Id],
+			FromFriend: friends[post.UserID],
+			Affinity:   affinity[post.UserID],
+			InMyGroup:  post.GroupID != 0 && groups[post.GroupID],
+		}
+	}
+	return candidates, nil
+}
+
+// countsByID reads the rows of a query selecting an ID and a count into a map.
+func (r *FeedRepository) countsByID(query string, args ...interface{}) (map[int]int, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var id, count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, err
+		}
+		counts[id] = count
+	}
+	return counts, rows.Err()
+}
+
+// idSet reads the rows of a query selecting IDs into a set.
+func (r *FeedRepository) idSet(query string, args ...interface{}) (map[int]bool, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
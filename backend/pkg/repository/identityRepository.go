@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"backend/pkg/model"
+	"database/sql"
+	"time"
+)
+
+type IdentityRepository struct {
+	db *sql.DB
+}
+
+func NewIdentityRepository(db *sql.DB) *IdentityRepository {
+	return &IdentityRepository{db: db}
+}
+
+// GetIdentity returns the linked identity with the subject at the issuer or sql.ErrNoRows.
+func (r *IdentityRepository) GetIdentity(issuer, subject string) (model.UserIdentity, error) {
+	var identity model.UserIdentity
+	var lastLoginAt sql.NullTime
+	err := r.db.QueryRow(`SELECT id, user_id, provider, issuer, subject, email, created_at, last_login_at
+	FROM user_identities WHERE issuer = ? AND subject = ?`, issuer, subject).Scan(
+		&identity.Id, &identity.UserID, &identity.Provider, &identity.Issuer, &identity.Subject, &identity.Email, &identity.CreatedAt, &lastLoginAt)
+	if err != nil {
+		return model.UserIdentity{}, err
+	}
+	if lastLoginAt.Valid {
+		identity.LastLoginAt = &lastLoginAt.Time
+	}
+	return identity, nil
+}
+
+func (r *IdentityRepository) GetIdentitiesByUserID(userID int) ([]model.UserIdentity, error) {
+	rows, err := r.db.Query(`SELECT id, user_id, provider, issuer, subject, email, created_at, last_login_at
+	FROM user_identities WHERE user_id = ? ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identities []model.UserIdentity
+	for rows.Next() {
+		var identity model.UserIdentity
+		var lastLoginAt sql.NullTime
+		if err := rows.Scan(&identity.Id, &identity.UserID, &identity.Provider, &identity.Issuer, &identity.Subject, &identity.Email, &identity.CreatedAt, &lastLoginAt); err != nil {
+			return nil, err
+		}
+		if lastLoginAt.Valid {
+			identity.LastLoginAt = &lastLoginAt.Time
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+func (r *IdentityRepository) CreateIdentity(identity model.UserIdentity) (int64, error) {
+	result, err := r.db.Exec(`INSERT INTO user_identities (user_id, provider, issuer, subject, email, last_login_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		identity.UserID, identity.Provider, identity.Issuer, identity.Subject, identity.Email, identity.LastLoginAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// CreateUserWithIdentity creates an account for a user logging in with a provider for the first time
+// together with the link to the identity, so neither exists without the other.
+func (r *IdentityRepository) CreateUserWithIdentity(user model.RegistrationData, emailVerified bool, identity model.UserIdentity) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var verifiedAt interface{}
+	if emailVerified {
+		verifiedAt = time.Now()
+	}
+	result, err := tx.Exec(`INSERT INTO users (username, email, password, first_name, last_name, date_of_birth, avatar_url, about_me, email_verified_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		user.Username, user.Email, user.Password, user.FirstName, user.LastName, user.DOB, user.AvatarURL, user.About, verifiedAt)
+	if err != nil {
+		return 0, err
+	}
+	userID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(`INSERT INTO user_identities (user_id, provider, issuer, subject, email, last_login_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		userID, identity.Provider, identity.Issuer, identity.Subject, identity.Email, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return int(userID), tx.Commit()
+}
+
+func (r *IdentityRepository) UpdateIdentityLogin(id int, email string) error {
+	_, err := r.db.Exec(`UPDATE user_identities SET last_login_at = ?, email = ? WHERE id = ?`, time.Now(), email, id)
+	return err
+}
+
+// DeleteIdentity unlinks one of the user's identities. The user ID makes sure users can only unlink their own.
+func (r *IdentityRepository) DeleteIdentity(id, userID int) error {
+	result, err := r.db.Exec(`DELETE FROM user_identities WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CreateState stores a login in progress under the hash of its state parameter. Expired states are cleaned up on the way.
+func (r *IdentityRepository) CreateState(stateHash string, state model.OIDCState) error {
+	var userID interface{}
+	if state.UserID != 0 {
+		userID = state.UserID
+	}
+	if _, err := r.db.Exec(`DELETE FROM oidc_states WHERE expires_at <= ?`, time.Now()); err != nil {
+		return err
+	}
+	_, err := r.db.Exec(`INSERT INTO oidc_states (state_hash, provider, nonce, code_verifier, user_id, remember_me, invite_code, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		stateHash, state.Provider, state.Nonce, state.CodeVerifier, userID, state.RememberMe, state.InviteCode, state.ExpiresAt)
+	return err
+}
+
+// ConsumeState removes the state and returns it, so every login can only be finished once.
+// Unknown and expired states return sql.ErrNoRows.
+func (r *IdentityRepository) ConsumeState(stateHash string) (model.OIDCState, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return model.OIDCState{}, err
+	}
+	defer tx.Rollback()
+
+	var state model.OIDCState
+	var userID sql.NullInt64
+	err = tx.QueryRow(`SELECT id, provider, nonce, code_verifier, user_id, remember_me, invite_code, expires_at FROM oidc_states WHERE state_hash = ?`, stateHash).Scan(
+		&state.Id, &state.Provider, &state.Nonce, &state.CodeVerifier, &userID, &state.RememberMe, &state.InviteCode, &state.ExpiresAt)
+	if err != nil {
+		return model.OIDCState{}, err
+	}
+	if _, err := tx.Exec(`DELETE FROM oidc_states WHERE id = ?`, state.Id); err != nil {
+		return model.OIDCState{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.OIDCState{}, err
+	}
+	if time.Now().After(state.ExpiresAt) {
+		return model.OIDCState{}, sql.ErrNoRows
+	}
+	state.UserID = int(userID.Int64)
+	return state, nil
+}
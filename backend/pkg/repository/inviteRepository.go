@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"backend/pkg/model"
+	"database/sql"
+	"time"
+)
+
+type InviteRepository struct {
+	db *sql.DB
+}
+
+func NewInviteRepository(db *sql.DB) *InviteRepository {
+	return &InviteRepository{db: db}
+}
+
+func (r *InviteRepository) CreateInvite(invite model.InviteCode) (int64, error) {
+	result, err := r.db.Exec(`INSERT INTO invite_codes (code, creator_id, max_uses, expires_at) VALUES (?, ?, ?, ?)`,
+		invite.Code, invite.CreatorID, invite.MaxUses, invite.ExpiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetInvitesByCreatorID returns the codes of a user, newest first.
+func (r *InviteRepository) GetInvitesByCreatorID(creatorID int) ([]model.InviteCode, error) {
+	return r.getInvites(`SELECT id, code, creator_id, max_uses, uses, expires_at, created_at FROM invite_codes WHERE creator_id = ? ORDER BY created_at DESC, id DESC`, creatorID)
+}
+
+// GetAllInvites returns the codes of all users, newest first.
+func (r *InviteRepository) GetAllInvites() ([]model.InviteCode, error) {
+	return r.getInvites(`SELECT id, code, creator_id, max_uses, uses, expires_at, created_at FROM invite_codes ORDER BY created_at DESC, id DESC`)
+}
+
+func (r *InviteRepository) getInvites(query string, args ...interface{}) ([]model.InviteCode, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invites []model.InviteCode
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, invite)
+	}
+	return invites, rows.Err()
+}
+
+func scanInvite(row interface{ Scan(...interface{}) error }) (model.InviteCode, error) {
+	var invite model.InviteCode
+	var expiresAt sql.NullTime
+	err := row.Scan(&invite.Id, &invite.Code, &invite.CreatorID, &invite.MaxUses, &invite.Uses, &expiresAt, &invite.CreatedAt)
+	if err != nil {
+		return model.InviteCode{}, err
+	}
+	if expiresAt.Valid {
+		invite.ExpiresAt = &expiresAt.Time
+	}
+	return invite, nil
+}
+
+func (r *InviteRepository) GetInviteByID(id int) (model.InviteCode, error) {
+	row := r.db.QueryRow(`SELECT id, code, creator_id, max_uses, uses, expires_at, created_at FROM invite_codes WHERE id = ?`, id)
+	return scanInvite(row)
+}
+
+// CountActiveInvitesByCreatorID counts the codes of a user that can still be used.
+func (r *InviteRepository) CountActiveInvitesByCreatorID(creatorID int) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM invite_codes WHERE creator_id = ? AND (max_uses = 0 OR uses < max_uses) AND (expires_at IS NULL OR expires_at > ?)`,
+		creatorID, time.Now()).Scan(&count)
+	return count, err
+}
+
+// IsInviteUsable checks whether a code exists and has uses left, without using it.
+func (r *InviteRepository) IsInviteUsable(code string) (bool, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM invite_codes WHERE code = ? AND (max_uses = 0 OR uses < max_uses) AND (expires_at IS NULL OR expires_at > ?)`,
+		code, time.Now()).Scan(&count)
+	return count > 0, err
+}
+
+// UseInvite counts a signup on the code and returns it. The check and the count happen in one statement,
+// so concurrent signups can't use a code more often than allowed. Unknown, used up and expired codes
+// return sql.ErrNoRows.
+func (r *InviteRepository) UseInvite(code string) (model.InviteCode, error) {
+	result, err := r.db.Exec(`UPDATE invite_codes SET uses = uses + 1 WHERE code = ? AND (max_uses = 0 OR uses < max_uses) AND (expires_at IS NULL OR expires_at > ?)`,
+		code, time.Now())
+	if err != nil {
+		return model.InviteCode{}, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return model.InviteCode{}, err
+	}
+	if rowsAffected == 0 {
+		return model.InviteCode{}, sql.ErrNoRows
+	}
+	row := r.db.QueryRow(`SELECT id, code, creator_id, max_uses, uses, expires_at, created_at FROM invite_codes WHERE code = ?`, code)
+	return scanInvite(row)
+}
+
+// ReleaseInvite gives back a use of the code when the signup failed after UseInvite.
+func (r *InviteRepository) ReleaseInvite(id int) error {
+	_, err := r.db.Exec(`UPDATE invite_codes SET uses = uses - 1 WHERE id = ? AND uses > 0`, id)
+	return err
+}
+
+// SetInviter records who invited the user and with which code.
+func (r *InviteRepository) SetInviter(userID int, invite model.InviteCode) error {
+	_, err := r.db.Exec(`UPDATE users SET invited_by = ?, invite_code_id = ? WHERE id = ?`, invite.CreatorID, invite.Id, userID)
+	return err
+}
+
+// GetInviteesByInviterID returns the users who signed up with the codes of the inviter, newest first.
+func (r *InviteRepository) GetInviteesByInviterID(inviterID int) ([]model.Invitee, error) {
+	rows, err := r.db.Query(`SELECT id, username, avatar_url, invite_code_id, created_at FROM users WHERE invited_by = ? ORDER BY created_at DESC, id DESC`, inviterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invitees []model.Invitee
+	for rows.Next() {
+		var invitee model.Invitee
+		var avatarURL sql.NullString
+		var inviteCodeID sql.NullInt64
+		if err := rows.Scan(&invitee.Id, &invitee.Username, &avatarURL, &inviteCodeID, &invitee.CreatedAt); err != nil {
+			return nil, err
+		}
+		invitee.AvatarURL = avatarURL.String
+		invitee.InviteCodeID = int(inviteCodeID.Int64)
+		invitees = append(invitees, invitee)
+	}
+	return invitees, rows.Err()
+}
+
+// DeleteInvite removes a code. Users who signed up with it keep their inviter.
+func (r *InviteRepository) DeleteInvite(id int) error {
+	_, err := r.db.Exec(`DELETE FROM invite_codes WHERE id = ?`, id)
+	return err
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"backend/pkg/model"
+	"database/sql"
+	"time"
+)
+
+type LoginThrottleRepository struct {
+	db *sql.DB
+}
+
+func NewLoginThrottleRepository(db *sql.DB) *LoginThrottleRepository {
+	return &LoginThrottleRepository{db: db}
+}
+
+// GetThrottle returns the failed login counter, a zero counter if there were no failures.
+func (r *LoginThrottleRepository) GetThrottle(scope, key string) (model.LoginThrottle, error) {
+	throttle := model.LoginThrottle{Scope: scope, Key: key}
+	var lastFailureAt, lockedUntil sql.NullTime
+	err := r.db.QueryRow(`SELECT failures, last_failure_at, locked_until FROM login_throttles WHERE scope = ? AND key = ?`, scope, key).Scan(
+		&throttle.Failures, &lastFailureAt, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return throttle, nil
+	} else if err != nil {
+		return model.LoginThrottle{}, err
+	}
+	throttle.LastFailureAt = lastFailureAt.Time
+	if lockedUntil.Valid {
+		throttle.LockedUntil = &lockedUntil.Time
+	}
+	return throttle, nil
+}
+
+// AddFailure counts a failed login at the time and returns the failures counted so far. Failures before resetBefore
+// are forgotten. Reading and writing the counter is one statement, so parallel failures are all counted.
+func (r *LoginThrottleRepository) AddFailure(scope, key string, at, resetBefore time.Time) (int, error) {
+	var failures int
+	err := r.db.QueryRow(`INSERT INTO login_throttles (scope, key, failures, last_failure_at) VALUES (?, ?, 1, ?)
+	ON CONFLICT(scope, key) DO UPDATE SET
+		failures = CASE WHEN login_throttles.last_failure_at > ? THEN login_throttles.failures ELSE 0 END + 1,
+		last_failure_at = excluded.last_failure_at
+	RETURNING failures`, scope, key, at, resetBefore).Scan(&failures)
+	return failures, err
+}
+
+// Lock locks the account or IP address until the time. A lockout that lasts longer already is kept.
+func (r *LoginThrottleRepository) Lock(scope, key string, until time.Time) error {
+	_, err := r.db.Exec(`UPDATE login_throttles SET locked_until = ? WHERE scope = ? AND key = ? AND (locked_until IS NULL OR locked_until < ?)`,
+		until, scope, key, until)
+	return err
+}
+
+// DeleteThrottle forgets the failed logins and lifts the lockout. It returns false if there was nothing to delete.
+func (r *LoginThrottleRepository) DeleteThrottle(scope, key string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM login_throttles WHERE scope = ? AND key = ?`, scope, key)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// GetActiveLockouts returns all accounts and IP addresses that are locked right now.
+func (r *LoginThrottleRepository) GetActiveLockouts() ([]model.LoginThrottle, error) {
+	rows, err := r.db.Query(`SELECT scope, key, failures, last_failure_at, locked_until FROM login_throttles
+	WHERE locked_until > ? ORDER BY locked_until DESC`, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lockouts := []model.LoginThrottle{}
+	for rows.Next() {
+		var throttle model.LoginThrottle
+		var lastFailureAt sql.NullTime
+		var lockedUntil time.Time
+		if err := rows.Scan(&throttle.Scope, &throttle.Key, &throttle.Failures, &lastFailureAt, &lockedUntil); err != nil {
+			return nil, err
+		}
+		throttle.LastFailureAt = lastFailureAt.Time
+		throttle.LockedUntil = &lockedUntil
+		lockouts = append(lockouts, throttle)
+	}
+	return lockouts, rows.Err()
+}
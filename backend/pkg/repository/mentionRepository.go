@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"backend/pkg/model"
+	"database/sql"
+	"regexp"
+	"strings"
+)
+
+// mention finds the @usernames of a text: an @ at the start or after a character that can't be part of a username,
+// so email addresses are left out. Dots and dashes at the end belong to the sentence, not the username.
+var mention = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.@-])@([\p{L}\p{N}_.-]+)`)
+
+// parseMentions returns the distinct usernames mentioned in the texts, without the @, in the order they are used first.
+func parseMentions(texts ...string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		for _, match := range mention.FindAllStringSubmatch(text, -1) {
+			username := strings.TrimRight(match[1], ".-")
+			if username != "" && !seen[strings.ToLower(username)] {
+				seen[strings.ToLower(username)] = true
+				usernames = append(usernames, username)
+			}
+		}
+	}
+	return usernames
+}
+
+type MentionRepository struct {
+	db *sql.DB
+}
+
+func NewMentionRepository(db *sql.DB) *MentionRepository {
+	return &MentionRepository{db: db}
+}
+
+// SetMentions replaces the mentions of a post, comment or chat message with the users mentioned in its texts
+// and returns the users who weren't mentioned in it before, to notify them. Usernames are matched ignoring case,
+// an exact match wins. Mentions of unknown users are left out.
+func (r *MentionRepository) SetMentions(itemType string, itemID int, texts ...string) ([]model.Mention, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	mentioned := make(map[int]bool)
+	rows, err := tx.Query(`SELECT user_id FROM mentions WHERE item_type = ? AND item_id = ?`, itemType, itemID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		mentioned[userID] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM mentions WHERE item_type = ? AND item_id = ?`, itemType, itemID); err != nil {
+		return nil, err
+	}
+
+	var added []model.Mention
+	for _, username := range parseMentions(texts...) {
+		var userID int
+		err := tx.QueryRow(`SELECT id FROM users WHERE username = ? COLLATE NOCASE ORDER BY username = ? DESC LIMIT 1`,
+			username, username).Scan(&userID)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		result, err := tx.Exec(`INSERT INTO mentions (item_type, item_id, user_id, username) VALUES (?, ?, ?, ?)
+			ON CONFLICT (item_type, item_id, user_id) DO NOTHING`, itemType, itemID, userID, username)
+		if err != nil {
+			return nil, err
+		}
+		// Two spellings of the same username are one mention
+		if inserted, err := result.RowsAffected(); err != nil {
+			return nil, err
+		} else if inserted > 0 && !mentioned[userID] {
+			added = append(added, model.Mention{UserID: userID, Username: username})
+		}
+	}
+	return added, tx.Commit()
+}
+
+// GetMentions returns the mentions of several items of one type with one query, in the order they were written.
+// Items without mentions are left out of the map.
+func (r *MentionRepository) GetMentions(itemType string, itemIDs []int) (map[int][]model.Mention, error) {
+	in, args := inClause(itemIDs)
+	query := `SELECT item_id, user_id, username FROM mentions WHERE item_type = ? AND item_id IN ` + in + ` ORDER BY item_id, rowid`
+	rows, err := r.db.Query(query, append([]interface{}{itemType}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := make(map[int][]model.Mention, len(itemIDs))
+	for rows.Next() {
+		var itemID int
+		var mention model.Mention
+		if err := rows.Scan(&itemID, &mention.UserID, &mention.Username); err != nil {
+			return nil, err
+		}
+		mentions[itemID] = append(mentions[itemID], mention)
+	}
+	return mentions, rows.Err()
+}
+
+// GetMentionSuggestions returns the users whose username or names start with the prefix, for the autocomplete
+// of mentions. Friends come first, then members of the groups of the user, then everyone else,
+// and username matches before name matches. The user is left out.
+func (r *MentionRepository) GetMentionSuggestions(userID int, prefix string, limit int) ([]model.UserList, error) {
+	like := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+	query := `SELECT users.id, users.username, users.first_name, users.last_name, COALESCE(users.avatar_url, '') FROM users
+    WHERE users.id != ? AND (users.username LIKE ? ESCAPE '\' OR users.first_name LIKE ? ESCAPE '\' OR users.last_name LIKE ? ESCAPE '\')
+    ORDER BY
+        CASE
+            WHEN users.id IN (
+                SELECT user_id1 FROM friends WHERE user_id2 = ? AND status = 'accepted'
+                UNION
+                SELECT user_id2 FROM friends WHERE user_id1 = ? AND status = 'accepted'
+            ) THEN 0
+            WHEN users.id IN (
+                SELECT group_members.user_id FROM group_members WHERE group_members.group_id IN ` + memberGroupsCondition + `
+                UNION
+                SELECT groups.creator_id FROM groups WHERE groups.id IN ` + memberGroupsCondition + `
+            ) THEN 1
+            ELSE 2
+        END,
+        users.username NOT LIKE ? ESCAPE '\',
+        users.username COLLATE NOCASE
+    LIMIT ?`
+	rows, err := r.db.Query(query, userID, like, like, like, userID, userID, userID, userID, userID, userID, like, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []model.UserList
+	for rows.Next() {
+		var user model.UserList
+		if err := rows.Scan(&user.Id, &user.Username, &user.FirstName, &user.LastName, &user.AvatarURL); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
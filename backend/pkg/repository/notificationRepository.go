@@ -3,6 +3,7 @@ package repository
 import (
 	"backend/pkg/model"
 	"database/sql"
+	"strings"
 )
 
 // NotificationRepository handles database operations related to notifications.
@@ -15,8 +16,19 @@ func NewNotificationRepository(db *sql.DB) *NotificationRepository {
 	return &NotificationRepository{db: db}
 }
 
+// notificationColumns are the columns of a notification in the order of notificationFields,
+// the optional IDs are 0 when they are not set.
+const notificationColumns = `id, user_id, COALESCE(group_id, 0), COALESCE(sender_id, 0), type, message, is_read, created_at,
+	COALESCE(post_id, 0), COALESCE(comment_id, 0)`
+
+// notificationFields returns the scan destinations of notificationColumns.
+func notificationFields(notification *model.Notification) []interface{} {
+	return []interface{}{&notification.Id, &notification.UserId, &notification.GroupId, &notification.SenderId, &notification.Type,
+		&notification.Message, &notification.IsRead, &notification.CreatedAt, &notification.PostId, &notification.CommentId}
+}
+
 func (r *NotificationRepository) GetNotificationsByUserId(id int) ([]model.Notification, error) {
-	query := `SELECT * FROM notifications WHERE user_id = ?`
+	query := `SELECT ` + notificationColumns + ` FROM notifications WHERE user_id = ?`
 	rows, err := r.db.Query(query, id)
 	if err != nil {
 		return nil, err
@@ -26,25 +38,9 @@ func (r *NotificationRepository) GetNotificationsByUserId(id int) ([]model.Notif
 	var notifications []model.Notification
 	for rows.Next() {
 		var notification model.Notification
-		var groupId, senderId sql.NullInt64 // Use sql.NullInt64 for nullable integers
-
-		// Scan the row with sql.NullInt64 variables for nullable columns
-		if err := rows.Scan(&notification.Id, &notification.UserId, &groupId, &senderId, &notification.Type, &notification.Message, &notification.IsRead, &notification.CreatedAt); err != nil {
+		if err := rows.Scan(notificationFields(&notification)...); err != nil {
 			return nil, err
 		}
-
-		// Check if groupId is valid, then assign its value to the Notification struct
-		if groupId.Valid {
-			val := int(groupId.Int64) // Convert sql.NullInt64 to int
-			notification.GroupId = val
-		}
-
-		// Check if senderId is valid, then assign its value to the Notification struct
-		if senderId.Valid {
-			val := int(senderId.Int64) // Convert sql.NullInt64 to int
-			notification.SenderId = val
-		}
-
 		notifications = append(notifications, notification)
 	}
 	if err := rows.Err(); err != nil {
@@ -53,29 +49,27 @@ func (r *NotificationRepository) GetNotificationsByUserId(id int) ([]model.Notif
 	return notifications, nil
 }
 
-// CreateNotification adds a new notification to the database.
+// CreateNotification adds a new notification to the database. Group, sender, post and comment IDs of 0 are left empty.
 func (r *NotificationRepository) CreateNotification(notification model.Notification) (int64, error) {
-	baseQuery := `INSERT ` + `INTO notifications (user_id, type, message`
-	valuesQuery := `VALUES (?, ?, ?`
+	columns := []string{"user_id", "type", "message"}
 	args := []interface{}{notification.UserId, notification.Type, notification.Message}
-	if notification.GroupId != 0 && notification.SenderId != 0 {
-		baseQuery += `, group_id, sender_id) `
-		valuesQuery += `, ?, ?)`
-		args = append(args, notification.GroupId, notification.SenderId)
-	} else if notification.GroupId != 0 {
-		baseQuery += `, group_id) `
-		valuesQuery += `, ?)`
-		args = append(args, notification.GroupId)
-	} else if notification.SenderId != 0 {
-		baseQuery += `, sender_id) `
-		valuesQuery += `, ?)`
-		args = append(args, notification.SenderId)
-	} else {
-		baseQuery += `)`
-		valuesQuery += `)`
+	optional := []struct {
+		column string
+		id     int
+	}{
+		{"group_id", notification.GroupId},
+		{"sender_id", notification.SenderId},
+		{"post_id", notification.PostId},
+		{"comment_id", notification.CommentId},
+	}
+	for _, field := range optional {
+		if field.id != 0 {
+			columns = append(columns, field.column)
+			args = append(args, field.id)
+		}
 	}
 
-	query := baseQuery + valuesQuery
+	query := `INSERT INTO notifications (` + strings.Join(columns, ", ") + `) VALUES (?` + strings.Repeat(", ?", len(columns)-1) + `)`
 
 	result, err := r.db.Exec(query, args...)
 	if err != nil {
@@ -102,10 +96,10 @@ func (r *NotificationRepository) EditGroupNotificationMessage(userID, groupID in
 
 // GetNotificationByID retrieves a specific notification by its ID from the database.
 func (r *NotificationRepository) GetNotificationByID(id int) (model.Notification, error) {
-	query := `SELECT * FROM notifications WHERE id = ?`
+	query := `SELECT ` + notificationColumns + ` FROM notifications WHERE id = ?`
 	row := r.db.QueryRow(query, id)
 	var notification model.Notification
-	err := row.Scan(&notification.Id, &notification.Type, &notification.Message, &notification.IsRead)
+	err := row.Scan(notificationFields(&notification)...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return model.Notification{}, nil
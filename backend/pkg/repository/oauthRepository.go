@@ -0,0 +1,271 @@
+package repository
+
+import (
+	"backend/pkg/model"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type OAuthRepository struct {
+	db *sql.DB
+}
+
+func NewOAuthRepository(db *sql.DB) *OAuthRepository {
+	return &OAuthRepository{db: db}
+}
+
+func (r *OAuthRepository) CreateClient(client model.OAuthClient) (int64, error) {
+	var secretHash interface{}
+	if client.ClientSecretHash != "" {
+		secretHash = client.ClientSecretHash
+	}
+	result, err := r.db.Exec(`INSERT INTO oauth_clients (client_id, client_secret_hash, name, redirect_uris, owner_id) VALUES (?, ?, ?, ?, ?)`,
+		client.ClientID, secretHash, client.Name, strings.Join(client.RedirectURIs, " "), client.OwnerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetClientByClientID returns the client with the public client ID or sql.ErrNoRows.
+func (r *OAuthRepository) GetClientByClientID(clientID string) (model.OAuthClient, error) {
+	row := r.db.QueryRow(`SELECT id, client_id, client_secret_hash, name, redirect_uris, owner_id, created_at FROM oauth_clients WHERE client_id = ?`, clientID)
+	return scanOAuthClient(row)
+}
+
+func (r *OAuthRepository) GetClientByID(id int) (model.OAuthClient, error) {
+	row := r.db.QueryRow(`SELECT id, client_id, client_secret_hash, name, redirect_uris, owner_id, created_at FROM oauth_clients WHERE id = ?`, id)
+	return scanOAuthClient(row)
+}
+
+func (r *OAuthRepository) GetClientsByOwnerID(ownerID int) ([]model.OAuthClient, error) {
+	rows, err := r.db.Query(`SELECT id, client_id, client_secret_hash, name, redirect_uris, owner_id, created_at FROM oauth_clients WHERE owner_id = ? ORDER BY created_at`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clients []model.OAuthClient
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+	return clients, rows.Err()
+}
+
+func scanOAuthClient(row interface{ Scan(...interface{}) error }) (model.OAuthClient, error) {
+	var client model.OAuthClient
+	var secretHash sql.NullString
+	var redirectURIs string
+	err := row.Scan(&client.Id, &client.ClientID, &secretHash, &client.Name, &redirectURIs, &client.OwnerID, &client.CreatedAt)
+	if err != nil {
+		return model.OAuthClient{}, err
+	}
+	client.ClientSecretHash = secretHash.String
+	client.Confidential = secretHash.Valid
+	client.RedirectURIs = strings.Fields(redirectURIs)
+	return client, nil
+}
+
+// DeleteClient removes a client of the owner together with its codes and tokens,
+// returning sql.ErrNoRows if the owner has no client with this ID.
+func (r *OAuthRepository) DeleteClient(id, ownerID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM oauth_clients WHERE id = ? AND owner_id = ?`, id, ownerID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec(`DELETE FROM oauth_codes WHERE client_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM oauth_tokens WHERE client_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateCode stores an authorization code under its hash. Expired codes are cleaned up on the way.
+func (r *OAuthRepository) CreateCode(codeHash string, code model.OAuthCode) error {
+	if _, err := r.db.Exec(`DELETE FROM oauth_codes WHERE expires_at <= ?`, time.Now()); err != nil {
+		return err
+	}
+	_, err := r.db.Exec(`INSERT INTO oauth_codes (code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		codeHash, code.ClientID, code.UserID, code.RedirectURI, strings.Join(code.Scopes, " "), code.CodeChallenge, code.ExpiresAt)
+	return err
+}
+
+// ConsumeCode removes the code and returns it, so every code can only be exchanged once.
+// Unknown and expired codes return sql.ErrNoRows.
+func (r *OAuthRepository) ConsumeCode(codeHash string) (model.OAuthCode, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return model.OAuthCode{}, err
+	}
+	defer tx.Rollback()
+
+	var code model.OAuthCode
+	var scopes string
+	err = tx.QueryRow(`SELECT id, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at FROM oauth_codes WHERE code_hash = ?`, codeHash).Scan(
+		&code.Id, &code.ClientID, &code.UserID, &code.RedirectURI, &scopes, &code.CodeChallenge, &code.ExpiresAt)
+	if err != nil {
+		return model.OAuthCode{}, err
+	}
+	if _, err := tx.Exec(`DELETE FROM oauth_codes WHERE id = ?`, code.Id); err != nil {
+		return model.OAuthCode{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.OAuthCode{}, err
+	}
+	if time.Now().After(code.ExpiresAt) {
+		return model.OAuthCode{}, sql.ErrNoRows
+	}
+	code.Scopes = strings.Fields(scopes)
+	return code, nil
+}
+
+// CreateToken stores a token pair. Tokens whose refresh token expired are cleaned up on the way.
+func (r *OAuthRepository) CreateToken(token model.OAuthToken) (int64, error) {
+	if _, err := r.db.Exec(`DELETE FROM oauth_tokens WHERE refresh_expires_at <= ?`, time.Now()); err != nil {
+		return 0, err
+	}
+	return insertOAuthToken(r.db, token)
+}
+
+func insertOAuthToken(db interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, token model.OAuthToken) (int64, error) {
+	result, err := db.Exec(`INSERT INTO oauth_tokens (client_id, user_id, access_hash, refresh_hash, scopes, access_expires_at, refresh_expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		token.ClientID, token.UserID, token.AccessHash, token.RefreshHash, strings.Join(token.Scopes, " "), token.AccessExpiresAt, token.RefreshExpiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetTokenByAccessHash returns the token pair with the access token hash or sql.ErrNoRows.
+func (r *OAuthRepository) GetTokenByAccessHash(accessHash string) (model.OAuthToken, error) {
+	return r.getToken(`access_hash = ?`, accessHash)
+}
+
+// GetTokenByRefreshHash returns the token pair with the refresh token hash or sql.ErrNoRows.
+func (r *OAuthRepository) GetTokenByRefreshHash(refreshHash string) (model.OAuthToken, error) {
+	return r.getToken(`refresh_hash = ?`, refreshHash)
+}
+
+func (r *OAuthRepository) getToken(condition, hash string) (model.OAuthToken, error) {
+	var token model.OAuthToken
+	var scopes string
+	err := r.db.QueryRow(`SELECT id, client_id, user_id, access_hash, refresh_hash, scopes, access_expires_at, refresh_expires_at FROM oauth_tokens WHERE `+condition, hash).Scan(
+		&token.Id, &token.ClientID, &token.UserID, &token.AccessHash, &token.RefreshHash, &scopes, &token.AccessExpiresAt, &token.RefreshExpiresAt)
+	if err != nil {
+		return model.OAuthToken{}, err
+	}
+	token.Scopes = strings.Fields(scopes)
+	return token, nil
+}
+
+// RotateToken replaces a token pair with a new one, so the old refresh token can't be used again.
+// It returns sql.ErrNoRows if the old pair was already replaced by a concurrent refresh.
+func (r *OAuthRepository) RotateToken(oldID int, token model.OAuthToken) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM oauth_tokens WHERE id = ?`, oldID)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if rowsAffected == 0 {
+		return 0, sql.ErrNoRows
+	}
+	id, err := insertOAuthToken(tx, token)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func (r *OAuthRepository) DeleteToken(id int) error {
+	_, err := r.db.Exec(`DELETE FROM oauth_tokens WHERE id = ?`, id)
+	return err
+}
+
+// GetAuthorizationsByUserID lists the clients holding tokens of the user, with the scopes of all their tokens.
+func (r *OAuthRepository) GetAuthorizationsByUserID(userID int) ([]model.OAuthAuthorization, error) {
+	rows, err := r.db.Query(`SELECT c.client_id, c.name, t.scopes, t.created_at FROM oauth_tokens t
+	JOIN oauth_clients c ON c.id = t.client_id
+	WHERE t.user_id = ? AND t.refresh_expires_at > ? ORDER BY t.created_at`, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var authorizations []model.OAuthAuthorization
+	index := make(map[string]int)
+	for rows.Next() {
+		var authorization model.OAuthAuthorization
+		var scopes string
+		if err := rows.Scan(&authorization.ClientID, &authorization.Name, &scopes, &authorization.CreatedAt); err != nil {
+			return nil, err
+		}
+		i, ok := index[authorization.ClientID]
+		if !ok {
+			index[authorization.ClientID] = len(authorizations)
+			authorizations = append(authorizations, authorization)
+			i = len(authorizations) - 1
+		}
+		for _, scope := range strings.Fields(scopes) {
+			if !containsString(authorizations[i].Scopes, scope) {
+				authorizations[i].Scopes = append(authorizations[i].Scopes, scope)
+			}
+		}
+	}
+	return authorizations, rows.Err()
+}
+
+// DeleteAuthorization revokes all tokens the client holds for the user, returning sql.ErrNoRows if there are none.
+func (r *OAuthRepository) DeleteAuthorization(userID int, clientID string) error {
+	result, err := r.db.Exec(`DELETE FROM oauth_tokens WHERE user_id = ? AND client_id = (SELECT id FROM oauth_clients WHERE client_id = ?)`, userID, clientID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"backend/pkg/model"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type PasskeyRepository struct {
+	db *sql.DB
+}
+
+func NewPasskeyRepository(db *sql.DB) *PasskeyRepository {
+	return &PasskeyRepository{db: db}
+}
+
+func (r *PasskeyRepository) CreatePasskey(passkey model.Passkey) (int64, error) {
+	result, err := r.db.Exec(`INSERT INTO webauthn_credentials (user_id, credential_id, public_key, sign_count, transports, name) VALUES (?, ?, ?, ?, ?, ?)`,
+		passkey.UserID, passkey.CredentialID, passkey.PublicKey, passkey.SignCount, strings.Join(passkey.Transports, ","), passkey.Name)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetPasskeyByCredentialID returns the passkey with the base64url encoded credential ID or sql.ErrNoRows.
+func (r *PasskeyRepository) GetPasskeyByCredentialID(credentialID string) (model.Passkey, error) {
+	row := r.db.QueryRow(`SELECT id, user_id, credential_id, public_key, sign_count, transports, name, created_at, last_used_at
+	FROM webauthn_credentials WHERE credential_id = ?`, credentialID)
+	return scanPasskey(row)
+}
+
+func (r *PasskeyRepository) GetPasskeysByUserID(userID int) ([]model.Passkey, error) {
+	rows, err := r.db.Query(`SELECT id, user_id, credential_id, public_key, sign_count, transports, name, created_at, last_used_at
+	FROM webauthn_credentials WHERE user_id = ? ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var passkeys []model.Passkey
+	for rows.Next() {
+		passkey, err := scanPasskey(rows)
+		if err != nil {
+			return nil, err
+		}
+		passkeys = append(passkeys, passkey)
+	}
+	return passkeys, rows.Err()
+}
+
+func scanPasskey(row interface{ Scan(...interface{}) error }) (model.Passkey, error) {
+	var passkey model.Passkey
+	var transports string
+	var lastUsedAt sql.NullTime
+	err := row.Scan(&passkey.Id, &passkey.UserID, &passkey.CredentialID, &passkey.PublicKey, &passkey.SignCount,
+		&transports, &passkey.Name, &passkey.CreatedAt, &lastUsedAt)
+	if err != nil {
+		return model.Passkey{}, err
+	}
+	passkey.Transports = []string{}
+	if transports != "" {
+		passkey.Transports = strings.Split(transports, ",")
+	}
+	if lastUsedAt.Valid {
+		passkey.LastUsedAt = &lastUsedAt.Time
+	}
+	return passkey, nil
+}
+
+// UpdatePasskeyUsage stores the signature counter of the last login with the passkey.
+func (r *PasskeyRepository) UpdatePasskeyUsage(id int, signCount uint32) error {
+	_, err := r.db.Exec(`UPDATE webauthn_credentials SET sign_count = ?, last_used_at = ? WHERE id = ?`, signCount, time.Now(), id)
+	return err
+}
+
+// DeletePasskey removes a passkey of the user, returning sql.ErrNoRows if the user has no passkey with this ID.
+func (r *PasskeyRepository) DeletePasskey(id, userID int) error {
+	result, err := r.db.Exec(`DELETE FROM webauthn_credentials WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CreateChallenge stores the challenge of a registration or login in progress. Expired challenges are cleaned up on the way.
+func (r *PasskeyRepository) CreateChallenge(challenge model.WebAuthnChallenge, purpose string) error {
+	var userID interface{}
+	if challenge.UserID != 0 {
+		userID = challenge.UserID
+	}
+	if _, err := r.db.Exec(`DELETE FROM webauthn_challenges WHERE expires_at <= ?`, time.Now()); err != nil {
+		return err
+	}
+	_, err := r.db.Exec(`INSERT INTO webauthn_challenges (challenge, user_id, purpose, expires_at) VALUES (?, ?, ?, ?)`,
+		challenge.Challenge, userID, purpose, challenge.ExpiresAt)
+	return err
+}
+
+// ConsumeChallenge removes the challenge and returns it, so every challenge can only be answered once.
+// Unknown and expired challenges return sql.ErrNoRows.
+func (r *PasskeyRepository) ConsumeChallenge(challenge, purpose string) (model.WebAuthnChallenge, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return model.WebAuthnChallenge{}, err
+	}
+	defer tx.Rollback()
+
+	var result model.WebAuthnChallenge
+	var userID sql.NullInt64
+	err = tx.QueryRow(`SELECT id, challenge, user_id, expires_at FROM webauthn_challenges WHERE challenge = ? AND purpose = ?`, challenge, purpose).Scan(
+		&result.Id, &result.Challenge, &userID, &result.ExpiresAt)
+	if err != nil {
+		return model.WebAuthnChallenge{}, err
+	}
+	if _, err := tx.Exec(`DELETE FROM webauthn_challenges WHERE id = ?`, result.Id); err != nil {
+		return model.WebAuthnChallenge{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.WebAuthnChallenge{}, err
+	}
+	if time.Now().After(result.ExpiresAt) {
+		return model.WebAuthnChallenge{}, sql.ErrNoRows
+	}
+	result.UserID = int(userID.Int64)
+	return result, nil
+}
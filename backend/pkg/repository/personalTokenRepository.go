@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"backend/pkg/model"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// lastUsedPrecision limits how often the last used time of a token is written, scripts may send many requests
+const lastUsedPrecision = time.Minute
+
+type PersonalTokenRepository struct {
+	db *sql.DB
+}
+
+func NewPersonalTokenRepository(db *sql.DB) *PersonalTokenRepository {
+	return &PersonalTokenRepository{db: db}
+}
+
+func (r *PersonalTokenRepository) CreateToken(token model.PersonalToken) (int64, error) {
+	result, err := r.db.Exec(`INSERT INTO personal_tokens (user_id, name, token_hash, scopes, expires_at) VALUES (?, ?, ?, ?, ?)`,
+		token.UserID, token.Name, token.TokenHash, strings.Join(token.Scopes, " "), token.ExpiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetTokenByHash returns the token with the hash or sql.ErrNoRows.
+func (r *PersonalTokenRepository) GetTokenByHash(tokenHash string) (model.PersonalToken, error) {
+	row := r.db.QueryRow(`SELECT id, user_id, name, scopes, expires_at, last_used_at, created_at FROM personal_tokens WHERE token_hash = ?`, tokenHash)
+	return scanPersonalToken(row)
+}
+
+func (r *PersonalTokenRepository) GetTokensByUserID(userID int) ([]model.PersonalToken, error) {
+	rows, err := r.db.Query(`SELECT id, user_id, name, scopes, expires_at, last_used_at, created_at FROM personal_tokens WHERE user_id = ? ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []model.PersonalToken
+	for rows.Next() {
+		token, err := scanPersonalToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (r *PersonalTokenRepository) CountTokensByUserID(userID int) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM personal_tokens WHERE user_id = ?`, userID).Scan(&count)
+	return count, err
+}
+
+func scanPersonalToken(row interface{ Scan(...interface{}) error }) (model.PersonalToken, error) {
+	var token model.PersonalToken
+	var scopes string
+	var expiresAt, lastUsedAt sql.NullTime
+	err := row.Scan(&token.Id, &token.UserID, &token.Name, &scopes, &expiresAt, &lastUsedAt, &token.CreatedAt)
+	if err != nil {
+		return model.PersonalToken{}, err
+	}
+	token.Scopes = strings.Fields(scopes)
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	return token, nil
+}
+
+// TouchToken records that the token was just used. The time is only written once per minute.
+func (r *PersonalTokenRepository) TouchToken(id int) error {
+	now := time.Now()
+	_, err := r.db.Exec(`UPDATE personal_tokens SET last_used_at = ? WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)`,
+		now, id, now.Add(-lastUsedPrecision))
+	return err
+}
+
+// DeleteToken revokes one of the user's tokens, returning sql.ErrNoRows if the user has no token with this ID.
+func (r *PersonalTokenRepository) DeleteToken(id, userID int) error {
+	result, err := r.db.Exec(`DELETE FROM personal_tokens WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
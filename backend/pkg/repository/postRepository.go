@@ -15,10 +15,18 @@ func NewPostRepository(db *sql.DB) *PostRepository {
 	return &PostRepository{db: db}
 }
 
+// postColumns are the columns of a post in the order of postFields.
+const postColumns = `posts.id, posts.user_id, posts.group_id, posts.title, posts.content, posts.image_url, posts.privacy_setting, posts.created_at, posts.updated_at`
+
+// postFields returns the scan destinations of postColumns.
+func postFields(post *model.Post) []interface{} {
+	return []interface{}{&post.Id, &post.UserID, &post.GroupID, &post.Title, &post.Content, &post.ImageURL, &post.PrivacySetting, &post.CreatedAt, &post.UpdatedAt}
+}
+
 func (r *PostRepository) GetPostByID(postID int) (model.Post, error) {
-	query := `SELECT * FROM posts WHERE id = ?`
+	query := `SELECT ` + postColumns + ` FROM posts WHERE id = ?`
 	var post model.Post
-	err := r.db.QueryRow(query, postID).Scan(&post.Id, &post.UserID, &post.GroupID, &post.Title, &post.Content, &post.ImageURL, &post.PrivacySetting, &post.CreatedAt, &post.UpdatedAt)
+	err := r.db.QueryRow(query, postID).Scan(postFields(&post)...)
 	if err != nil {
 		return model.Post{}, err
 	}
@@ -26,9 +34,15 @@ func (r *PostRepository) GetPostByID(postID int) (model.Post, error) {
 }
 
 func (r *PostRepository) CreatePost(post *model.CreatePostRequest, userID int) (*model.CreatePostRequest, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	query := `INSERT INTO posts (user_id, title, group_id, content, privacy_setting) 
 	VALUES (?, ?, ?, ?, ?)`
-	result, err := r.db.Exec(query, userID, post.Title, post.GroupID, post.Content, post.PrivacySetting)
+	result, err := tx.Exec(query, userID, post.Title, post.GroupID, post.Content, post.PrivacySetting)
 	if err != nil {
 		fmt.Println("Error inserting post into database: ", err)
 		return nil, err
@@ -38,6 +52,15 @@ func (r *PostRepository) CreatePost(post *model.CreatePostRequest, userID int) (
 	if err != nil {
 		fmt.Println("Error getting last inserted post id")
 	}
+	if err := setPostAudience(tx, post.PostID, post.Audience, post.AudienceLists); err != nil {
+		return nil, err
+	}
+	if err := setPostTags(tx, post.PostID, post.Title, post.Content); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
 	post.ImageURL = os.Getenv("NEXT_PUBLIC_URL")+ ":" + os.Getenv("NEXT_PUBLIC_BACKEND_PORT") + "/images/posts/" + fmt.Sprint(post.PostID) + ".jpg"
 	query = `UPDATE posts SET image_url = ? WHERE id = ?`
@@ -54,26 +77,53 @@ func (r *PostRepository) CreatePost(post *model.CreatePostRequest, userID int) (
 	return post, nil
 }
 
-// GetAllPostsWithUserIDAccess retrieves all posts with the given user ID access.
-// It queries the database to fetch posts that meet the following conditions:
+// postAccessCondition selects the posts a user can see, it needs the user ID five times:
 // - Posts with the specified user ID
 // - Posts with privacy setting set to 'public'
 // - Posts with privacy setting set to 'private' and the user is a friend (status = 'accepted')
-// The function returns a slice of model.Post and an error if any occurred during the query.
-func (r *PostRepository) GetAllPostsWithUserIDAccess(userID int) ([]model.Post, error) {
-	query := `
-    SELECT posts.* 
-    FROM posts 
-    WHERE posts.user_id = ? 
-    OR posts.privacy_setting = 'public' 
+// - Posts with privacy setting set to 'custom' and the user is in the audience of the post,
+//   directly or as current member of one of its audience lists
+const postAccessCondition = `(posts.user_id = ?
+    OR posts.privacy_setting = 'public'
     OR (posts.privacy_setting = 'private' AND posts.user_id IN (
         SELECT user_id1 FROM friends WHERE user_id2 = ? AND status = 'accepted'
         UNION
         SELECT user_id2 FROM friends WHERE user_id1 = ? AND status = 'accepted'
     ))
-    `
+    OR (posts.privacy_setting = 'custom' AND posts.id IN (
+        SELECT post_id FROM post_audience WHERE user_id = ?
+        UNION
+        SELECT post_audience_lists.post_id FROM post_audience_lists
+        JOIN audience_list_members ON audience_list_members.list_id = post_audience_lists.list_id
+        WHERE audience_list_members.user_id = ?
+    )))`
+
+// postPageTimeFormat is how SQLite stores CURRENT_TIMESTAMP, the cursor is compared with created_at in it.
+const postPageTimeFormat = "2006-01-02 15:04:05"
+
+// pageClause returns the end of a feed query selecting the page: the posts after the cursor, newest first
+// ordered by (created_at, id). It asks for one post more than the limit, to tell whether there is a next page.
+func pageClause(page model.PostPage) (string, []interface{}) {
+	clause := ` ORDER BY posts.created_at DESC, posts.id DESC LIMIT ?`
+	if page.After == nil {
+		return clause, []interface{}{page.Limit + 1}
+	}
+	createdAt := page.After.CreatedAt.UTC().Format(postPageTimeFormat)
+	return ` AND (posts.created_at < ? OR (posts.created_at = ? AND posts.id < ?))` + clause,
+		[]interface{}{createdAt, createdAt, page.After.Id, page.Limit + 1}
+}
+
+// GetAllPostsWithUserIDAccess retrieves a page of the posts with the given user ID access, see postAccessCondition.
+// The function returns a slice of model.Post and an error if any occurred during the query.
+func (r *PostRepository) GetAllPostsWithUserIDAccess(userID int, page model.PostPage) ([]model.Post, error) {
+	clause, pageArgs := pageClause(page)
+	query := `
+    SELECT ` + postColumns + `
+    FROM posts 
+    WHERE ` + postAccessCondition + clause
 
-	rows, err := r.db.Query(query, userID, userID, userID)
+	args := append([]interface{}{userID, userID, userID, userID, userID}, pageArgs...)
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return []model.Post{}, err
 	}
@@ -82,7 +132,7 @@ func (r *PostRepository) GetAllPostsWithUserIDAccess(userID int) ([]model.Post,
 	var posts []model.Post
 	for rows.Next() {
 		var post model.Post
-		if err := rows.Scan(&post.Id, &post.UserID, &post.GroupID, &post.Title, &post.Content, &post.ImageURL, &post.PrivacySetting, &post.CreatedAt, &post.UpdatedAt); err != nil {
+		if err := rows.Scan(postFields(&post)...); err != nil {
 			return nil, err
 		}
 		posts = append(posts, post)
@@ -94,9 +144,11 @@ func (r *PostRepository) GetAllPostsWithUserIDAccess(userID int) ([]model.Post,
 	return posts, nil
 }
 
-func (r *PostRepository) GetAllUserPosts(userID int) ([]model.Post, error) {
-	query := `SELECT * FROM posts WHERE user_id = ? AND group_id IS 0`
-	rows, err := r.db.Query(query, userID)
+// GetAllUserPosts retrieves a page of the posts of a user outside of groups.
+func (r *PostRepository) GetAllUserPosts(userID int, page model.PostPage) ([]model.Post, error) {
+	clause, pageArgs := pageClause(page)
+	query := `SELECT ` + postColumns + ` FROM posts WHERE user_id = ? AND group_id IS 0` + clause
+	rows, err := r.db.Query(query, append([]interface{}{userID}, pageArgs...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -105,7 +157,7 @@ func (r *PostRepository) GetAllUserPosts(userID int) ([]model.Post, error) {
 	var posts []model.Post
 	for rows.Next() {
 		var post model.Post
-		if err := rows.Scan(&post.Id, &post.UserID, &post.GroupID, &post.Title, &post.Content, &post.ImageURL, &post.PrivacySetting, &post.CreatedAt, &post.UpdatedAt); err != nil {
+		if err := rows.Scan(postFields(&post)...); err != nil {
 			return nil, err
 		}
 		posts = append(posts, post)
@@ -116,8 +168,106 @@ func (r *PostRepository) GetAllUserPosts(userID int) ([]model.Post, error) {
 	return posts, nil
 }
 
+// GetUserPostsWithUserIDAccess retrieves a page of the posts of a user outside of groups that the viewer can see.
+func (r *PostRepository) GetUserPostsWithUserIDAccess(userID, viewerID int, page model.PostPage) ([]model.Post, error) {
+	clause, pageArgs := pageClause(page)
+	query := `SELECT ` + postColumns + ` FROM posts WHERE posts.user_id = ? AND (posts.group_id IS NULL OR posts.group_id = 0) AND ` + postAccessCondition + clause
+	args := append([]interface{}{userID, viewerID, viewerID, viewerID, viewerID, viewerID}, pageArgs...)
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []model.Post
+	for rows.Next() {
+		var post model.Post
+		if err := rows.Scan(postFields(&post)...); err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// CanUserSeePost checks whether the user can see the post and its comments. Group posts are visible to
+// the members of the group, other posts follow postAccessCondition. Unknown posts return false.
+func (r *PostRepository) CanUserSeePost(postID, userID int) (bool, error) {
+	query := `SELECT COUNT(*) FROM posts WHERE posts.id = ? AND (
+        (posts.group_id IS NOT NULL AND posts.group_id != 0 AND (
+            posts.group_id IN (SELECT group_id FROM group_members WHERE user_id = ?)
+            OR posts.group_id IN (SELECT id FROM groups WHERE creator_id = ?)
+        ))
+        OR ((posts.group_id IS NULL OR posts.group_id = 0) AND ` + postAccessCondition + `))`
+	var count int
+	err := r.db.QueryRow(query, postID, userID, userID, userID, userID, userID, userID, userID).Scan(&count)
+	return count > 0, err
+}
+
+// GetPostAudience returns the IDs of the users who can see a post with the 'custom' privacy setting.
+func (r *PostRepository) GetPostAudience(postID int) ([]int, error) {
+	rows, err := r.db.Query(`SELECT user_id FROM post_audience WHERE post_id = ? ORDER BY user_id`, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	audience := []int{}
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		audience = append(audience, userID)
+	}
+	return audience, rows.Err()
+}
+
+// GetPostAudienceLists returns the IDs of the audience lists of a post with the 'custom' privacy setting.
+func (r *PostRepository) GetPostAudienceLists(postID int) ([]int, error) {
+	rows, err := r.db.Query(`SELECT list_id FROM post_audience_lists WHERE post_id = ? ORDER BY list_id`, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []int{}
+	for rows.Next() {
+		var listID int
+		if err := rows.Scan(&listID); err != nil {
+			return nil, err
+		}
+		lists = append(lists, listID)
+	}
+	return lists, rows.Err()
+}
+
+// setPostAudience replaces the audience users and audience lists of a post, an empty audience removes it.
+func setPostAudience(tx *sql.Tx, postID int, audience []int, lists []int) error {
+	if _, err := tx.Exec(`DELETE FROM post_audience WHERE post_id = ?`, postID); err != nil {
+		return err
+	}
+	for _, userID := range audience {
+		if _, err := tx.Exec(`INSERT INTO post_audience (post_id, user_id) VALUES (?, ?)`, postID, userID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM post_audience_lists WHERE post_id = ?`, postID); err != nil {
+		return err
+	}
+	for _, listID := range lists {
+		if _, err := tx.Exec(`INSERT INTO post_audience_lists (post_id, list_id) VALUES (?, ?)`, postID, listID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *PostRepository) GetAllUserPublicPosts(userID int) ([]model.Post, error) {
-	query := `SELECT * FROM posts WHERE user_id = ? AND privacy_setting = 'public' AND group_id IS NULL`
+	query := `SELECT ` + postColumns + ` FROM posts WHERE user_id = ? AND privacy_setting = 'public' AND group_id IS NULL`
 	rows, err := r.db.Query(query, userID)
 	if err != nil {
 		return nil, err
@@ -127,7 +277,7 @@ func (r *PostRepository) GetAllUserPublicPosts(userID int) ([]model.Post, error)
 	var posts []model.Post
 	for rows.Next() {
 		var post model.Post
-		if err := rows.Scan(&post.Id, &post.UserID, &post.GroupID, &post.Title, &post.Content, &post.ImageURL, &post.PrivacySetting, &post.CreatedAt, &post.UpdatedAt); err != nil {
+		if err := rows.Scan(postFields(&post)...); err != nil {
 			return nil, err
 		}
 		posts = append(posts, post)
@@ -139,8 +289,14 @@ func (r *PostRepository) GetAllUserPublicPosts(userID int) ([]model.Post, error)
 }
 
 func (r *PostRepository) DeletePost(postID int, userID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `DELETE FROM posts WHERE id = ? AND user_id = ?`
-	result, err := r.db.Exec(query, postID, userID)
+	result, err := tx.Exec(query, postID, userID)
 	if err != nil {
 		return err
 	}
@@ -152,13 +308,34 @@ func (r *PostRepository) DeletePost(postID int, userID int) error {
 	if rowsAffected == 0 {
 		return fmt.Errorf("no post found with the specified id that belongs to the user")
 	}
-	return nil
+	if err := setPostAudience(tx, postID, nil, nil); err != nil {
+		return err
+	}
+	if err := setPostTags(tx, postID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM mentions WHERE item_type = 'post' AND item_id = ?`, postID); err != nil {
+		return err
+	}
+	if err := deleteReactions(tx, model.ReactionItemPost, "= ?", postID); err != nil {
+		return err
+	}
+	if err := deletePostComments(tx, postID); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (r *PostRepository) UpdatePost(postID int, userID int, request model.UpdatePostRequest) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `UPDATE posts SET title = ?, content = ?, image_url = ?, privacy_setting = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?`
 
-	result, err := r.db.Exec(query, request.Title, request.Content, request.ImageURL, request.PrivacySetting, postID, userID)
+	result, err := tx.Exec(query, request.Title, request.Content, request.ImageURL, request.PrivacySetting, postID, userID)
 	if err != nil {
 		return err // Handle the error appropriately
 	}
@@ -172,12 +349,59 @@ func (r *PostRepository) UpdatePost(postID int, userID int, request model.Update
 		return fmt.Errorf("no post found with the specified id that belongs to the user or no update was needed")
 	}
 
-	return nil
+	// The audience only applies to custom posts, other privacy settings remove it
+	if err := setPostAudience(tx, postID, request.Audience, request.AudienceLists); err != nil {
+		return err
+	}
+	if err := setPostTags(tx, postID, request.Title, request.Content); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *PostRepository) GetPostsByGroupID(groupID int) ([]model.Post, error) {
-	query := `SELECT id, user_id, title, content, image_url, created_at FROM posts WHERE group_id = ?`
-	rows, err := r.db.Query(query, groupID)
+// GetPostsByGroupID retrieves a page of the posts of a group.
+func (r *PostRepository) GetPostsByGroupID(groupID int, page model.PostPage) ([]model.Post, error) {
+	clause, pageArgs := pageClause(page)
+	query := `SELECT ` + postColumns + ` FROM posts WHERE group_id = ?` + clause
+	rows, err := r.db.Query(query, append([]interface{}{groupID}, pageArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []model.Post
+	for rows.Next() {
+		var post model.Post
+		if err := rows.Scan(postFields(&post)...); err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// GetPostsByTag retrieves a page of the posts the user can find with a hashtag, see discoverablePostCondition:
+// the posts with the tag in their title or content or in one of their comments.
+func (r *PostRepository) GetPostsByTag(tag string, userID int, page model.PostPage) ([]model.Post, error) {
+	clause, pageArgs := pageClause(page)
+	query := `SELECT ` + postColumns + ` FROM posts
+    WHERE posts.id IN (
+        SELECT post_tags.post_id FROM post_tags JOIN tags ON tags.id = post_tags.tag_id WHERE tags.name = ?
+        UNION
+        SELECT comments.post_id FROM comment_tags
+        JOIN tags ON tags.id = comment_tags.tag_id
+        JOIN comments ON comments.id = comment_tags.comment_id
+        WHERE tags.name = ?
+    ) AND ` + discoverablePostCondition + clause
+
+	args := []interface{}{tag, tag}
+	for i := 0; i < 7; i++ {
+		args = append(args, userID)
+	}
+	rows, err := r.db.Query(query, append(args, pageArgs...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -186,7 +410,7 @@ func (r *PostRepository) GetPostsByGroupID(groupID int) ([]model.Post, error) {
 	var posts []model.Post
 	for rows.Next() {
 		var post model.Post
-		if err := rows.Scan(&post.Id, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.CreatedAt); err != nil {
+		if err := rows.Scan(postFields(&post)...); err != nil {
 			return nil, err
 		}
 		posts = append(posts, post)
@@ -199,7 +423,7 @@ func (r *PostRepository) GetPostsByGroupID(groupID int) ([]model.Post, error) {
 
 func (r *PostRepository) GetPostsByUserGroups(userID int) ([]model.Post, error) {
 	query := `
-    SELECT posts.* 
+    SELECT ` + postColumns + `
     FROM posts 
     JOIN group_members ON posts.group_id = group_members.group_id
     WHERE group_members.user_id = ?
@@ -214,7 +438,7 @@ func (r *PostRepository) GetPostsByUserGroups(userID int) ([]model.Post, error)
 	var posts []model.Post
 	for rows.Next() {
 		var post model.Post
-		if err := rows.Scan(&post.Id, &post.UserID, &post.GroupID, &post.Title, &post.Content, &post.ImageURL, &post.PrivacySetting, &post.CreatedAt, &post.UpdatedAt); err != nil {
+		if err := rows.Scan(postFields(&post)...); err != nil {
 			return nil, err
 		}
 		posts = append(posts, post)
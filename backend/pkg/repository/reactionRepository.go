@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"backend/pkg/model"
+	"database/sql"
+	"fmt"
+)
+
+// reactionTables are the tables of the items users can react to.
+var reactionTables = map[string]string{
+	model.ReactionItemPost:    "posts",
+	model.ReactionItemComment: "comments",
+	model.ReactionItemMessage: "chats",
+}
+
+// deleteReactions removes the reactions of items and their counters in the transaction deleting the items.
+// items selects the item IDs, "= ?" for one item or "IN (SELECT ...)" for several.
+func deleteReactions(tx *sql.Tx, itemType, items string, args ...interface{}) error {
+	args = append([]interface{}{itemType}, args...)
+	for _, table := range []string{"reactions", "reaction_counts"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE item_type = ? AND item_id `+items, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type ReactionRepository struct {
+	db *sql.DB
+}
+
+func NewReactionRepository(db *sql.DB) *ReactionRepository {
+	return &ReactionRepository{db: db}
+}
+
+// React gives a post, comment or chat message the reaction of the user and returns the reaction the user has now.
+// Reacting the same way again removes the reaction, reacting another way replaces it. The reaction and the counters
+// of the item change in one transaction. Unknown items return sql.ErrNoRows.
+func (r *ReactionRepository) React(itemType string, itemID, userID int, reaction string) (string, error) {
+	table, ok := reactionTables[itemType]
+	if !ok {
+		return "", fmt.Errorf("unknown reaction item %q", itemType)
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(fmt.Sprintf("SELECT 1 FROM %s WHERE id = ?", table), itemID).Scan(&exists); err != nil {
+		return "", err
+	}
+
+	var current string
+	err = tx.QueryRow(`SELECT reaction FROM reactions WHERE item_type = ? AND item_id = ? AND user_id = ?`,
+		itemType, itemID, userID).Scan(&current)
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = tx.Exec(`INSERT INTO reactions (user_id, item_type, item_id, reaction) VALUES (?, ?, ?, ?)`,
+			userID, itemType, itemID, reaction)
+	case err != nil:
+		return "", err
+	case current == reaction:
+		reaction = ""
+		_, err = tx.Exec(`DELETE FROM reactions WHERE item_type = ? AND item_id = ? AND user_id = ?`, itemType, itemID, userID)
+	default:
+		_, err = tx.Exec(`UPDATE reactions SET reaction = ?, created_at = CURRENT_TIMESTAMP WHERE item_type = ? AND item_id = ? AND user_id = ?`,
+			reaction, itemType, itemID, userID)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if current != "" {
+		if _, err := tx.Exec(`UPDATE reaction_counts SET count = count - 1 WHERE item_type = ? AND item_id = ? AND reaction = ?`,
+			itemType, itemID, current); err != nil {
+			return "", err
+		}
+		if _, err := tx.Exec(`DELETE FROM reaction_counts WHERE item_type = ? AND item_id = ? AND count <= 0`, itemType, itemID); err != nil {
+			return "", err
+		}
+	}
+	if reaction != "" {
+		if _, err := tx.Exec(`INSERT INTO reaction_counts (item_type, item_id, reaction, count) VALUES (?, ?, ?, 1)
+			ON CONFLICT (item_type, item_id, reaction) DO UPDATE SET count = count + 1`, itemType, itemID, reaction); err != nil {
+			return "", err
+		}
+	}
+	return reaction, tx.Commit()
+}
+
+// GetReactionCounts returns the reaction counters of several items of one type with one query.
+// Items without reactions are left out of the map.
+func (r *ReactionRepository) GetReactionCounts(itemType string, itemIDs []int) (map[int]map[string]int, error) {
+	in, args := inClause(itemIDs)
+	query := `SELECT item_id, reaction, count FROM reaction_counts WHERE item_type = ? AND count > 0 AND item_id IN ` + in
+	rows, err := r.db.Query(query, append([]interface{}{itemType}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]map[string]int, len(itemIDs))
+	for rows.Next() {
+		var itemID, count int
+		var reaction string
+		if err := rows.Scan(&itemID, &reaction, &count); err != nil {
+			return nil, err
+		}
+		if counts[itemID] == nil {
+			counts[itemID] = make(map[string]int)
+		}
+		counts[itemID][reaction] = count
+	}
+	return counts, rows.Err()
+}
+
+// GetUserReactions returns the reactions of the user to several items of one type with one query.
+// Items the user didn't react to are left out of the map.
+func (r *ReactionRepository) GetUserReactions(itemType string, itemIDs []int, userID int) (map[int]string, error) {
+	in, args := inClause(itemIDs)
+	query := `SELECT item_id, reaction FROM reactions WHERE item_type = ? AND user_id = ? AND item_id IN ` + in
+	rows, err := r.db.Query(query, append([]interface{}{itemType, userID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reactions := make(map[int]string, len(itemIDs))
+	for rows.Next() {
+		var itemID int
+		var reaction string
+		if err := rows.Scan(&itemID, &reaction); err != nil {
+			return nil, err
+		}
+		reactions[itemID] = reaction
+	}
+	return reactions, rows.Err()
+}
+
+// RepairReactionCounts recomputes the reaction counters of all items from the reactions
+// and returns how many items had wrong counters.
+func (r *ReactionRepository) RepairReactionCounts() (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	const actual = `SELECT item_type, item_id, reaction, COUNT(*) FROM reactions GROUP BY item_type, item_id, reaction`
+	const stored = `SELECT item_type, item_id, reaction, count FROM reaction_counts WHERE count > 0`
+	var repaired int64
+	err = tx.QueryRow(`SELECT COUNT(*) FROM (
+		SELECT item_type, item_id FROM (` + actual + ` EXCEPT ` + stored + `)
+		UNION
+		SELECT item_type, item_id FROM (` + stored + ` EXCEPT ` + actual + `)
+	)`).Scan(&repaired)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`DELETE FROM reaction_counts`); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`INSERT INTO reaction_counts (item_type, item_id, reaction, count) ` + actual); err != nil {
+		return 0, err
+	}
+	return repaired, tx.Commit()
+}
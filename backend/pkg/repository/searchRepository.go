@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"backend/pkg/model"
+	"database/sql"
+	"strings"
+)
+
+type SearchRepository struct {
+	db *sql.DB
+}
+
+func NewSearchRepository(db *sql.DB) *SearchRepository {
+	return &SearchRepository{db: db}
+}
+
+// searchSnippet marks the matches in about 16 tokens of the best matching column of the FTS5 table of a table.
+func searchSnippet(table string) string {
+	return `snippet(` + table + `_fts, -1, '<mark>', '</mark>', '…', 16)`
+}
+
+// memberGroupsCondition selects the groups the user is a member or the creator of, it needs the user ID twice.
+const memberGroupsCondition = `(SELECT group_id FROM group_members WHERE user_id = ? UNION SELECT id FROM groups WHERE creator_id = ?)`
+
+// discoverablePostCondition selects the posts the user can find in search results and on tag pages: the posts
+// of postAccessCondition that are not in a group or in a group of the user. It needs the user ID seven times.
+const discoverablePostCondition = postAccessCondition + ` AND (COALESCE(posts.group_id, 0) = 0 OR posts.group_id IN ` + memberGroupsCondition + `)`
+
+// profileAccessCondition selects the users whose whole profile the user can see: public profiles, the own one and
+// the ones of friends. It needs the user ID three times. Only the names of the other users are searched.
+const profileAccessCondition = `(users.id = ? OR users.profile = 'public' OR users.id IN (
+        SELECT user_id1 FROM friends WHERE user_id2 = ? AND status = 'accepted'
+        UNION
+        SELECT user_id2 FROM friends WHERE user_id1 = ? AND status = 'accepted'
+    ))`
+
+// searchSources are the queries of the result types, each selecting type, id, title, snippet, post ID, group ID and score,
+// with the arguments after the match: how often they need the user ID.
+var searchSources = map[string]struct {
+	query   string
+	userIDs int
+}{
+	model.SearchPost: {`SELECT 'post', posts.id, posts.title, ` + searchSnippet("posts") + `,
+        0, COALESCE(posts.group_id, 0), -bm25(posts_fts, 2.0, 1.0)
+        FROM posts_fts JOIN posts ON posts.id = posts_fts.rowid
+        WHERE posts_fts MATCH ? AND ` + discoverablePostCondition, 7},
+	model.SearchComment: {`SELECT 'comment', comments.id, posts.title, ` + searchSnippet("comments") + `,
+        comments.post_id, COALESCE(posts.group_id, 0), -bm25(comments_fts)
+        FROM comments_fts JOIN comments ON comments.id = comments_fts.rowid JOIN posts ON posts.id = comments.post_id
+        WHERE comments_fts MATCH ? AND ` + discoverablePostCondition, 7},
+	model.SearchUser: {`SELECT 'user', users.id, users.username, ` + searchSnippet("users") + `,
+        0, 0, -bm25(users_fts, 3.0, 2.0, 2.0, 1.0)
+        FROM users_fts JOIN users ON users.id = users_fts.rowid
+        WHERE users_fts MATCH ? AND ` + profileAccessCondition, 3},
+	model.SearchGroup: {`SELECT 'group', groups.id, groups.title, ` + searchSnippet("groups") + `,
+        0, groups.id, -bm25(groups_fts, 2.0, 1.0)
+        FROM groups_fts JOIN groups ON groups.id = groups_fts.rowid
+        WHERE groups_fts MATCH ?`, 0},
+	model.SearchEvent: {`SELECT 'event', events.id, events.title, ` + searchSnippet("events") + `,
+        0, COALESCE(events.group_id, 0), -bm25(events_fts, 2.0, 1.0, 1.0)
+        FROM events_fts JOIN events ON events.id = events_fts.rowid
+        WHERE events_fts MATCH ? AND (events.creator_id = ? OR events.group_id IN ` + memberGroupsCondition + `)`, 3},
+}
+
+// privateProfileSearch finds the users whose profile the user can't see by their names only,
+// the snippet is the full name so nothing else of the profile shows up.
+const privateProfileSearch = `SELECT 'user', users.id, users.username, users.first_name || ' ' || users.last_name,
+        0, 0, -bm25(users_fts, 3.0, 2.0, 2.0, 1.0)
+        FROM users_fts JOIN users ON users.id = users_fts.rowid
+        WHERE users_fts MATCH ? AND NOT ` + profileAccessCondition
+
+// Search returns the results of all result types in the query the user can see, most relevant first.
+// It returns one result more than the limit when there are more results.
+func (r *SearchRepository) Search(userID int, query model.SearchQuery) ([]model.SearchResult, error) {
+	types := query.Types
+	if len(types) == 0 {
+		types = []string{model.SearchPost, model.SearchComment, model.SearchUser, model.SearchGroup, model.SearchEvent}
+	}
+
+	var selects []string
+	var args []interface{}
+	addSource := func(sourceQuery, match string, userIDs int) {
+		selects = append(selects, sourceQuery)
+		args = append(args, match)
+		for i := 0; i < userIDs; i++ {
+			args = append(args, userID)
+		}
+	}
+	for _, resultType := range types {
+		source, ok := searchSources[resultType]
+		if !ok {
+			continue
+		}
+		addSource(source.query, query.Match, source.userIDs)
+		if resultType == model.SearchUser {
+			addSource(privateProfileSearch, `{username first_name last_name} : (`+query.Match+`)`, 3)
+		}
+	}
+	if len(selects) == 0 {
+		return nil, nil
+	}
+
+	rows, err := r.db.Query(`SELECT * FROM (`+strings.Join(selects, "\n    UNION ALL\n    ")+`)
+    ORDER BY 7 DESC, 1, 2 LIMIT ? OFFSET ?`, append(args, query.Limit+1, query.Offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []model.SearchResult
+	for rows.Next() {
+		var result model.SearchResult
+		var snippet sql.NullString
+		if err := rows.Scan(&result.Type, &result.Id, &result.Title, &snippet, &result.PostID, &result.GroupID, &result.Score); err != nil {
+			return nil, err
+		}
+		result.Snippet = snippet.String
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
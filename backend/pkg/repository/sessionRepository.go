@@ -4,7 +4,6 @@ import (
 	"backend/pkg/model"
 	"database/sql"
 	"fmt"
-	"log"
 	"time"
 )
 
@@ -16,23 +15,39 @@ func NewSessionRepository(db *sql.DB) *SessionRepository {
 	return &SessionRepository{db: db}
 }
 
-func (r *SessionRepository) StoreSessionInDB(sessionToken string, userID int) {
-	expiresAt := time.Now().Add(30 * time.Minute)
-	_, err := r.db.Exec(`INSERT OR REPLACE INTO sessions (sessionToken, userID, expiresAt)
-	VALUES (?, ?, ?)`, sessionToken, userID, expiresAt)
+// StoreSessionInDB creates a new session for the user. A user can hold several sessions at once,
+// one for every device they are logged in from.
+func (r *SessionRepository) StoreSessionInDB(session *model.Session) error {
+	result, err := r.db.Exec(`INSERT INTO sessions (sessionToken, userID, expiresAt, absoluteExpiresAt, rememberMe, userAgent, ipAddress, createdAt, lastSeenAt)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, session.SessionToken, session.UserID, session.ExpiresAt, session.AbsoluteExpiresAt, session.RememberMe,
+		session.UserAgent, session.IPAddress, session.CreatedAt, session.LastSeenAt)
 	if err != nil {
 		fmt.Println("Error inserting session into database: ", err)
-		log.Fatalf("Error inserting session into database: %v", err)
+		return err
 	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	session.Id = int(id)
+	return nil
 }
 
 func (r *SessionRepository) GetSessionBySessionToken(sessionToken string) (model.Session, error) {
 	var session model.Session
-	err := r.db.QueryRow(`SELECT userID, expiresAt FROM sessions WHERE sessionToken = ?`, sessionToken).Scan(&session.UserID, &session.ExpiresAt)
+	var absoluteExpiresAt sql.NullTime
+	err := r.db.QueryRow(`SELECT id, userID, expiresAt, absoluteExpiresAt, rememberMe, lastSeenAt FROM sessions WHERE sessionToken = ?`, sessionToken).Scan(
+		&session.Id, &session.UserID, &session.ExpiresAt, &absoluteExpiresAt, &session.RememberMe, &session.LastSeenAt)
 	if err != nil {
 		fmt.Println("Error querying session1: ", err)
 		return model.Session{}, err
 	}
+	session.SessionToken = sessionToken
+	// Sessions created before absolute lifetimes existed can't outlive their current expiration time
+	session.AbsoluteExpiresAt = session.ExpiresAt
+	if absoluteExpiresAt.Valid {
+		session.AbsoluteExpiresAt = absoluteExpiresAt.Time
+	}
 	return session, nil
 }
 
@@ -46,11 +61,85 @@ func (r *SessionRepository) GetUserIDFromSessionToken(sessionToken string) (int,
 	return userID, nil
 }
 
-func (r *SessionRepository) UpdateSessionExpiration(token string) error {
-	_, err := r.db.Exec(`UPDATE sessions SET expiresAt = ? WHERE sessionToken = ?`, time.Now().Add(30*time.Minute), token)
+// RenewSession moves the expiration time of the session and marks it as seen just now.
+func (r *SessionRepository) RenewSession(sessionToken string, expiresAt time.Time) error {
+	_, err := r.db.Exec(`UPDATE sessions SET expiresAt = ?, lastSeenAt = ? WHERE sessionToken = ?`, expiresAt, time.Now(), sessionToken)
 	if err != nil {
 		fmt.Println("Error updating session expiration: ", err)
 		return err
 	}
 	return nil
 }
+
+// GetActiveSessionsByUserID returns all sessions of the user that have not expired yet, most recently used first.
+func (r *SessionRepository) GetActiveSessionsByUserID(userID int) ([]model.Session, error) {
+	query := `SELECT id, sessionToken, userID, expiresAt, userAgent, ipAddress, createdAt, lastSeenAt
+	FROM sessions WHERE userID = ? AND expiresAt > ? ORDER BY lastSeenAt DESC`
+	rows, err := r.db.Query(query, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []model.Session
+	for rows.Next() {
+		var session model.Session
+		if err := rows.Scan(&session.Id, &session.SessionToken, &session.UserID, &session.ExpiresAt, &session.UserAgent, &session.IPAddress, &session.CreatedAt, &session.LastSeenAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// DeleteSessionByID revokes a single session. The user ID makes sure users can only revoke their own sessions.
+func (r *SessionRepository) DeleteSessionByID(sessionID, userID int) error {
+	result, err := r.db.Exec(`DELETE FROM sessions WHERE id = ? AND userID = ?`, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteOtherSessions revokes every session of the user except the one with the given ID.
+func (r *SessionRepository) DeleteOtherSessions(userID, keepSessionID int) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM sessions WHERE userID = ? AND id != ?`, userID, keepSessionID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteSessionByToken removes the session so the token can't be used anymore.
+func (r *SessionRepository) DeleteSessionByToken(sessionToken string) error {
+	_, err := r.db.Exec(`DELETE FROM sessions WHERE sessionToken = ?`, sessionToken)
+	return err
+}
+
+// DeleteAllSessionsByUserID ends every session of the user on every device.
+func (r *SessionRepository) DeleteAllSessionsByUserID(userID int) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM sessions WHERE userID = ?`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteExpiredSessions removes all sessions whose expiration time has passed.
+func (r *SessionRepository) DeleteExpiredSessions() (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM sessions WHERE expiresAt <= ?`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"backend/pkg/model"
+	"database/sql"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxTagLength is the longest hashtag in runes, longer ones are not tags.
+const maxTagLength = 50
+
+// hashtag finds the hashtags of a text: a # at the start or after a character that can't be part of a word,
+// so URL fragments and HTML entities like &#39; are left out.
+var hashtag = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#/])#([\p{L}\p{N}_]+)`)
+
+// NormalizeTag returns the stored name of a hashtag, lower case and without the #,
+// and false when it isn't one: tags have a letter and at most 50 letters, digits and underscores.
+func NormalizeTag(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	if tag == "" || utf8.RuneCountInString(tag) > maxTagLength {
+		return "", false
+	}
+	hasLetter := false
+	for _, r := range tag {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case !unicode.IsNumber(r) && r != '_':
+			return "", false
+		}
+	}
+	return tag, hasLetter
+}
+
+// parseTags returns the distinct hashtags of the texts in the order they are used first.
+func parseTags(texts ...string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		for _, match := range hashtag.FindAllStringSubmatch(text, -1) {
+			tag, ok := NormalizeTag(match[1])
+			if ok && !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// tagIDs returns the IDs of the tags, adding the tags used for the first time.
+func tagIDs(tx *sql.Tx, tags []string) ([]int, error) {
+	ids := make([]int, len(tags))
+	for i, tag := range tags {
+		if _, err := tx.Exec(`INSERT INTO tags (name) VALUES (?) ON CONFLICT (name) DO NOTHING`, tag); err != nil {
+			return nil, err
+		}
+		if err := tx.QueryRow(`SELECT id FROM tags WHERE name = ?`, tag).Scan(&ids[i]); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// setPostTags replaces the hashtags of a post with the ones in its title and content. Without texts it removes them.
+func setPostTags(tx *sql.Tx, postID int, texts ...string) error {
+	if _, err := tx.Exec(`DELETE FROM post_tags WHERE post_id = ?`, postID); err != nil {
+		return err
+	}
+	ids, err := tagIDs(tx, parseTags(texts...))
+	if err != nil {
+		return err
+	}
+	for _, tagID := range ids {
+		if _, err := tx.Exec(`INSERT INTO post_tags (post_id, tag_id) VALUES (?, ?)`, postID, tagID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setCommentTags replaces the hashtags of a comment with the ones in its content. Without texts it removes them.
+func setCommentTags(tx *sql.Tx, commentID int, texts ...string) error {
+	if _, err := tx.Exec(`DELETE FROM comment_tags WHERE comment_id = ?`, commentID); err != nil {
+		return err
+	}
+	ids, err := tagIDs(tx, parseTags(texts...))
+	if err != nil {
+		return err
+	}
+	for _, tagID := range ids {
+		if _, err := tx.Exec(`INSERT INTO comment_tags (comment_id, tag_id) VALUES (?, ?)`, commentID, tagID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type TagRepository struct {
+	db *sql.DB
+}
+
+func NewTagRepository(db *sql.DB) *TagRepository {
+	return &TagRepository{db: db}
+}
+
+// publicPostCondition selects the posts everyone can see, only their hashtags count for the trending tags
+// so the list doesn't tell anything about private posts and groups.
+const publicPostCondition = `posts.privacy_setting = 'public' AND COALESCE(posts.group_id, 0) = 0`
+
+// GetTrendingTags returns the hashtags used most in public posts and comments on them created since the time,
+// ranked by how many users used them and then by how often.
+func (r *TagRepository) GetTrendingTags(since time.Time, limit int) ([]model.TrendingTag, error) {
+	createdSince := since.UTC().Format(postPageTimeFormat)
+	rows, err := r.db.Query(`SELECT tags.name, COUNT(*) AS uses, COUNT(DISTINCT uses.user_id) AS users FROM (
+		SELECT post_tags.tag_id, posts.user_id FROM post_tags
+		JOIN posts ON posts.id = post_tags.post_id
+		WHERE `+publicPostCondition+` AND posts.created_at >= ?
+		UNION ALL
+		SELECT comment_tags.tag_id, comments.user_id FROM comment_tags
+		JOIN comments ON comments.id = comment_tags.comment_id
+		JOIN posts ON posts.id = comments.post_id
+		WHERE `+publicPostCondition+` AND comments.created_at >= ?
+	) AS uses JOIN tags ON tags.id = uses.tag_id
+	GROUP BY tags.id ORDER BY users DESC, uses DESC, tags.name LIMIT ?`, createdSince, createdSince, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []model.TrendingTag
+	for rows.Next() {
+		var tag model.TrendingTag
+		if err := rows.Scan(&tag.Name, &tag.Uses, &tag.Users); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// ReindexTags parses the hashtags of all posts and comments again, for posts and comments written before
+// hashtags were stored. It returns how many posts and comments have hashtags.
+func (r *TagRepository) ReindexTags() (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	tagged := 0
+	reindex := func(query string, setTags func(tx *sql.Tx, id int, texts ...string) error) error {
+		texts := make(map[int][]string)
+		rows, err := tx.Query(query)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id int
+			var first, second string
+			if err := rows.Scan(&id, &first, &second); err != nil {
+				rows.Close()
+				return err
+			}
+			texts[id] = []string{first, second}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for id, itemTexts := range texts {
+			if err := setTags(tx, id, itemTexts...); err != nil {
+				return err
+			}
+			if len(parseTags(itemTexts...)) > 0 {
+				tagged++
+			}
+		}
+		return nil
+	}
+
+	if _, err := tx.Exec(`DELETE FROM post_tags`); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`DELETE FROM comment_tags`); err != nil {
+		return 0, err
+	}
+	if err := reindex(`SELECT id, COALESCE(title, ''), COALESCE(content, '') FROM posts`, setPostTags); err != nil {
+		return 0, err
+	}
+	if err := reindex(`SELECT id, COALESCE(content, ''), '' FROM comments`, setCommentTags); err != nil {
+		return 0, err
+	}
+	// Tags no post or comment uses anymore
+	if _, err := tx.Exec(`DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM post_tags UNION SELECT tag_id FROM comment_tags)`); err != nil {
+		return 0, err
+	}
+	return tagged, tx.Commit()
+}
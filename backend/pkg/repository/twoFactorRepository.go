@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"backend/pkg/model"
+	"database/sql"
+	"time"
+)
+
+type TwoFactorRepository struct {
+	db *sql.DB
+}
+
+func NewTwoFactorRepository(db *sql.DB) *TwoFactorRepository {
+	return &TwoFactorRepository{db: db}
+}
+
+// GetTOTP returns the TOTP setup of the user or sql.ErrNoRows if there is none.
+func (r *TwoFactorRepository) GetTOTP(userID int) (model.TOTP, error) {
+	var totp model.TOTP
+	err := r.db.QueryRow(`SELECT user_id, secret, enabled_at IS NOT NULL, last_used_step FROM user_totp WHERE user_id = ?`, userID).Scan(
+		&totp.UserID, &totp.Secret, &totp.Enabled, &totp.LastUsedStep)
+	if err != nil {
+		return model.TOTP{}, err
+	}
+	return totp, nil
+}
+
+// IsTOTPEnabled reports whether the user has finished TOTP enrollment.
+func (r *TwoFactorRepository) IsTOTPEnabled(userID int) (bool, error) {
+	var enabled bool
+	err := r.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM user_totp WHERE user_id = ? AND enabled_at IS NOT NULL)`, userID).Scan(&enabled)
+	return enabled, err
+}
+
+// SavePendingTOTP stores a new secret that becomes active once the first code is verified.
+// A previous, not yet verified secret is replaced.
+func (r *TwoFactorRepository) SavePendingTOTP(userID int, secret string) error {
+	_, err := r.db.Exec(`INSERT INTO user_totp (user_id, secret) VALUES (?, ?)
+	ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, enabled_at = NULL, last_used_step = 0, created_at = CURRENT_TIMESTAMP
+	WHERE user_totp.enabled_at IS NULL`, userID, secret)
+	return err
+}
+
+// EnableTOTP turns on two-factor authentication and replaces the recovery codes of the user.
+func (r *TwoFactorRepository) EnableTOTP(userID int, step int64, codeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE user_totp SET enabled_at = ?, last_used_step = ? WHERE user_id = ?`, time.Now(), step, userID)
+	if err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DisableTOTP removes the TOTP secret and all recovery codes of the user.
+func (r *TwoFactorRepository) DisableTOTP(userID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM user_totp WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM user_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseTOTPStep records the time step of an accepted code. It returns false if a code of this or a later step
+// was used already, which stops the same code from being replayed.
+func (r *TwoFactorRepository) UseTOTPStep(userID int, step int64) (bool, error) {
+	result, err := r.db.Exec(`UPDATE user_totp SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?`, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+// UseRecoveryCode marks a recovery code as used. It returns false if the code doesn't exist or was used before.
+func (r *TwoFactorRepository) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	result, err := r.db.Exec(`UPDATE user_recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`,
+		time.Now(), userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// ReplaceRecoveryCodes throws away all recovery codes of the user and stores the new ones.
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(userID int, codeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID int, codeHashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM user_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(`INSERT INTO user_recovery_codes (user_id, code_hash) VALUES (?, ?)`, userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *TwoFactorRepository) CountUnusedRecoveryCodes(userID int) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = ? AND used_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
+
+// CreateChallenge stores a login waiting for the second factor. Older challenges of the user are removed.
+func (r *TwoFactorRepository) CreateChallenge(userID int, tokenHash string, rememberMe bool, expiresAt time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM mfa_challenges WHERE user_id = ? OR expires_at <= ?`, userID, time.Now()); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO mfa_challenges (token_hash, user_id, remember_me, expires_at) VALUES (?, ?, ?, ?)`,
+		tokenHash, userID, rememberMe, expiresAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetChallenge returns the challenge for the token hash or sql.ErrNoRows if there is none.
+func (r *TwoFactorRepository) GetChallenge(tokenHash string) (model.MFAChallenge, error) {
+	var challenge model.MFAChallenge
+	err := r.db.QueryRow(`SELECT id, user_id, remember_me, attempts, expires_at FROM mfa_challenges WHERE token_hash = ?`, tokenHash).Scan(
+		&challenge.Id, &challenge.UserID, &challenge.RememberMe, &challenge.Attempts, &challenge.ExpiresAt)
+	if err != nil {
+		return model.MFAChallenge{}, err
+	}
+	return challenge, nil
+}
+
+// IncrementChallengeAttempts counts an attempt to enter the code of a challenge and returns the attempts made so far.
+// It returns sql.ErrNoRows when all maxAttempts are used up. Counting and checking is one statement,
+// so concurrent requests with the same challenge can't get more attempts.
+func (r *TwoFactorRepository) IncrementChallengeAttempts(id, maxAttempts int) (int, error) {
+	var attempts int
+	err := r.db.QueryRow(`UPDATE mfa_challenges SET attempts = attempts + 1 WHERE id = ? AND attempts < ? RETURNING attempts`,
+		id, maxAttempts).Scan(&attempts)
+	return attempts, err
+}
+
+func (r *TwoFactorRepository) DeleteChallenge(id int) error {
+	_, err := r.db.Exec(`DELETE FROM mfa_challenges WHERE id = ?`, id)
+	return err
+}
@@ -44,11 +44,14 @@ func (r *UserRepository) GetUsernameAndAvatarByID(id int) (string, string, error
 }
 
 func (r *UserRepository) GetUserByEmailOrNickname(emailOrNickname string) (model.User, error) {
-	query := "SELECT * FROM users WHERE email = ? OR username = ? LIMIT 1"
+	query := `SELECT id, username, email, password, first_name, last_name, date_of_birth, avatar_url, about_me, profile, created_at, updated_at, role,
+	email_verified_at IS NOT NULL
+	FROM users WHERE email = ? OR username = ? LIMIT 1`
 	var user model.User
 	err := r.db.QueryRow(query, emailOrNickname, emailOrNickname).Scan(
 		&user.Id, &user.Username, &user.Email, &user.Password, &user.FirstName, &user.LastName,
-		&user.DOB, &user.AvatarURL, &user.About, &user.Profile, &user.CreatedAt, &user.UpdatedAt)
+		&user.DOB, &user.AvatarURL, &user.About, &user.Profile, &user.CreatedAt, &user.UpdatedAt, &user.Role,
+		&user.EmailVerified)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			fmt.Println("User not found in database")
@@ -58,6 +61,40 @@ func (r *UserRepository) GetUserByEmailOrNickname(emailOrNickname string) (model
 	return user, nil
 }
 
+// GetUserAccessByID returns what the auth middleware needs to know about a user: the role and whether the email is verified.
+func (r *UserRepository) GetUserAccessByID(id int) (string, bool, error) {
+	query := "SELECT role, email_verified_at IS NOT NULL FROM users WHERE id = ?"
+	var role string
+	var emailVerified bool
+	err := r.db.QueryRow(query, id).Scan(&role, &emailVerified)
+	if err != nil {
+		return "", false, err
+	}
+	return role, emailVerified, nil
+}
+
+// GetUserEmailByID returns the email address, the username and whether the address is verified.
+func (r *UserRepository) GetUserEmailByID(id int) (string, string, bool, error) {
+	query := "SELECT email, username, email_verified_at IS NOT NULL FROM users WHERE id = ?"
+	var email, username string
+	var emailVerified bool
+	err := r.db.QueryRow(query, id).Scan(&email, &username, &emailVerified)
+	if err != nil {
+		return "", "", false, err
+	}
+	return email, username, emailVerified, nil
+}
+
+func (r *UserRepository) MarkEmailVerified(id int) error {
+	_, err := r.db.Exec("UPDATE users SET email_verified_at = CURRENT_TIMESTAMP WHERE id = ? AND email_verified_at IS NULL", id)
+	return err
+}
+
+func (r *UserRepository) UpdatePassword(id int, hashedPassword string) error {
+	_, err := r.db.Exec("UPDATE users SET password = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", hashedPassword, id)
+	return err
+}
+
 func (r *UserRepository) RegisterUser(data model.RegistrationData) (int64, error) {
 	result, err := r.db.Exec("INSERT INTO users (username, email, password, first_name, last_name, date_of_birth, avatar_url, about_me) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
 		data.Username, data.Email, data.Password, data.FirstName, data.LastName, data.DOB, data.AvatarURL, data.About)
@@ -86,9 +123,53 @@ func (r *UserRepository) GetUserProfileByID(id int) (model.Profile, error) {
 	return profile, nil
 }
 
-func (r *UserRepository) UpdateUserProfile(id int, data model.RegistrationData) error {
-	_, err := r.db.Exec("UPDATE users SET username = ?, email = ?, password = ?, first_name = ?, last_name = ?, date_of_birth = ?, avatar_url = ?, about_me = ?, profile = ? WHERE id = ?",
-		data.Username, data.Email, data.Password, data.FirstName, data.LastName, data.DOB, data.AvatarURL, data.About, data.ProfileSetting, id)
+// GetUserProfilesByIDs loads the profiles of several users with one query, unknown users are left out of the map.
+func (r *UserRepository) GetUserProfilesByIDs(ids []int) (map[int]model.Profile, error) {
+	in, args := inClause(ids)
+	rows, err := r.db.Query("SELECT id, username, first_name, last_name, date_of_birth, avatar_url, about_me, profile, created_at FROM users WHERE id IN "+in, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := make(map[int]model.Profile, len(ids))
+	for rows.Next() {
+		var profile model.Profile
+		if err := rows.Scan(&profile.Id, &profile.Username, &profile.FirstName, &profile.LastName, &profile.DOB, &profile.AvatarURL, &profile.About, &profile.ProfileSetting, &profile.CreatedAt); err != nil {
+			return nil, err
+		}
+		profiles[profile.Id] = profile
+	}
+	return profiles, rows.Err()
+}
+
+// UpdateUserProfile changes the profile fields that are set in data. It never touches the email address or the password.
+func (r *UserRepository) UpdateUserProfile(id int, data model.ProfileUpdateData) error {
+	fields := []struct {
+		column string
+		value  *string
+	}{
+		{"username", data.Username},
+		{"first_name", data.FirstName},
+		{"last_name", data.LastName},
+		{"date_of_birth", data.DOB},
+		{"avatar_url", data.AvatarURL},
+		{"about_me", data.About},
+		{"profile", data.ProfileSetting},
+	}
+
+	query := "UPDATE users SET updated_at = CURRENT_TIMESTAMP"
+	var args []interface{}
+	for _, field := range fields {
+		if field.value != nil {
+			query += ", " + field.column + " = ?"
+			args = append(args, *field.value)
+		}
+	}
+	query += " WHERE id = ?"
+	args = append(args, id)
+
+	_, err := r.db.Exec(query, args...)
 	if err != nil {
 		fmt.Println("Error updating user profile in database")
 		return err
@@ -96,6 +177,28 @@ func (r *UserRepository) UpdateUserProfile(id int, data model.RegistrationData)
 	return nil
 }
 
+func (r *UserRepository) GetUserPasswordByID(id int) (string, error) {
+	var password string
+	err := r.db.QueryRow("SELECT password FROM users WHERE id = ?", id).Scan(&password)
+	if err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+// IsUsernameOrEmailTaken reports whether another user than excludeID already uses the username or email address.
+func (r *UserRepository) IsUsernameOrEmailTaken(value string, excludeID int) (bool, error) {
+	var taken bool
+	err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE (username = ? OR email = ?) AND id != ?)", value, value, excludeID).Scan(&taken)
+	return taken, err
+}
+
+// UpdateEmail changes the email address of the user, the new address has to be verified again.
+func (r *UserRepository) UpdateEmail(id int, email string) error {
+	_, err := r.db.Exec("UPDATE users SET email = ?, email_verified_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = ?", email, id)
+	return err
+}
+
 func (r *UserRepository) GetAllUsersExcludeRequestingUserAndFriends(userID int) ([]model.UserList, error) {
 	query := `
     SELECT u.id, u.username, u.first_name, u.last_name, u.avatar_url 
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+)
+
+type UserTokenRepository struct {
+	db *sql.DB
+}
+
+func NewUserTokenRepository(db *sql.DB) *UserTokenRepository {
+	return &UserTokenRepository{db: db}
+}
+
+// CreateToken stores a new token for the user. Unused tokens of the same purpose are removed,
+// so only the most recently sent link works.
+func (r *UserTokenRepository) CreateToken(userID int, purpose, tokenHash string, expiresAt time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM user_tokens WHERE user_id = ? AND purpose = ? AND used_at IS NULL`, userID, purpose)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO user_tokens (user_id, token_hash, purpose, expires_at) VALUES (?, ?, ?, ?)`,
+		userID, tokenHash, purpose, expiresAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ConsumeToken marks the token as used and returns the ID of its user.
+// Unknown, expired and already used tokens return sql.ErrNoRows.
+func (r *UserTokenRepository) ConsumeToken(purpose, tokenHash string) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id, userID int
+	err = tx.QueryRow(`SELECT id, user_id FROM user_tokens WHERE token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?`,
+		tokenHash, purpose, time.Now()).Scan(&id, &userID)
+	if err != nil {
+		return 0, err
+	}
+	result, err := tx.Exec(`UPDATE user_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL`, time.Now(), id)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if rowsAffected == 0 {
+		return 0, sql.ErrNoRows
+	}
+	return userID, tx.Commit()
+}
@@ -1,19 +1,27 @@
 package ws
 
 import (
+	"backend/pkg/config"
+	"backend/pkg/model"
 	"backend/pkg/repository"
+	"database/sql"
 	"fmt"
 	"log"
 	"time"
 )
 
 type ChatHandler struct {
-	ChatRepo    *ChatRepository
-	SessionRepo *repository.SessionRepository
+	ChatRepo         *ChatRepository
+	SessionRepo      *repository.SessionRepository
+	ReactionRepo     *repository.ReactionRepository
+	MentionRepo      *repository.MentionRepository
+	NotificationRepo *repository.NotificationRepository
+	UserRepo         *repository.UserRepository
+	Reactions        config.Reactions
 }
 
-func NewChatHandler(chatRepo *ChatRepository, sessionRepo *repository.SessionRepository) *ChatHandler {
-	return &ChatHandler{ChatRepo: chatRepo, SessionRepo: sessionRepo}
+func NewChatHandler(chatRepo *ChatRepository, sessionRepo *repository.SessionRepository, reactionRepo *repository.ReactionRepository, mentionRepo *repository.MentionRepository, notificationRepo *repository.NotificationRepository, userRepo *repository.UserRepository, reactions config.Reactions) *ChatHandler {
+	return &ChatHandler{ChatRepo: chatRepo, SessionRepo: sessionRepo, ReactionRepo: reactionRepo, MentionRepo: mentionRepo, NotificationRepo: notificationRepo, UserRepo: userRepo, Reactions: reactions}
 }
 func (h *ChatHandler) FetchChatHistory(c *Client, recipientID int, page int) {
 
@@ -23,6 +31,35 @@ func (h *ChatHandler) FetchChatHistory(c *Client, recipientID int, page int) {
 		return
 	}
 
+	// Reactions of the whole page, with one query each for the counters and the reactions of the user
+	messageIDs := make([]int, len(chatHistory))
+	for i, msg := range chatHistory {
+		messageIDs[i] = msg.MessageID
+	}
+	counts, err := h.ReactionRepo.GetReactionCounts(model.ReactionItemMessage, messageIDs)
+	if err != nil {
+		log.Printf("Error fetching message reactions: %v", err)
+		return
+	}
+	myReactions, err := h.ReactionRepo.GetUserReactions(model.ReactionItemMessage, messageIDs, c.ID)
+	if err != nil {
+		log.Printf("Error fetching message reactions: %v", err)
+		return
+	}
+	mentions, err := h.MentionRepo.GetMentions(model.MentionItemMessage, messageIDs)
+	if err != nil {
+		log.Printf("Error fetching message mentions: %v", err)
+		return
+	}
+	for i, msg := range chatHistory {
+		chatHistory[i].Reactions = h.Reactions.Filter(counts[msg.MessageID])
+		chatHistory[i].MyReaction = myReactions[msg.MessageID]
+		chatHistory[i].Mentions = mentions[msg.MessageID]
+		if chatHistory[i].Mentions == nil {
+			chatHistory[i].Mentions = []model.Mention{}
+		}
+	}
+
 	response := make(map[string]interface{})
 	response["action"] = "chat_history"
 	response["content"] = chatHistory
@@ -41,6 +78,20 @@ func (h *ChatHandler) SendMessage(messageData map[string]interface{}, c *Client)
 	messageData["timestamp"] = time.Now().Format(time.RFC3339)
 	messageData["sender"] = c.ID
 	recipientID := int(messageData["recipientID"].(float64))
+
+	// The message is stored first, so the recipient gets its ID and mentions
+	messageID, err := h.ChatRepo.StoreMessage(c.ID, recipientID, message)
+	if err != nil {
+		log.Print("Error while storing message to database")
+		return
+	}
+	mentions, err := h.mentionInMessage(messageID, c.ID, recipientID, message)
+	if err != nil {
+		log.Printf("Error while storing message mentions: %v", err)
+	}
+	messageData["id"] = messageID
+	messageData["mentions"] = mentions
+
 	for key, value := range c.Hub.Clients {
 		if key.ID == recipientID {
 			if value {
@@ -48,11 +99,81 @@ func (h *ChatHandler) SendMessage(messageData map[string]interface{}, c *Client)
 			}
 		}
 	}
+}
 
-	err := h.ChatRepo.StoreMessage(c.ID, recipientID, message)
+// mentionInMessage stores the mentions of a chat message and returns them. Only the recipient can read the message,
+// so only the recipient gets a notification when mentioned.
+func (h *ChatHandler) mentionInMessage(messageID, senderID, recipientID int, message string) ([]model.Mention, error) {
+	mentions, err := h.MentionRepo.SetMentions(model.MentionItemMessage, messageID, message)
 	if err != nil {
-		log.Print("Error while storing message to database")
+		return []model.Mention{}, err
+	}
+	if mentions == nil {
+		mentions = []model.Mention{}
+	}
+	recipientMentioned := false
+	for _, mention := range mentions {
+		recipientMentioned = recipientMentioned || mention.UserID == recipientID
+	}
+	if !recipientMentioned || recipientID == senderID {
+		return mentions, nil
+	}
+	username, err := h.UserRepo.GetUsernameByID(senderID)
+	if err != nil {
+		return mentions, err
+	}
+	_, err = h.NotificationRepo.CreateNotification(model.Notification{
+		UserId:   recipientID,
+		SenderId: senderID,
+		Type:     "mention",
+		Message:  username + " mentioned you in a chat message",
+	})
+	if err != nil {
+		return mentions, err
+	}
+	return mentions, nil
+}
+
+// ReactToMessage gives a message the reaction of the client's user, who has to be its sender or receiver.
+// Sending the reaction the user already gave removes it. Both sides of the chat get the new reactions.
+func (h *ChatHandler) ReactToMessage(messageData map[string]interface{}, c *Client) {
+	messageID, idOK := messageData["message_id"].(float64)
+	reaction, reactionOK := messageData["reaction"].(string)
+	if !idOK || !reactionOK || !h.Reactions.Allowed(reaction) {
+		log.Printf("Invalid reaction format: %v", messageData)
+		return
+	}
+	senderID, receiverID, err := h.ChatRepo.GetMessageParticipants(int(messageID))
+	if err == sql.ErrNoRows || (err == nil && c.ID != senderID && c.ID != receiverID) {
+		log.Printf("User %d reacted to message %d, which is not in one of their chats", c.ID, int(messageID))
+		return
+	} else if err != nil {
+		log.Printf("Error fetching message: %v", err)
 		return
 	}
 
+	myReaction, err := h.ReactionRepo.React(model.ReactionItemMessage, int(messageID), c.ID, reaction)
+	if err != nil {
+		log.Printf("Error while storing reaction to database: %v", err)
+		return
+	}
+	counts, err := h.ReactionRepo.GetReactionCounts(model.ReactionItemMessage, []int{int(messageID)})
+	if err != nil {
+		log.Printf("Error fetching message reactions: %v", err)
+		return
+	}
+
+	response := make(map[string]interface{})
+	response["action"] = "message_reaction"
+	response["content"] = MessageReaction{
+		MessageID: int(messageID),
+		UserID:    c.ID,
+		Reaction:  myReaction,
+		Reactions: h.Reactions.Filter(counts[int(messageID)]),
+	}
+	for key, value := range c.Hub.Clients {
+		if value && (key.ID == senderID || key.ID == receiverID) {
+			key.Conn.WriteJSON(response)
+		}
+	}
 }
@@ -48,7 +48,19 @@ func (h *ChatRepository) GetMessages(senderID, recipientID int, page int) ([]Cha
 	}
 }
 
-func (h *ChatRepository) StoreMessage(senderID, recipientID int, message string) error {
-	_, err := h.db.Exec("INSERT INTO chats (sender_id, receiver_id, message) VALUES (?, ?, ?)", senderID, recipientID, message)
-	return err
+// GetMessageParticipants returns the sender and receiver of a message, sql.ErrNoRows for unknown messages.
+func (h *ChatRepository) GetMessageParticipants(messageID int) (int, int, error) {
+	var senderID, receiverID int
+	err := h.db.QueryRow("SELECT sender_id, receiver_id FROM chats WHERE id = ?", messageID).Scan(&senderID, &receiverID)
+	return senderID, receiverID, err
+}
+
+// StoreMessage stores a chat message and returns its ID.
+func (h *ChatRepository) StoreMessage(senderID, recipientID int, message string) (int, error) {
+	result, err := h.db.Exec("INSERT INTO chats (sender_id, receiver_id, message) VALUES (?, ?, ?)", senderID, recipientID, message)
+	if err != nil {
+		return 0, err
+	}
+	messageID, err := result.LastInsertId()
+	return int(messageID), err
 }
@@ -1,6 +1,8 @@
 package ws
 
 import (
+	"backend/pkg/model"
+
 	"github.com/gorilla/websocket"
 )
 
@@ -37,9 +39,20 @@ type FetchMessage struct {
 }
 
 type ChatMessage struct {
-	MessageID  int    `json:"id"`
-	SenderID   int    `json:"sender"`
-	ReceiverID int    `json:"receiver"`
-	Message    string `json:"text"`
-	CreatedAt  string `json:"timestamp"`
+	MessageID  int             `json:"id"`
+	SenderID   int             `json:"sender"`
+	ReceiverID int             `json:"receiver"`
+	Message    string          `json:"text"`
+	CreatedAt  string          `json:"timestamp"`
+	Reactions  map[string]int  `json:"reactions"`   // number of users per reaction
+	MyReaction string          `json:"my_reaction"` // reaction of the user fetching the history, empty without a reaction
+	Mentions   []model.Mention `json:"mentions"`
+}
+
+// MessageReaction is sent to both sides of a chat when one of them reacts to a message.
+type MessageReaction struct {
+	MessageID int            `json:"message_id"`
+	UserID    int            `json:"user"`      // the user who reacted
+	Reaction  string         `json:"reaction"`  // the reaction of the user now, empty after removing it
+	Reactions map[string]int `json:"reactions"` // number of users per reaction
 }
@@ -1,7 +1,7 @@
 package ws
 
 import (
-	"backend/util"
+	"backend/pkg/auth"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -63,6 +63,8 @@ func (c *Client) readPump() {
 
 		case "send_message":
 			c.Hub.ChatHandler.SendMessage(messageData, c)
+		case "react_message":
+			c.Hub.ChatHandler.ReactToMessage(messageData, c)
 		case "fetch_chat_history":
 			// Convert the "user" value to an integer
 			userID, userOK := messageData["user"].(float64)
@@ -121,7 +123,7 @@ func (c *Client) writePump() {
 
 // ServeWs handles websocket requests from the peer.
 func (h *Hub) ServeWs(w http.ResponseWriter, r *http.Request) {
-	userID, err := h.ChatHandler.SessionRepo.GetUserIDFromSessionToken(util.GetSessionToken(r))
+	userID, err := auth.UserIDFromRequest(r)
 	if err != nil {
 		log.Println("Error confirming authentication: ", err)
 		return
@@ -2,7 +2,9 @@ package main
 
 import (
 	"backend/api"
+	"backend/pkg/config"
 	"backend/pkg/db/sqlite"
+	"backend/pkg/repository"
 	"fmt"
 	"log"
 	"net/http"
@@ -23,6 +25,27 @@ func main() {
 		log.Fatal("Failed to connect to the database:", err)
 	}
 	defer db.Close()
+
+	// "repair-reactions" recomputes the reaction counters of posts, comments and chat messages instead of starting the server
+	if len(os.Args) > 1 && os.Args[1] == "repair-reactions" {
+		repaired, err := repository.NewReactionRepository(db).RepairReactionCounts()
+		if err != nil {
+			log.Fatal("Failed to repair the reaction counters:", err)
+		}
+		fmt.Printf("Repaired the reaction counters of %d items\n", repaired)
+		return
+	}
+
+	// "reindex-tags" parses the hashtags of all posts and comments again, for the ones written before hashtags were stored
+	if len(os.Args) > 1 && os.Args[1] == "reindex-tags" {
+		tagged, err := repository.NewTagRepository(db).ReindexTags()
+		if err != nil {
+			log.Fatal("Failed to reindex the hashtags:", err)
+		}
+		fmt.Printf("Reindexed the hashtags, %d posts and comments have hashtags\n", tagged)
+		return
+	}
+
 	// ENV variables
 
 	err = godotenv.Load("../.env")
@@ -40,7 +63,7 @@ func main() {
 	}
 
 	// Start the router
-	api.Router(mux, db)
+	api.Router(mux, db, config.Load())
 
 	fmt.Println("Server is running on " + address + ":" + port)
 	http.ListenAndServe(":"+port, nil)
@@ -5,9 +5,11 @@ import (
 	"encoding/hex"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type Data interface {
@@ -78,3 +80,52 @@ func GetSessionToken(r *http.Request) string {
 	}
 	return cookie.Value
 }
+
+// trustedProxies are the reverse proxies whose X-Forwarded-For header is believed, see SetTrustedProxies.
+var trustedProxies []*net.IPNet
+
+// SetTrustedProxies sets the reverse proxies (TRUSTED_PROXIES) allowed to pass on the client address
+// in the X-Forwarded-For header. Without trusted proxies the header is ignored.
+func SetTrustedProxies(proxies []*net.IPNet) {
+	trustedProxies = proxies
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	for _, proxy := range trustedProxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetClientIP returns the IP address of the client. Behind the Caddy reverse proxy the original address
+// is passed on in the X-Forwarded-For header, which is only read when the request comes from a trusted proxy.
+// Clients can put anything into the header, so the address is the rightmost hop that isn't a trusted proxy.
+func GetClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	remote := net.ParseIP(host)
+	if remote == nil || !isTrustedProxy(remote) {
+		return host
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	client := host
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+		client = ip.String()
+		if !isTrustedProxy(ip) {
+			break
+		}
+	}
+	return client
+}
@@ -0,0 +1,65 @@
+"use client"
+
+import {Field, Form, Formik, FormikHelpers} from "formik";
+import {useRouter, useSearchParams} from "next/navigation";
+import Header from '../../../components/headers/LoginHeader'
+
+interface ResetPasswordValues {
+    password: string;
+}
+
+// Target of the link in the password reset email, the token comes from the query string
+export default function ResetPassword() {
+    const router = useRouter();
+    const token = useSearchParams().get('token') ?? '';
+
+    const handleReset = (values: ResetPasswordValues, formikHelpers: FormikHelpers<ResetPasswordValues>) => {
+        const BE_PORT = process.env.NEXT_PUBLIC_BACKEND_PORT;
+        const FE_URL = process.env.NEXT_PUBLIC_URL;
+        fetch(`${FE_URL}:${BE_PORT}/api/users/password/reset`, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify({token: token, password: values.password}),
+        })
+            .then(response => {
+                formikHelpers.setSubmitting(false);
+                if (!response.ok) {
+                    return response.json().then(data => {
+                        throw new Error(data.error);
+                    });
+                }
+                alert("Password changed, you can now log in with the new password");
+                router.push('/auth');
+            })
+            .catch(error => {
+                formikHelpers.setSubmitting(false);
+                alert(error.message);
+            });
+    };
+
+    return (
+        <div className="flex flex-col h-screen">
+            <Header />
+            <Formik initialValues={{password: ""}} onSubmit={handleReset}>
+                <Form className="flex items-center justify-center mt-10">
+                    <Field
+                        className="rounded-md p-2 border border-black w-64 mr-2 focus:outline-none"
+                        id="password"
+                        name="password"
+                        placeholder="New password"
+                        type="password"
+                        autoComplete="new-password"
+                    />
+                    <button
+                        type="submit"
+                        className="bg-green-500 hover:bg-primary text-white px-4 py-2 rounded"
+                    >
+                        Reset password
+                    </button>
+                </Form>
+            </Formik>
+        </div>
+    )
+}
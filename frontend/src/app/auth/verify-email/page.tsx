@@ -0,0 +1,50 @@
+"use client"
+
+import {useState} from "react";
+import {useRouter, useSearchParams} from "next/navigation";
+import Header from '../../../components/headers/LoginHeader'
+
+// Target of the link in the verification email, the token comes from the query string.
+// The address is only verified when the button is clicked, so mail scanners opening the link don't use up the token.
+export default function VerifyEmail() {
+    const router = useRouter();
+    const token = useSearchParams().get('token') ?? '';
+    const [submitting, setSubmitting] = useState(false);
+
+    const handleVerify = () => {
+        const BE_PORT = process.env.NEXT_PUBLIC_BACKEND_PORT;
+        const FE_URL = process.env.NEXT_PUBLIC_URL;
+        setSubmitting(true);
+        fetch(`${FE_URL}:${BE_PORT}/api/users/verify-email`, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify({token: token}),
+        })
+            .then(response => {
+                setSubmitting(false);
+                router.push(`/auth?email_verified=${response.ok}`);
+            })
+            .catch(error => {
+                setSubmitting(false);
+                alert(error.message);
+            });
+    };
+
+    return (
+        <div className="flex flex-col h-screen">
+            <Header />
+            <div className="flex items-center justify-center mt-10">
+                <button
+                    type="button"
+                    disabled={submitting}
+                    onClick={handleVerify}
+                    className="bg-green-500 hover:bg-primary text-white px-4 py-2 rounded"
+                >
+                    Verify email address
+                </button>
+            </div>
+        </div>
+    )
+}
@@ -37,6 +37,7 @@ export default function Group({
 
     const [group, setGroup] = React.useState<GroupProps | null>(null);
     const [posts, setPosts] = React.useState<PostProps[]>([]);
+    const [nextCursor, setNextCursor] = React.useState<string | null>(null);
     const [isMember, setMember] = React.useState<boolean>(true);
     const [isCreator, setCreator] = React.useState<boolean>(false);
     const [invitationSent, setInvitationSent] = React.useState<boolean>(false);
@@ -64,9 +65,11 @@ export default function Group({
         }
     }, [])
 
-    useEffect(() => {
+    // Fetch a page of posts, the next page starts after the cursor of the previous one
+    const fetchPosts = (cursor: string | null) => {
+        const query = cursor ? `?cursor=${encodeURIComponent(cursor)}` : '';
         try {
-            fetch(`${FE_URL}:${BE_PORT}/groups/${params.id}/posts`, {
+            fetch(`${FE_URL}:${BE_PORT}/groups/${params.id}/posts${query}`, {
                 method: 'GET',
                 credentials: 'include'
             })
@@ -74,13 +77,18 @@ export default function Group({
                 .then(data => {
                     if (data.message === 'User not member of group') {
                         setMember(false);
-                    } else {
-                        setPosts(data);
+                    } else if (data.posts) {
+                        setPosts(prevPosts => cursor ? [...prevPosts, ...data.posts] : data.posts);
+                        setNextCursor(data.next_cursor ?? null);
                     }
                 })
         } catch (error) {
             console.error('Error fetching posts:', error);
         }
+    }
+
+    useEffect(() => {
+        fetchPosts(null);
     }, [])
 
     useEffect(() => {
@@ -179,6 +187,9 @@ export default function Group({
                                     </div>
                                 )
                             }
+                            {isMember && nextCursor && (
+                                <button className="btn mt-2" onClick={() => fetchPosts(nextCursor)}>Load more</button>
+                            )}
                         </div>
                     </section>
 
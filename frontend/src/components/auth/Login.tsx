@@ -2,11 +2,30 @@
 
 import {Field, Form, Formik, FormikHelpers} from "formik";
 import {useRouter} from "next/navigation";
-import React from "react";
+import React, {useEffect, useState} from "react";
+
+interface OIDCProvider {
+    name: string;
+    display_name: string;
+    login_url: string;
+}
+
+// Errors the backend sends back in ?oidc_error= after a login with an identity provider
+const oidcErrors: { [code: string]: string } = {
+    provider_error: "The login at the identity provider was cancelled or failed",
+    invalid_state: "The login took too long, please try again",
+    login_failed: "The login with the identity provider failed",
+    email_required: "The identity provider didn't share your email address",
+    account_exists: "An account with this email address exists already. Log in with your password and link the provider in your settings",
+    identity_in_use: "This identity is linked to another account",
+    registration_closed: "There is no account for this identity and signing up needs an invitation code",
+    invite_invalid: "The invitation code is invalid, used up or expired",
+};
 
 interface LoginValues {
 username: string;
 password: string;
+remember_me: boolean;
 }
 
 const handleLogin = (
@@ -31,25 +50,82 @@ const handleLogin = (
                     throw new Error(text);
                 });
             }
-            formikHelpers.setSubmitting(false);
-            router.push('/');
+            return response.json().then(data => {
+                formikHelpers.setSubmitting(false);
+                if (data.two_factor_required) {
+                    handleTwoFactor(data.mfa_token, router);
+                    return;
+                }
+                router.push('/');
+            });
         })
         .catch(error => {
             formikHelpers.setSubmitting(false);
-            if (JSON.parse(error.message).error === "Incorrect login credentials.") {
+            const message = JSON.parse(error.message).error;
+            if (message === "Incorrect login credentials.") {
                 alert("Invalid username or password")
+            } else if (message) {
+                alert(message)
             }
         });
 };
 
 
+// Second login step for users with two-factor authentication, accepts a code from the authenticator app or a recovery code
+const handleTwoFactor = (mfaToken: string, router: any) => {
+    const BE_PORT = process.env.NEXT_PUBLIC_BACKEND_PORT;
+    const FE_URL = process.env.NEXT_PUBLIC_URL;
+    const code = prompt("Enter the code from your authenticator app or a recovery code");
+    if (!code) {
+        return;
+    }
+    const body = code.includes('-') ? {mfa_token: mfaToken, recovery_code: code} : {mfa_token: mfaToken, code: code};
+    fetch(`${FE_URL}:${BE_PORT}/api/users/login/2fa`, {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        body: JSON.stringify(body),
+        credentials: 'include'
+    })
+        .then(response => response.json().then(data => {
+            if (!response.ok) {
+                alert(data.error);
+                return;
+            }
+            router.push('/');
+        }));
+};
+
 const LoginForm = (({}) => {
     const router = useRouter();
+    const [providers, setProviders] = useState<OIDCProvider[]>([]);
+
+    useEffect(() => {
+        const BE_PORT = process.env.NEXT_PUBLIC_BACKEND_PORT;
+        const FE_URL = process.env.NEXT_PUBLIC_URL;
+        fetch(`${FE_URL}:${BE_PORT}/api/users/oidc/providers`)
+            .then(response => response.ok ? response.json() : [])
+            .then(data => setProviders(data))
+            .catch(() => setProviders([]));
+
+        // Logins with an identity provider come back here with the result in the query string
+        const params = new URLSearchParams(window.location.search);
+        const mfaToken = params.get('mfa_token');
+        const oidcError = params.get('oidc_error');
+        if (mfaToken) {
+            handleTwoFactor(mfaToken, router);
+        } else if (oidcError) {
+            alert(oidcErrors[oidcError] || "Login failed");
+        }
+    }, [router]);
+
     return (
         <Formik
             initialValues={{
                 username: "",
                 password: "",
+                remember_me: false,
             }}
             onSubmit={(values, formikHelpers) => handleLogin(values, formikHelpers, router)}
         >
@@ -77,12 +153,32 @@ const LoginForm = (({}) => {
                     />
                 </div>
 
+                <label className="mr-2 flex items-center text-sm">
+                    <Field
+                        className="mr-1"
+                        id="remember_me"
+                        name="remember_me"
+                        type="checkbox"
+                    />
+                    Remember me
+                </label>
+
                 <button
                     type="submit"
                     className="bg-green-500 hover:bg-primary text-white px-4 py-2 rounded"
                 >
                     Login
                 </button>
+
+                {providers.map(provider => (
+                    <a
+                        key={provider.name}
+                        href={provider.login_url}
+                        className="ml-2 bg-white hover:bg-gray-100 text-black border border-black px-4 py-2 rounded"
+                    >
+                        {provider.display_name}
+                    </a>
+                ))}
             </Form>
         </Formik>
     );
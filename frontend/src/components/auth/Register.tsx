@@ -1,6 +1,6 @@
 "use client";
 
-import React from "react";
+import React, { useEffect, useState } from "react";
 import { Formik, Field, Form, FormikHelpers, FieldProps } from "formik";
 import "../../../styles/styles.css";
 import { useRouter } from 'next/navigation';
@@ -16,6 +16,7 @@ interface RegisterValues {
     image: File | null;
     username: string;
     about: string;
+    invite_code: string;
 }
 
 
@@ -23,10 +24,14 @@ interface RegisterValues {
 const handleRegister = (
     values: RegisterValues,
     formikHelpers: FormikHelpers<RegisterValues>,
-    router: any
+    router: any,
+    mode: string
 ) => {
-    // Check if all fields are filled
+    // Check if all fields are filled, the invitation code is optional unless registration is invite-only
     for (let key in values) {
+        if (key === 'invite_code' && mode !== 'invite') {
+            continue;
+        }
         if (values[key] === '' || values[key] === null) {
             alert(`Please fill in the ${key} field.`);
             return;
@@ -65,6 +70,8 @@ const handleRegister = (
             if (error.message.startsWith("Error registering user: UNIQUE constraint failed: users.")) {
                 const fieldName = error.message.split("Error registering user: UNIQUE constraint failed: users.")[1];
                 alert(`${fieldName} already taken`);
+            } else if (error.message.includes("Registration failed: ")) {
+                alert(JSON.parse(error.message).error);
             } else {
                 alert("Invalid username or password");
             }
@@ -74,6 +81,32 @@ const handleRegister = (
 
 const RegisterForm = () => {
     const router = useRouter();
+    // open, invite or closed, see REGISTRATION_MODE in the backend
+    const [mode, setMode] = useState("open");
+    const [inviteCode, setInviteCode] = useState<string | null>(null);
+
+    useEffect(() => {
+        // Invitation links look like /register?invite_code=...
+        const code = new URLSearchParams(window.location.search).get('invite_code') || '';
+        setInviteCode(code);
+        fetch(`${process.env.NEXT_PUBLIC_URL}:${process.env.NEXT_PUBLIC_BACKEND_PORT}/api/users/registration`)
+            .then(response => response.ok ? response.json() : { mode: "open" })
+            .then(data => setMode(data.mode))
+            .catch(() => setMode("open"));
+    }, []);
+
+    if (mode === "closed") {
+        return (
+            <div style={{ backgroundColor: '#e5e7eb' }} className="flex flex-col max-w-md mx-auto mt-3 p-4">
+                <h2 className="text-5xl font-rasa font-bold mb-4 text-green-700">Register</h2>
+                <p>Registration is closed.</p>
+            </div>
+        );
+    }
+    if (inviteCode === null) {
+        return null;
+    }
+
     return (
         <Formik
             initialValues={{
@@ -85,9 +118,10 @@ const RegisterForm = () => {
                 image: null as File | null,
                 username: '',
                 about: '',
+                invite_code: inviteCode,
             }}
             onSubmit={(values, formikHelpers) =>
-                handleRegister(values, formikHelpers, router)
+                handleRegister(values, formikHelpers, router, mode)
             }
         >
             {({ setFieldValue }) => (
@@ -183,6 +217,13 @@ const RegisterForm = () => {
                             <label className="labelStyle">About Me</label>
                             <Field as="textarea" name="about" className="inputStyle" />
                         </div>
+                        {/* Invitation Code */}
+                        <div className="mb-4">
+                            <label className="labelStyle">
+                                Invitation Code{mode !== "invite" && " (optional)"}
+                            </label>
+                            <Field type="text" name="invite_code" className="inputStyle" />
+                        </div>
                         {/* Submit Button */}
                         <button type="submit" className="buttonStyle">
                             Register
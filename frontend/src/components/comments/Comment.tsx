@@ -8,8 +8,8 @@ export interface CommentProps {
     content: string;
     image?: string;
     created_at: string;
-    likes: number;
-    dislikes: number;
+    reactions: { [reaction: string]: number };
+    my_reaction?: string;
     username: string;
     profile_image?: string;
 }
@@ -21,8 +21,6 @@ const Comment: React.FC<CommentProps> = ({
     content,
     image,
     created_at,
-    likes,
-    dislikes,
     username,
     profile_image
 }) => {
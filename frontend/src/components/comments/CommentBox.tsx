@@ -24,8 +24,8 @@ const CommentBox: React.FC<CommentBoxProps> = ({ comments }: CommentBoxProps) =>
                                 content={comment.content}
                                 image={comment.image}
                                 created_at={comment.created_at}
-                                likes={comment.likes}
-                                dislikes={comment.dislikes}
+                                reactions={comment.reactions}
+                                my_reaction={comment.my_reaction}
                                 username={comment.username}
                                 profile_image={comment.profile_image}
                             />
@@ -8,22 +8,29 @@ const PostFeed: React.FC = () => {
     const BE_PORT = process.env.NEXT_PUBLIC_BACKEND_PORT;
     const FE_URL = process.env.NEXT_PUBLIC_URL;
     const [posts, setPosts] = useState<PostProps[]>([]);
+    const [nextCursor, setNextCursor] = useState<string | null>(null);
     const [comments, setComments] = useState<{ [postId: number]: CommentProps[] }>([]);
 
-    useEffect(() => {
-        // Fetch posts
-        fetch(`${FE_URL}:${BE_PORT}/posts`, {
+    // Fetch a page of posts, the next page starts after the cursor of the previous one
+    const fetchPosts = (cursor: string | null) => {
+        const query = cursor ? `?cursor=${encodeURIComponent(cursor)}` : '';
+        fetch(`${FE_URL}:${BE_PORT}/posts${query}`, {
             method: 'GET',
             credentials: 'include' // Send cookies with the request
         })
             .then(response => response.json())
             .then(data => {
-                if (data === null) {
+                if (data === null || !data.posts) {
                     return;
                 }
-                setPosts(data);
+                setPosts(prevPosts => cursor ? [...prevPosts, ...data.posts] : data.posts);
+                setNextCursor(data.next_cursor ?? null);
             })
             .catch(error => console.error('Error fetching posts:', error));
+    };
+
+    useEffect(() => {
+        fetchPosts(null);
     }, []);
 
     useEffect(() => {
@@ -68,6 +75,9 @@ const PostFeed: React.FC = () => {
                                 <p>No posts found</p>
                             </div>
                     }
+                    {nextCursor && (
+                        <button className="btn mt-2" onClick={() => fetchPosts(nextCursor)}>Load more</button>
+                    )}
                 </div>
                 {/* News */}
                 <div>
@@ -68,8 +68,7 @@ const CreateGroupPost: React.FC<CreatePostGroupProps> = ({ groupId , onNewPost,
                 image_url: data.data.image_url,
                 privacySetting: data.data.privacy_setting,
                 created_at: new Date(data.data.created_at),
-                likes: 0,
-                dislikes: 0,
+                reactions: {},
                 setComments: setComments,
             }
             onNewPost && onNewPost(newPost);
@@ -14,15 +14,15 @@ export interface PostProps {
     image_url?: string;
     privacySetting: string;
     created_at: Date;
-    likes: number;
-    dislikes: number;
+    reactions: { [reaction: string]: number };
+    my_reaction?: string;
     creator: string;
     creator_avatar?: string;
     comments?: CommentProps[];
     setComments: React.Dispatch<React.SetStateAction<{[postId: number]: CommentProps[]}>>;
 }
 
-const Post: React.FC<PostProps> = ({ id, userId, groupId, title, content, image_url, privacySetting, created_at, likes, dislikes, creator, creator_avatar, comments, setComments }) => {
+const Post: React.FC<PostProps> = ({ id, userId, groupId, title, content, image_url, privacySetting, created_at, creator, creator_avatar, comments, setComments }) => {
     return (
         <div style={{ border: '1px solid #ccc', borderRadius: '8px', padding: '20px', marginBottom: '20px' }}>
             {/* Post Content */}
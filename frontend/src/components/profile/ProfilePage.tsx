@@ -36,27 +36,33 @@ export const ProfileFeed: React.FC<ProfileFeedProps> = ({ profile, friends, user
     const BE_PORT = process.env.NEXT_PUBLIC_BACKEND_PORT;
     const FE_URL = process.env.NEXT_PUBLIC_URL;
     const [posts, setPosts] = useState<PostProps[]>([]);
+    const [nextCursor, setNextCursor] = useState<string | null>(null);
     const [comments, setComments] = useState<{ [postId: number]: CommentProps[] }>([]);
 
-
-    useEffect(() => {
-        // Fetch posts
-        if (userID !== "me") {
-            userID = parseInt(userID);
-        }
-        fetch(`${FE_URL}:${BE_PORT}/profile/posts/${userID}`, {
+    // Fetch a page of posts, the next page starts after the cursor of the previous one
+    const fetchPosts = (cursor: string | null) => {
+        const query = cursor ? `?cursor=${encodeURIComponent(cursor)}` : '';
+        fetch(`${FE_URL}:${BE_PORT}/profile/posts/${userID}${query}`, {
             method: 'GET',
             credentials: 'include' // Send cookies with the request
         })
             .then(response => response.json())
             .then(data => {
-                if (data === null) {
+                if (data === null || !data.posts) {
                     return;
                 }
-                setPosts(data);
-                console.log(data);
+                setPosts(prevPosts => cursor ? [...prevPosts, ...data.posts] : data.posts);
+                setNextCursor(data.next_cursor ?? null);
             })
             .catch(error => console.error('Error fetching posts:', error));
+    };
+
+    useEffect(() => {
+        // Fetch posts
+        if (userID !== "me") {
+            userID = parseInt(userID);
+        }
+        fetchPosts(null);
     }, []);
 
     useEffect(() => {
@@ -150,6 +156,9 @@ export const ProfileFeed: React.FC<ProfileFeedProps> = ({ profile, friends, user
                                 <p>No posts found</p>
                             </div>
                     }
+                    {nextCursor && (
+                        <button className="btn mt-2" onClick={() => fetchPosts(nextCursor)}>Load more</button>
+                    )}
                 </div>
             </section>
 